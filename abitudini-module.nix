@@ -4,7 +4,7 @@ with lib;
 
 let
   cfg = config.services.abitudini;
-  abitudiniPkg = pkgs.callPackage ./. { };
+  abitudiniPkg = pkgs.callPackage ./nix/package.nix { };
 in
 
 {
@@ -17,12 +17,32 @@ in
       description = "Port for the Abitudini HTTP server to listen on";
     };
 
+    address = mkOption {
+      type = types.str;
+      default = "";
+      example = "127.0.0.1";
+      description = "Address to bind to; empty listens on all interfaces";
+    };
+
     dataDir = mkOption {
       type = types.path;
       default = "/var/lib/abitudini";
       description = "Directory where Abitudini will store its database and data";
     };
 
+    configFile = mkOption {
+      type = types.nullOr types.path;
+      default = null;
+      description = "Optional TOML or YAML config file; environment settings below take precedence";
+    };
+
+    environmentFile = mkOption {
+      type = types.nullOr types.path;
+      default = null;
+      example = "/run/secrets/abitudini.env";
+      description = "File with extra environment variables, such as ABITUDINI_OIDC_CLIENT_SECRET, kept out of the Nix store";
+    };
+
     package = mkOption {
       type = types.package;
       default = abitudiniPkg;
@@ -39,7 +59,7 @@ in
       serviceConfig = {
         # Execution
         ExecStart = "${cfg.package}/bin/abitudini";
-        WorkingDirectory = "${cfg.package}/share/abitudini";
+        WorkingDirectory = cfg.dataDir;
 
         # User/Security
         DynamicUser = true;
@@ -50,7 +70,11 @@ in
         StateDirectoryMode = "0755";
 
         # Environment
-        Environment = "PORT=${toString cfg.port}";
+        Environment = [
+          "ABITUDINI_DB=${cfg.dataDir}/abitudini.db"
+          "ABITUDINI_ADDR=${cfg.address}:${toString cfg.port}"
+        ] ++ optional (cfg.configFile != null) "ABITUDINI_CONFIG=${cfg.configFile}";
+        EnvironmentFile = mkIf (cfg.environmentFile != null) cfg.environmentFile;
 
         # Process isolation
         Type = "simple";
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/epalmerini/abitudini/internal/auth"
+	"github.com/epalmerini/abitudini/internal/backup"
+	"github.com/epalmerini/abitudini/internal/config"
+	"github.com/epalmerini/abitudini/internal/db"
+	"github.com/epalmerini/abitudini/internal/export"
+	"github.com/epalmerini/abitudini/internal/importer"
+)
+
+// runCommand runs the subcommand named by args[0], if any, and reports
+// whether one was run
+func runCommand(args []string, stdout io.Writer) (bool, error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+
+	switch args[0] {
+	case "export":
+		return true, runExport(args[1:], stdout)
+	case "import":
+		return true, runImport(args[1:], stdout)
+	case "import-loop":
+		return true, runImportLoop(args[1:], stdout)
+	case "migrate":
+		return true, runMigrate(args[1:], stdout)
+	case "backup":
+		return true, runBackup(args[1:], stdout)
+	case "restore":
+		return true, runRestore(args[1:], stdout)
+	}
+	return false, nil
+}
+
+// runExport writes every habit and record to stdout or a file
+func runExport(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	formatFlag := flags.String("format", "json", "Export format: json or csv")
+	output := flags.String("o", "", "Write the export to this file instead of stdout")
+	username := flags.String("user", "", "Export this account's habits (required once accounts exist)")
+	cfg, err := config.LoadCommand(flags, args, os.Getenv)
+	if err != nil {
+		return err
+	}
+
+	format, err := export.ParseFormat(*formatFlag)
+	if err != nil {
+		return err
+	}
+
+	database, err := db.Init(cfg.DBPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer database.Close()
+
+	userID, err := resolveUser(database, *username)
+	if err != nil {
+		return err
+	}
+
+	doc, err := export.NewService(export.NewStore(database)).Export(userID)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		return export.Write(stdout, doc, format)
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+	if err := export.Write(file, doc, format); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// runImport imports an export file and prints what changed
+func runImport(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	formatFlag := flags.String("format", "", "Import format: json or csv (default: from the file extension)")
+	modeFlag := flags.String("mode", "merge", "Import mode: merge or replace")
+	dryRun := flags.Bool("dry-run", false, "Report what would change without saving anything")
+	username := flags.String("user", "", "Import into this account (required once accounts exist)")
+	cfg, err := config.LoadCommand(flags, args, os.Getenv)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: abitudini import [flags] <file>")
+	}
+	path := flags.Arg(0)
+
+	if *formatFlag == "" {
+		*formatFlag = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+	format, err := export.ParseFormat(*formatFlag)
+	if err != nil {
+		return err
+	}
+
+	mode, err := importer.ParseMode(*modeFlag)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open import file: %w", err)
+	}
+	defer file.Close()
+
+	doc, err := export.Read(file, format)
+	if err != nil {
+		return err
+	}
+
+	return importDocument(doc, cfg.DBPath, *username, importer.Options{Mode: mode, DryRun: *dryRun}, stdout)
+}
+
+// runImportLoop imports a Loop Habit Tracker backup and prints what changed
+func runImportLoop(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("import-loop", flag.ContinueOnError)
+	modeFlag := flags.String("mode", "merge", "Import mode: merge or replace")
+	dryRun := flags.Bool("dry-run", false, "Report what would change without saving anything")
+	username := flags.String("user", "", "Import into this account (required once accounts exist)")
+	cfg, err := config.LoadCommand(flags, args, os.Getenv)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: abitudini import-loop [flags] <backup.db|export.zip>")
+	}
+
+	mode, err := importer.ParseMode(*modeFlag)
+	if err != nil {
+		return err
+	}
+
+	doc, err := importer.ReadLoop(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	return importDocument(doc, cfg.DBPath, *username, importer.Options{Mode: mode, DryRun: *dryRun}, stdout)
+}
+
+// importDocument imports doc into the database at dbPath for the named
+// account and prints the report summary
+func importDocument(doc *export.Document, dbPath, username string, opts importer.Options, stdout io.Writer) error {
+	database, err := db.Init(dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer database.Close()
+
+	userID, err := resolveUser(database, username)
+	if err != nil {
+		return err
+	}
+
+	report, err := importer.NewService(importer.NewStore(database)).Import(userID, doc, opts)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range report.Summary() {
+		fmt.Fprintln(stdout, line)
+	}
+	return nil
+}
+
+// resolveUser returns the ID of the named account. Without a name it
+// returns 0, the owner of habits created before accounts existed, as long
+// as no account exists yet.
+func resolveUser(database *sql.DB, username string) (int, error) {
+	store := auth.NewStore(database)
+	if username == "" {
+		users, err := store.CountUsers()
+		if err != nil {
+			return 0, err
+		}
+		if users > 0 {
+			return 0, errors.New("-user is required once accounts exist")
+		}
+		return 0, nil
+	}
+
+	user, _, err := store.GetUserByUsername(username)
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		return 0, fmt.Errorf("no account named %q", username)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return user.ID, nil
+}
+
+// runMigrate shows the schema version or migrates up or down. Unlike the
+// server it opens the database without migrating it first.
+func runMigrate(args []string, stdout io.Writer) error {
+	const usage = "usage: abitudini migrate status|up|down [-to version] [flags]"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	action := args[0]
+	if action != "status" && action != "up" && action != "down" {
+		return errors.New(usage)
+	}
+
+	flags := flag.NewFlagSet("migrate "+action, flag.ContinueOnError)
+	to := flags.Int("to", -1, "Target schema version (default: latest for up, one below the current for down)")
+	cfg, err := config.LoadCommand(flags, args[1:], os.Getenv)
+	if err != nil {
+		return err
+	}
+
+	database, err := db.Open(cfg.DBPath)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	current, err := db.CurrentVersion(database)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "status":
+		return printMigrationStatus(database, current, stdout)
+	case "up":
+		target := db.LatestVersion()
+		if *to >= 0 {
+			target = *to
+		}
+		if target < current {
+			return fmt.Errorf("schema is at version %d; use migrate down to go back to %d", current, target)
+		}
+		if err := db.MigrateTo(database, target); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Migrated from version %d to %d\n", current, target)
+	case "down":
+		target := current - 1
+		if *to >= 0 {
+			target = *to
+		}
+		if target > current {
+			return fmt.Errorf("schema is at version %d; use migrate up to go forward to %d", current, target)
+		}
+		if target < 0 {
+			return errors.New("schema is at version 0; nothing to revert")
+		}
+		if err := db.MigrateTo(database, target); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Reverted from version %d to %d\n", current, target)
+	}
+	return nil
+}
+
+// printMigrationStatus lists every migration and whether it is applied
+func printMigrationStatus(database *sql.DB, current int, stdout io.Writer) error {
+	statuses, err := db.Status(database)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "Schema version %d (latest %d)\n", current, db.LatestVersion())
+	if current > db.LatestVersion() {
+		fmt.Fprintln(stdout, "The database was migrated by a newer build of abitudini")
+	}
+	for _, m := range statuses {
+		applied := "pending"
+		if m.Applied {
+			applied = "applied " + m.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(stdout, "%4d  %-28s %s\n", m.Version, m.Name, applied)
+	}
+	return nil
+}
+
+// runBackup copies the database to a new file. It is safe to run while the
+// server is up.
+func runBackup(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	cfg, err := config.LoadCommand(flags, args, os.Getenv)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: abitudini backup [flags] <file>")
+	}
+	if _, err := os.Stat(cfg.DBPath); err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+
+	database, err := db.Open(cfg.DBPath)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	if err := backup.Create(database, flags.Arg(0)); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Backed up %s to %s\n", cfg.DBPath, flags.Arg(0))
+	return nil
+}
+
+// runRestore replaces the database with a backup after checking that this
+// build can read it. The current database is saved next to it first.
+func runRestore(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	cfg, err := config.LoadCommand(flags, args, os.Getenv)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: abitudini restore [flags] <file>")
+	}
+	path := flags.Arg(0)
+
+	if _, err := backup.Validate(path); err != nil {
+		return err
+	}
+
+	_, statErr := os.Stat(cfg.DBPath)
+	existed := statErr == nil
+
+	database, err := db.Open(cfg.DBPath)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	if existed {
+		safety := cfg.DBPath + ".before-restore-" + time.Now().UTC().Format("20060102-150405")
+		if err := backup.Create(database, safety); err != nil {
+			return fmt.Errorf("failed to save the current database: %w", err)
+		}
+		fmt.Fprintf(stdout, "Saved the current database to %s\n", safety)
+	}
+
+	version, err := backup.Restore(database, path)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Restored %s from schema version %d, now at %d\n", path, version, db.LatestVersion())
+	return nil
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/epalmerini/abitudini/internal/auth"
+	"github.com/epalmerini/abitudini/internal/db"
+)
+
+// newTestDBFile creates a migrated database file with one habit and record
+func newTestDBFile(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "abitudini.db")
+	database, err := db.Init(path)
+	if err != nil {
+		t.Fatalf("failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	if _, err := database.Exec(`INSERT INTO habits (id, description, start_date, color) VALUES (1, 'Read', '2025-01-01', '')`); err != nil {
+		t.Fatalf("failed to create habit: %v", err)
+	}
+	if _, err := database.Exec(`INSERT INTO records (habit_id, record_date, completed_at) VALUES (1, '2025-01-02', '2025-01-02 20:00:00')`); err != nil {
+		t.Fatalf("failed to create record: %v", err)
+	}
+	return path
+}
+
+func TestRunCommand_NoCommand(t *testing.T) {
+	handled, err := runCommand([]string{"-p", "9000"}, &bytes.Buffer{})
+	if handled || err != nil {
+		t.Errorf("expected flags to fall through to the server, got %v, %v", handled, err)
+	}
+}
+
+func TestRunCommand_ExportCSVToStdout(t *testing.T) {
+	dbPath := newTestDBFile(t)
+	var out bytes.Buffer
+
+	handled, err := runCommand([]string{"export", "-db", dbPath, "-format", "csv"}, &out)
+	if !handled || err != nil {
+		t.Fatalf("expected export to run, got %v, %v", handled, err)
+	}
+
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatalf("expected valid CSV, got %v", err)
+	}
+	if len(rows) != 2 || rows[1][1] != "Read" || rows[1][11] != "2025-01-02" {
+		t.Errorf("unexpected CSV export %v", rows)
+	}
+}
+
+func TestRunCommand_ExportJSONToFile(t *testing.T) {
+	dbPath := newTestDBFile(t)
+	output := filepath.Join(t.TempDir(), "export.json")
+
+	if _, err := runCommand([]string{"export", "-db", dbPath, "-o", output}, &bytes.Buffer{}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("expected export file, got %v", err)
+	}
+	if !strings.Contains(string(data), `"description": "Read"`) {
+		t.Errorf("unexpected JSON export %s", data)
+	}
+}
+
+func TestRunCommand_ExportInvalidFormat(t *testing.T) {
+	if _, err := runCommand([]string{"export", "-format", "xml"}, &bytes.Buffer{}); err == nil {
+		t.Error("expected error for unsupported format")
+	}
+}
+
+func TestRunCommand_ExportUser(t *testing.T) {
+	dbPath := newTestDBFile(t)
+	database, err := db.Init(dbPath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if _, err := auth.NewStore(database).CreateUser("alice", "unused"); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	database.Close()
+
+	if _, err := runCommand([]string{"export", "-db", dbPath}, &bytes.Buffer{}); err == nil {
+		t.Error("expected -user to be required once accounts exist")
+	}
+	if _, err := runCommand([]string{"export", "-db", dbPath, "-user", "bob"}, &bytes.Buffer{}); err == nil {
+		t.Error("expected error for an unknown account")
+	}
+
+	var out bytes.Buffer
+	if _, err := runCommand([]string{"export", "-db", dbPath, "-user", "Alice"}, &out); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !strings.Contains(out.String(), `"description": "Read"`) {
+		t.Errorf("expected the first account to own the existing habit, got %s", out.String())
+	}
+}
+
+func TestRunCommand_ImportDryRun(t *testing.T) {
+	dbPath := newTestDBFile(t)
+	input := filepath.Join(t.TempDir(), "habits.csv")
+	csvData := "habit_id,description,start_date,date\n5,Read,2025-01-01,2025-01-03\n6,Walk,2025-01-01,2025-01-03\n"
+	if err := os.WriteFile(input, []byte(csvData), 0o644); err != nil {
+		t.Fatalf("failed to write import file: %v", err)
+	}
+
+	var out bytes.Buffer
+	if _, err := runCommand([]string{"import", "-db", dbPath, "-dry-run", input}, &out); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	summary := out.String()
+	if !strings.Contains(summary, "Dry run (merge)") || !strings.Contains(summary, "Created 1 habits: Walk") {
+		t.Errorf("unexpected summary %s", summary)
+	}
+}
+
+func TestRunCommand_ImportRequiresFile(t *testing.T) {
+	if _, err := runCommand([]string{"import"}, &bytes.Buffer{}); err == nil {
+		t.Error("expected usage error without a file")
+	}
+}
+
+func TestRunCommand_ImportLoopCSV(t *testing.T) {
+	dbPath := newTestDBFile(t)
+	input := filepath.Join(t.TempDir(), "Loop Habits CSV.zip")
+	file, err := os.Create(input)
+	if err != nil {
+		t.Fatalf("failed to create export: %v", err)
+	}
+	archive := zip.NewWriter(file)
+	w, _ := archive.Create("Habits.csv")
+	w.Write([]byte("Position,Name,Description,NumRepetitions,Interval,Color\n001,Stretch,,3,7,#00897B\n"))
+	w, _ = archive.Create("Checkmarks.csv")
+	w.Write([]byte("Date,Stretch\n2025-03-09,2\n2025-03-08,2\n"))
+	archive.Close()
+	file.Close()
+
+	var out bytes.Buffer
+	if _, err := runCommand([]string{"import-loop", "-db", dbPath, input}, &out); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	summary := out.String()
+	if !strings.Contains(summary, "Created 1 habits: Stretch") || !strings.Contains(summary, "Added 2 records") {
+		t.Errorf("unexpected summary %s", summary)
+	}
+}
+
+func TestRunCommand_Migrate(t *testing.T) {
+	dbPath := newTestDBFile(t)
+	latest := db.LatestVersion()
+
+	var out bytes.Buffer
+	if _, err := runCommand([]string{"migrate", "down", "-db", dbPath}, &out); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if want := fmt.Sprintf("Reverted from version %d to %d", latest, latest-1); !strings.Contains(out.String(), want) {
+		t.Errorf("expected %q, got %s", want, out.String())
+	}
+
+	out.Reset()
+	if _, err := runCommand([]string{"migrate", "status", "-db", dbPath}, &out); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !strings.Contains(out.String(), fmt.Sprintf("Schema version %d (latest %d)", latest-1, latest)) || !strings.Contains(out.String(), "pending") {
+		t.Errorf("unexpected status %s", out.String())
+	}
+
+	out.Reset()
+	if _, err := runCommand([]string{"migrate", "up", "-db", dbPath}, &out); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !strings.Contains(out.String(), fmt.Sprintf("to %d", latest)) {
+		t.Errorf("unexpected output %s", out.String())
+	}
+}
+
+func TestRunCommand_MigrateUsage(t *testing.T) {
+	for _, args := range [][]string{{"migrate"}, {"migrate", "sideways"}} {
+		if _, err := runCommand(args, &bytes.Buffer{}); err == nil {
+			t.Errorf("%v: expected usage error", args)
+		}
+	}
+
+	dbPath := newTestDBFile(t)
+	if _, err := runCommand([]string{"migrate", "up", "-to", "1", "-db", dbPath}, &bytes.Buffer{}); err == nil {
+		t.Error("expected up to an older version to be refused")
+	}
+}
+
+func TestRunCommand_BackupAndRestore(t *testing.T) {
+	dbPath := newTestDBFile(t)
+	backupPath := filepath.Join(t.TempDir(), "backup.db")
+
+	var out bytes.Buffer
+	if _, err := runCommand([]string{"backup", "-db", dbPath, backupPath}, &out); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !strings.Contains(out.String(), "Backed up") {
+		t.Errorf("unexpected output %s", out.String())
+	}
+
+	// Restoring into a database with other habits replaces them and keeps
+	// a copy of what was there
+	target := newTestDBFile(t)
+	database, err := db.Open(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.Exec(`DELETE FROM habits`); err != nil {
+		t.Fatal(err)
+	}
+	database.Close()
+
+	out.Reset()
+	if _, err := runCommand([]string{"restore", "-db", target, backupPath}, &out); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !strings.Contains(out.String(), "Saved the current database to "+target+".before-restore-") {
+		t.Errorf("expected a safety copy, got %s", out.String())
+	}
+
+	database, err = db.Open(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	var habits int
+	if err := database.QueryRow(`SELECT COUNT(*) FROM habits`).Scan(&habits); err != nil || habits != 1 {
+		t.Errorf("expected the backup's habit, got %d (%v)", habits, err)
+	}
+}
+
+func TestRunCommand_BackupUsage(t *testing.T) {
+	for _, args := range [][]string{{"backup"}, {"restore"}, {"backup", "a.db", "b.db"}} {
+		if _, err := runCommand(args, &bytes.Buffer{}); err == nil {
+			t.Errorf("%v: expected usage error", args)
+		}
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing.db")
+	if _, err := runCommand([]string{"backup", "-db", missing, filepath.Join(t.TempDir(), "b.db")}, &bytes.Buffer{}); err == nil {
+		t.Error("expected backing up a missing database to fail")
+	}
+}
@@ -0,0 +1,216 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/epalmerini/abitudini/internal/shared"
+)
+
+func csrfCookieFrom(w *httptest.ResponseRecorder) *http.Cookie {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == csrfCookie {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestCSRF_IssuesToken(t *testing.T) {
+	handler := NewHandler(nil, nil, nil)
+
+	var seen string
+	guarded := handler.CSRF(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = shared.CSRFToken(r.Context())
+	}))
+
+	w := httptest.NewRecorder()
+	guarded.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	cookie := csrfCookieFrom(w)
+	if cookie == nil || !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode {
+		t.Fatalf("expected an HttpOnly CSRF cookie, got %+v", cookie)
+	}
+	if seen == "" || seen != cookie.Value {
+		t.Errorf("expected the page to get the cookie's token, got %q and %q", seen, cookie.Value)
+	}
+
+	// The token is kept while the cookie is
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(cookie)
+	w = httptest.NewRecorder()
+	guarded.ServeHTTP(w, req)
+	if csrfCookieFrom(w) != nil || seen != cookie.Value {
+		t.Errorf("expected the existing token to be reused, got %q", seen)
+	}
+
+	// A cookie this server did not issue is replaced
+	req = httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: csrfCookie, Value: "chosen-by-someone-else"})
+	w = httptest.NewRecorder()
+	guarded.ServeHTTP(w, req)
+	if replaced := csrfCookieFrom(w); replaced == nil || replaced.Value == "chosen-by-someone-else" {
+		t.Errorf("expected a malformed token to be replaced, got %+v", replaced)
+	}
+}
+
+func TestCSRF_Session(t *testing.T) {
+	handler := newTestHandler(t, true)
+
+	reached := false
+	guarded := handler.CSRF(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+
+	page := httptest.NewRecorder()
+	guarded.ServeHTTP(page, httptest.NewRequest("GET", "/", nil))
+	token := csrfCookieFrom(page)
+	login := httptest.NewRecorder()
+	handler.Login(login, postForm("/login", url.Values{"username": {"alice"}, "password": {"long enough"}}))
+	session := sessionCookie(login)
+
+	tests := []struct {
+		name    string
+		prepare func(req *http.Request)
+		want    int
+	}{
+		{"header", func(req *http.Request) {
+			req.Header.Set(CSRFHeader, token.Value)
+		}, http.StatusOK},
+		{"missing", func(req *http.Request) {}, http.StatusForbidden},
+		{"wrong", func(req *http.Request) {
+			req.Header.Set(CSRFHeader, "not-the-token")
+		}, http.StatusForbidden},
+		{"header without cookie", func(req *http.Request) {
+			req.Header.Set(CSRFHeader, token.Value)
+			req.Header.Del("Cookie")
+			req.AddCookie(session)
+		}, http.StatusForbidden},
+		{"same origin", func(req *http.Request) {
+			req.Header.Set(CSRFHeader, token.Value)
+			req.Header.Set("Sec-Fetch-Site", "same-origin")
+			req.Header.Set("Origin", "http://example.com")
+		}, http.StatusOK},
+		{"cross site", func(req *http.Request) {
+			req.Header.Set(CSRFHeader, token.Value)
+			req.Header.Set("Sec-Fetch-Site", "cross-site")
+		}, http.StatusForbidden},
+		{"sibling site", func(req *http.Request) {
+			req.Header.Set(CSRFHeader, token.Value)
+			req.Header.Set("Sec-Fetch-Site", "same-site")
+		}, http.StatusForbidden},
+		{"foreign origin", func(req *http.Request) {
+			req.Header.Set(CSRFHeader, token.Value)
+			req.Header.Set("Origin", "https://evil.example")
+		}, http.StatusForbidden},
+		{"null origin", func(req *http.Request) {
+			req.Header.Set(CSRFHeader, token.Value)
+			req.Header.Set("Origin", "null")
+		}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reached = false
+			req := httptest.NewRequest("DELETE", "/api/habits/1", nil)
+			req.AddCookie(session)
+			req.AddCookie(token)
+			tt.prepare(req)
+			w := httptest.NewRecorder()
+			guarded.ServeHTTP(w, req)
+			if w.Code != tt.want || reached != (tt.want == http.StatusOK) {
+				t.Errorf("expected status %d, got %d (reached handler: %v)", tt.want, w.Code, reached)
+			}
+		})
+	}
+
+	// Plain forms send the token as a field, which the handler still sees
+	var username string
+	guarded = handler.CSRF(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username = r.FormValue("username")
+	}))
+	req := postForm("/logout", url.Values{"csrf_token": {token.Value}, "username": {"alice"}})
+	req.AddCookie(session)
+	req.AddCookie(token)
+	w := httptest.NewRecorder()
+	guarded.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || username != "alice" {
+		t.Errorf("expected the form post through, got %d %q", w.Code, username)
+	}
+}
+
+func TestCSRF_WithoutAmbientCredentials(t *testing.T) {
+	handler := newTestHandler(t, true)
+	s := handler.service.(*Service)
+	user, _, _ := s.store.GetUserByUsername("alice")
+	secret, _, _ := s.CreateToken(user.ID, "cron", ScopeWrite)
+	login := httptest.NewRecorder()
+	handler.Login(login, postForm("/login", url.Values{"username": {"alice"}, "password": {"long enough"}}))
+	session := sessionCookie(login)
+
+	reached := false
+	guarded := handler.CSRF(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+
+	request := func(prepare func(req *http.Request)) int {
+		reached = false
+		req := httptest.NewRequest("POST", "/api/habits", strings.NewReader(`{"name":"Read"}`))
+		req.Header.Set("Content-Type", "application/json")
+		prepare(req)
+		w := httptest.NewRecorder()
+		guarded.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Scripts logging in or using an API token have nothing to forge
+	if code := request(func(req *http.Request) {}); code != http.StatusOK || !reached {
+		t.Errorf("expected a request without cookies through, got %d", code)
+	}
+	if code := request(func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+secret)
+		req.AddCookie(session)
+	}); code != http.StatusOK || !reached {
+		t.Errorf("expected an API token request through, got %d", code)
+	}
+
+	// Only a token that authenticates the request waives the check
+	for _, bogus := range []string{"abt_secret", "anything"} {
+		if code := request(func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer "+bogus)
+			req.AddCookie(session)
+		}); code != http.StatusForbidden || reached {
+			t.Errorf("expected status 403 for %q next to a session cookie, got %d", bogus, code)
+		}
+	}
+
+	// Browsers are still kept from posting across sites, so nobody gets
+	// logged in to an account of someone else's choosing
+	if code := request(func(req *http.Request) {
+		req.Header.Set("Sec-Fetch-Site", "cross-site")
+	}); code != http.StatusForbidden || reached {
+		t.Errorf("expected status 403 for a cross-site post, got %d", code)
+	}
+}
+
+func TestCSRF_Proxy(t *testing.T) {
+	handler := NewHandler(nil, nil, &ProxyAuth{
+		Header:  "Remote-User",
+		Trusted: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+	})
+	guarded := handler.CSRF(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// The proxy adds the header to whatever the browser sends, so it is as
+	// ambient as a cookie
+	req := httptest.NewRequest("POST", "/api/habits", nil)
+	req.RemoteAddr = "10.1.2.3:51000"
+	req.Header.Set("Remote-User", "alice")
+	w := httptest.NewRecorder()
+	guarded.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 without a token, got %d", w.Code)
+	}
+}
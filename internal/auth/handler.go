@@ -0,0 +1,589 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/epalmerini/abitudini/internal/shared"
+)
+
+// HandlerService interface for dependency injection
+type HandlerService interface {
+	Register(username, password string) (*User, error)
+	Login(username, password string) (string, time.Time, error)
+	StartSession(userID int) (string, time.Time, error)
+	Authenticate(token string) (*User, error)
+	Logout(token string) error
+	RegistrationOpen() (bool, error)
+	CreateToken(userID int, name, scope string) (string, *Token, error)
+	ListTokens(userID int) ([]Token, error)
+	RevokeToken(userID, tokenID int) error
+	AuthenticateToken(secret string) (*User, *Token, error)
+	ValidToken(secret string) bool
+	LoginIdentity(identity Identity, current *User) (string, time.Time, error)
+	ProxyUser(username string) (*User, error)
+}
+
+// OIDCClient runs the single sign-on flow against the identity provider
+type OIDCClient interface {
+	AuthCodeURL(ctx context.Context, redirectURL, state, nonce, verifier string) (string, error)
+	Exchange(ctx context.Context, redirectURL, code, verifier, nonce string) (*Identity, error)
+}
+
+// oidcCookie holds the state, nonce and PKCE verifier of a single sign-on
+// login between leaving for the provider and coming back
+const oidcCookie = "abitudini_oidc"
+
+const oidcCallbackPath = "/login/oidc/callback"
+
+// ProxyAuth trusts an authenticating reverse proxy to name the user in a
+// header. The header is only believed from the trusted addresses.
+type ProxyAuth struct {
+	Header  string
+	Trusted []netip.Prefix
+}
+
+// username returns the user the proxy vouches for, if the request came
+// straight from a trusted proxy
+func (p *ProxyAuth) username(r *http.Request) string {
+	if p == nil {
+		return ""
+	}
+	addrPort, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return ""
+	}
+	addr := addrPort.Addr().Unmap()
+	for _, prefix := range p.Trusted {
+		if prefix.Contains(addr) {
+			return strings.TrimSpace(r.Header.Get(p.Header))
+		}
+	}
+	return ""
+}
+
+type Handler struct {
+	shared.BaseHandler
+	service HandlerService
+	oidc    OIDCClient
+	proxy   *ProxyAuth
+}
+
+// NewHandler creates the account handler. oidc is nil when single sign-on
+// is not configured, proxy when proxy authentication is not.
+func NewHandler(service HandlerService, oidc OIDCClient, proxy *ProxyAuth) *Handler {
+	return &Handler{service: service, oidc: oidc, proxy: proxy}
+}
+
+// LoginPage serves the login form
+func (h *Handler) LoginPage(w http.ResponseWriter, r *http.Request) {
+	if !h.ValidateMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	open, err := h.service.RegistrationOpen()
+	if err != nil {
+		h.RespondError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.WriteHTML(w, RenderLogin("", "", open, h.oidc != nil, shared.CSRFToken(r.Context())))
+}
+
+// Login checks the credentials and sets the session cookie
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
+	if !h.ValidateMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	if err := h.ParseInput(r); err != nil {
+		h.RespondError(w, r, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	username := r.FormValue("username")
+
+	token, expiresAt, err := h.service.Login(username, r.FormValue("password"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrInvalidCredentials) {
+			status = http.StatusUnauthorized
+		}
+		if h.WantsJSON(r) {
+			h.RespondError(w, r, err.Error(), status)
+			return
+		}
+		h.loginFailed(w, r, username, err.Error(), status)
+		return
+	}
+
+	h.setSession(w, r, token, expiresAt)
+	user, err := h.service.Authenticate(token)
+	if err != nil {
+		h.RespondError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.loggedIn(w, r, http.StatusOK, user)
+}
+
+// OIDCLogin sends the browser to the identity provider to log in
+func (h *Handler) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if !h.ValidateMethod(w, r, http.MethodGet) {
+		return
+	}
+	if h.oidc == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	var values [3]string
+	for i := range values {
+		value, err := randomToken()
+		if err != nil {
+			h.RespondError(w, r, "failed to start single sign-on", http.StatusInternalServerError)
+			return
+		}
+		values[i] = value
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	target, err := h.oidc.AuthCodeURL(r.Context(), callbackURL(r), state, nonce, verifier)
+	if err != nil {
+		h.loginFailed(w, r, "", "Single sign-on is unavailable: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	// SameSite=Lax still sends the cookie on the provider's redirect back
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcCookie,
+		Value:    state + "." + nonce + "." + verifier,
+		Path:     "/login/oidc",
+		MaxAge:   600,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// OIDCCallback finishes a single sign-on login when the provider sends the
+// browser back with an authorization code
+func (h *Handler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if !h.ValidateMethod(w, r, http.MethodGet) {
+		return
+	}
+	if h.oidc == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	var state, nonce, verifier string
+	if cookie, err := r.Cookie(oidcCookie); err == nil {
+		parts := strings.Split(cookie.Value, ".")
+		if len(parts) == 3 {
+			state, nonce, verifier = parts[0], parts[1], parts[2]
+		}
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcCookie,
+		Value:    "",
+		Path:     "/login/oidc",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	query := r.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		message := providerErr
+		if description := query.Get("error_description"); description != "" {
+			message = description
+		}
+		h.loginFailed(w, r, "", "Single sign-on failed: "+message, http.StatusUnauthorized)
+		return
+	}
+	if state == "" || subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(state)) != 1 {
+		h.loginFailed(w, r, "", "Single sign-on expired or was started elsewhere; please try again", http.StatusBadRequest)
+		return
+	}
+
+	identity, err := h.oidc.Exchange(r.Context(), callbackURL(r), query.Get("code"), verifier, nonce)
+	if err != nil {
+		h.loginFailed(w, r, "", "Single sign-on failed: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	// Logging in with single sign-on while logged in with a password is
+	// how an account confirms the link
+	var current *User
+	if cookie, err := r.Cookie(SessionCookie); err == nil {
+		current, _ = h.service.Authenticate(cookie.Value)
+	}
+
+	token, expiresAt, err := h.service.LoginIdentity(*identity, current)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrInvalidAccount):
+			status = http.StatusForbidden
+		case errors.Is(err, ErrIdentityConflict):
+			status = http.StatusConflict
+		}
+		h.loginFailed(w, r, "", "Single sign-on failed: "+err.Error(), status)
+		return
+	}
+
+	h.setSession(w, r, token, expiresAt)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// RegisterPage serves the registration form
+func (h *Handler) RegisterPage(w http.ResponseWriter, r *http.Request) {
+	if !h.ValidateMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	open, err := h.service.RegistrationOpen()
+	if err != nil {
+		h.RespondError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !open {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	h.WriteHTML(w, RenderRegister("", "", shared.CSRFToken(r.Context())))
+}
+
+// Register creates an account and logs it in
+func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
+	if !h.ValidateMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	if err := h.ParseInput(r); err != nil {
+		h.RespondError(w, r, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	username := r.FormValue("username")
+
+	user, err := h.service.Register(username, r.FormValue("password"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrInvalidAccount):
+			status = http.StatusBadRequest
+		case errors.Is(err, ErrUsernameTaken):
+			status = http.StatusConflict
+		case errors.Is(err, ErrRegistrationClosed):
+			status = http.StatusForbidden
+		}
+		if h.WantsJSON(r) {
+			h.RespondError(w, r, err.Error(), status)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+		w.Write([]byte(RenderRegister(username, err.Error(), shared.CSRFToken(r.Context()))))
+		return
+	}
+
+	token, expiresAt, err := h.service.StartSession(user.ID)
+	if err != nil {
+		h.RespondError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.setSession(w, r, token, expiresAt)
+	h.loggedIn(w, r, http.StatusCreated, user)
+}
+
+// Logout ends the session and clears the cookie
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	if !h.ValidateMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	if cookie, err := r.Cookie(SessionCookie); err == nil {
+		if err := h.service.Logout(cookie.Value); err != nil {
+			h.RespondError(w, r, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	if h.WantsJSON(r) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", "/login")
+		return
+	}
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+// RequireUser lets through only requests with a live session, adding the
+// user to the request context. Others get a 401, or a redirect to the
+// login page for page loads.
+func (h *Handler) RequireUser(next http.Handler) http.Handler {
+	return h.requireUser(next, false)
+}
+
+// RequireUserOrToken is RequireUser that also accepts API tokens sent as
+// "Authorization: Bearer <token>". Read-only tokens may only GET.
+func (h *Handler) RequireUserOrToken(next http.Handler) http.Handler {
+	return h.requireUser(next, true)
+}
+
+func (h *Handler) requireUser(next http.Handler, allowTokens bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret, ok := bearerToken(r)
+		if ok && !allowTokens {
+			// Never fall back to the session: CSRF checks pass requests
+			// carrying an API token
+			h.RespondError(w, r, "API tokens are not accepted here", http.StatusUnauthorized)
+			return
+		}
+		if ok {
+			user, token, err := h.service.AuthenticateToken(secret)
+			if err != nil {
+				if !errors.Is(err, ErrTokenNotFound) {
+					h.RespondError(w, r, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				w.Header().Set("WWW-Authenticate", `Bearer realm="abitudini"`)
+				h.RespondError(w, r, "Invalid API token", http.StatusUnauthorized)
+				return
+			}
+			if !token.CanWrite() && r.Method != http.MethodGet && r.Method != http.MethodHead {
+				h.RespondError(w, r, "API token is read-only", http.StatusForbidden)
+				return
+			}
+			ctx := shared.WithUser(r.Context(), shared.User{ID: user.ID, Username: user.Username})
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		if username := h.proxy.username(r); username != "" {
+			user, err := h.service.ProxyUser(username)
+			if err != nil {
+				status := http.StatusInternalServerError
+				if errors.Is(err, ErrInvalidAccount) {
+					status = http.StatusForbidden
+				}
+				h.RespondError(w, r, err.Error(), status)
+				return
+			}
+			ctx := shared.WithUser(r.Context(), shared.User{ID: user.ID, Username: user.Username})
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		var token string
+		if cookie, err := r.Cookie(SessionCookie); err == nil {
+			token = cookie.Value
+		}
+
+		user, err := h.service.Authenticate(token)
+		if err != nil {
+			if !errors.Is(err, ErrSessionNotFound) {
+				h.RespondError(w, r, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if strings.HasPrefix(r.URL.Path, "/api/") || h.WantsJSON(r) {
+				if r.Header.Get("HX-Request") == "true" {
+					w.Header().Set("HX-Redirect", "/login")
+				}
+				h.RespondError(w, r, "Authentication required", http.StatusUnauthorized)
+				return
+			}
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
+		ctx := shared.WithUser(r.Context(), shared.User{ID: user.ID, Username: user.Username})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequireReadToken lets through only requests with a read-only API token
+// in the token query parameter, for clients such as calendar apps that
+// cannot send headers. The account is the token's owner. Read-write tokens
+// are refused so they never end up in URLs.
+func (h *Handler) RequireReadToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, token, err := h.service.AuthenticateToken(r.URL.Query().Get("token"))
+		if err != nil {
+			if !errors.Is(err, ErrTokenNotFound) {
+				h.RespondError(w, r, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			h.RespondError(w, r, "Invalid API token", http.StatusUnauthorized)
+			return
+		}
+		if token.CanWrite() {
+			h.RespondError(w, r, "Use a read-only API token in URLs", http.StatusForbidden)
+			return
+		}
+		ctx := shared.WithUser(r.Context(), shared.User{ID: user.ID, Username: user.Username})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// TokensPage serves the page for managing API tokens
+func (h *Handler) TokensPage(w http.ResponseWriter, r *http.Request) {
+	if !h.ValidateMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	tokens, err := h.service.ListTokens(h.UserID(r))
+	if err != nil {
+		h.RespondError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.WriteHTML(w, RenderTokensPage(shared.UserFromContext(r.Context()).Username, tokens, shared.CSRFToken(r.Context())))
+}
+
+// ListTokens lists the user's API tokens
+func (h *Handler) ListTokens(w http.ResponseWriter, r *http.Request) {
+	if !h.ValidateMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	tokens, err := h.service.ListTokens(h.UserID(r))
+	if err != nil {
+		h.RespondError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if h.WantsJSON(r) {
+		h.WriteJSON(w, http.StatusOK, tokens)
+		return
+	}
+	h.WriteHTML(w, RenderTokenList(tokens))
+}
+
+// CreateToken issues an API token. Its secret is only in this response.
+func (h *Handler) CreateToken(w http.ResponseWriter, r *http.Request) {
+	if !h.ValidateMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	if err := h.ParseInput(r); err != nil {
+		h.RespondError(w, r, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	secret, token, err := h.service.CreateToken(h.UserID(r), r.FormValue("name"), r.FormValue("scope"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrInvalidToken) {
+			status = http.StatusBadRequest
+		}
+		h.RespondError(w, r, err.Error(), status)
+		return
+	}
+
+	created := NewToken{Token: *token, Secret: secret}
+	if h.WantsJSON(r) {
+		h.WriteJSON(w, http.StatusCreated, created)
+		return
+	}
+	h.WriteHTML(w, RenderNewToken(created))
+}
+
+// RevokeToken deletes an API token
+func (h *Handler) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	if !h.ValidateMethod(w, r, http.MethodDelete) {
+		return
+	}
+
+	tokenID, err := h.ExtractIntPathParam(r, "id")
+	if err != nil {
+		h.RespondError(w, r, "Invalid token ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.RevokeToken(h.UserID(r), tokenID); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrTokenNotFound) {
+			status = http.StatusNotFound
+		}
+		h.RespondError(w, r, err.Error(), status)
+		return
+	}
+
+	if h.WantsJSON(r) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// loginFailed shows the login page again with an error
+func (h *Handler) loginFailed(w http.ResponseWriter, r *http.Request, username, message string, status int) {
+	open, _ := h.service.RegistrationOpen()
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write([]byte(RenderLogin(username, message, open, h.oidc != nil, shared.CSRFToken(r.Context()))))
+}
+
+// setSession sets the session cookie. It is marked Secure when the request
+// came over HTTPS, directly or through a proxy.
+func (h *Handler) setSession(w http.ResponseWriter, r *http.Request, token string, expiresAt time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// loggedIn answers a successful login or registration with the user as
+// JSON, or by sending the browser to the home page
+func (h *Handler) loggedIn(w http.ResponseWriter, r *http.Request, status int, user *User) {
+	if h.WantsJSON(r) {
+		h.WriteJSON(w, status, user)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// callbackURL is where the provider sends the browser back to, on the host
+// the login started from
+func callbackURL(r *http.Request) string {
+	scheme := "http"
+	if isHTTPS(r) {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + oidcCallbackPath
+}
+
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
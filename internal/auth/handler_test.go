@@ -0,0 +1,393 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/epalmerini/abitudini/internal/shared"
+)
+
+func newTestHandler(t *testing.T, allowRegistration bool) *Handler {
+	t.Helper()
+	s := NewService(newMockAuthStore(), allowRegistration)
+	if _, err := s.Register("alice", "long enough"); err != nil {
+		t.Fatalf("failed to register: %v", err)
+	}
+	return NewHandler(s, nil, nil)
+}
+
+func postForm(path string, values url.Values) *http.Request {
+	req := httptest.NewRequest("POST", path, strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func sessionCookie(w *httptest.ResponseRecorder) *http.Cookie {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == SessionCookie {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestLogin_Form(t *testing.T) {
+	handler := newTestHandler(t, true)
+
+	w := httptest.NewRecorder()
+	handler.Login(w, postForm("/login", url.Values{"username": {"alice"}, "password": {"long enough"}}))
+
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/" {
+		t.Errorf("expected redirect home, got %d %s", w.Code, w.Header().Get("Location"))
+	}
+	cookie := sessionCookie(w)
+	if cookie == nil || cookie.Value == "" || !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode {
+		t.Fatalf("expected an HttpOnly session cookie, got %+v", cookie)
+	}
+	if cookie.Secure {
+		t.Error("expected no Secure flag over plain HTTP")
+	}
+}
+
+func TestLogin_SecureBehindProxy(t *testing.T) {
+	handler := newTestHandler(t, true)
+
+	req := postForm("/login", url.Values{"username": {"alice"}, "password": {"long enough"}})
+	req.Header.Set("X-Forwarded-Proto", "https")
+	w := httptest.NewRecorder()
+	handler.Login(w, req)
+
+	if cookie := sessionCookie(w); cookie == nil || !cookie.Secure {
+		t.Errorf("expected a Secure cookie, got %+v", cookie)
+	}
+}
+
+func TestLogin_WrongPassword(t *testing.T) {
+	handler := newTestHandler(t, true)
+
+	w := httptest.NewRecorder()
+	handler.Login(w, postForm("/login", url.Values{"username": {"alice"}, "password": {"nope"}}))
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401, got %d", w.Code)
+	}
+	if sessionCookie(w) != nil {
+		t.Error("expected no session cookie")
+	}
+	if !strings.Contains(w.Body.String(), "invalid username or password") || !strings.Contains(w.Body.String(), `value="alice"`) {
+		t.Errorf("expected the login form with an error, got %s", w.Body.String())
+	}
+}
+
+func TestLogin_JSON(t *testing.T) {
+	handler := newTestHandler(t, true)
+
+	req := httptest.NewRequest("POST", "/login", strings.NewReader(`{"username":"alice","password":"long enough"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	handler.Login(w, req)
+
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"username":"alice"`) {
+		t.Errorf("expected the user as JSON, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRegisterHandler_Form(t *testing.T) {
+	handler := newTestHandler(t, true)
+
+	w := httptest.NewRecorder()
+	handler.Register(w, postForm("/register", url.Values{"username": {"bob"}, "password": {"long enough"}}))
+
+	if w.Code != http.StatusSeeOther || sessionCookie(w) == nil {
+		t.Errorf("expected to be logged in and redirected, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.Register(w, postForm("/register", url.Values{"username": {"BOB"}, "password": {"long enough"}}))
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected status 409 for a taken username, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.Register(w, postForm("/register", url.Values{"username": {"carol"}, "password": {"short"}}))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for a short password, got %d", w.Code)
+	}
+}
+
+func TestRegisterHandler_Closed(t *testing.T) {
+	handler := newTestHandler(t, false)
+
+	w := httptest.NewRecorder()
+	handler.Register(w, postForm("/register", url.Values{"username": {"bob"}, "password": {"long enough"}}))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.RegisterPage(w, httptest.NewRequest("GET", "/register", nil))
+	if w.Code != http.StatusSeeOther {
+		t.Errorf("expected the register page to redirect to login, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.LoginPage(w, httptest.NewRequest("GET", "/login", nil))
+	if strings.Contains(w.Body.String(), `href="/register"`) {
+		t.Error("expected no registration link while registration is closed")
+	}
+}
+
+func TestRequireUser(t *testing.T) {
+	handler := newTestHandler(t, true)
+
+	var seen shared.User
+	protected := handler.RequireUser(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = shared.UserFromContext(r.Context())
+	}))
+
+	// Pages redirect to the login form
+	w := httptest.NewRecorder()
+	protected.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/login" {
+		t.Errorf("expected redirect to login, got %d %s", w.Code, w.Header().Get("Location"))
+	}
+
+	// API calls get a 401, and HTMX is told where to go
+	req := httptest.NewRequest("POST", "/api/habits", nil)
+	req.Header.Set("HX-Request", "true")
+	w = httptest.NewRecorder()
+	protected.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized || w.Header().Get("HX-Redirect") != "/login" {
+		t.Errorf("expected 401 with HX-Redirect, got %d %q", w.Code, w.Header().Get("HX-Redirect"))
+	}
+
+	login := httptest.NewRecorder()
+	handler.Login(login, postForm("/login", url.Values{"username": {"alice"}, "password": {"long enough"}}))
+
+	req = httptest.NewRequest("GET", "/api/habits", nil)
+	req.AddCookie(sessionCookie(login))
+	w = httptest.NewRecorder()
+	protected.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || seen.Username != "alice" || seen.ID == 0 {
+		t.Errorf("expected alice to be let through, got %d %+v", w.Code, seen)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	handler := newTestHandler(t, true)
+
+	login := httptest.NewRecorder()
+	handler.Login(login, postForm("/login", url.Values{"username": {"alice"}, "password": {"long enough"}}))
+	cookie := sessionCookie(login)
+
+	req := httptest.NewRequest("POST", "/logout", nil)
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	handler.Logout(w, req)
+
+	if w.Code != http.StatusSeeOther {
+		t.Errorf("expected redirect, got %d", w.Code)
+	}
+	if cleared := sessionCookie(w); cleared == nil || cleared.MaxAge >= 0 {
+		t.Errorf("expected the cookie to be cleared, got %+v", cleared)
+	}
+	if _, err := handler.service.Authenticate(cookie.Value); err == nil {
+		t.Error("expected the session to end")
+	}
+}
+
+func TestRequireUserOrToken(t *testing.T) {
+	handler := newTestHandler(t, true)
+	s := handler.service.(*Service)
+	user, _, _ := s.store.GetUserByUsername("alice")
+	readOnly, _, _ := s.CreateToken(user.ID, "dashboard", ScopeRead)
+	readWrite, _, _ := s.CreateToken(user.ID, "cron", ScopeWrite)
+
+	var seen shared.User
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = shared.UserFromContext(r.Context())
+	})
+
+	request := func(method, token string) *httptest.ResponseRecorder {
+		seen = shared.User{}
+		req := httptest.NewRequest(method, "/api/habits", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handler.RequireUserOrToken(next).ServeHTTP(w, req)
+		return w
+	}
+
+	if w := request("GET", readOnly); w.Code != http.StatusOK || seen.Username != "alice" {
+		t.Errorf("expected a read-only token to read, got %d %+v", w.Code, seen)
+	}
+	if w := request("POST", readOnly); w.Code != http.StatusForbidden || seen.ID != 0 {
+		t.Errorf("expected a read-only token not to write, got %d", w.Code)
+	}
+	if w := request("DELETE", readWrite); w.Code != http.StatusOK || seen.Username != "alice" {
+		t.Errorf("expected a read-write token to write, got %d", w.Code)
+	}
+	w := request("GET", "abt_unknown")
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("expected 401 with a challenge for an unknown token, got %d", w.Code)
+	}
+
+	// Routes that only take sessions refuse tokens, even next to a session
+	// cookie, since requests with a token skip the CSRF check
+	login := httptest.NewRecorder()
+	handler.Login(login, postForm("/login", url.Values{"username": {"alice"}, "password": {"long enough"}}))
+	for _, cookie := range []*http.Cookie{nil, sessionCookie(login)} {
+		seen = shared.User{}
+		req := httptest.NewRequest("POST", "/api/tokens", nil)
+		req.Header.Set("Authorization", "Bearer "+readWrite)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w = httptest.NewRecorder()
+		handler.RequireUser(next).ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized || seen.ID != 0 {
+			t.Errorf("expected tokens to be refused (session cookie: %v), got %d", cookie != nil, w.Code)
+		}
+	}
+}
+
+func TestRequireReadToken(t *testing.T) {
+	handler := newTestHandler(t, true)
+	s := handler.service.(*Service)
+	bob, err := s.Register("bob", "long enough")
+	if err != nil {
+		t.Fatalf("failed to register: %v", err)
+	}
+	alice, _, _ := s.store.GetUserByUsername("alice")
+	aliceFeed, _, _ := s.CreateToken(alice.ID, "calendar", ScopeRead)
+	bobFeed, _, _ := s.CreateToken(bob.ID, "calendar", ScopeRead)
+	readWrite, _, _ := s.CreateToken(alice.ID, "cron", ScopeWrite)
+
+	var seen shared.User
+	feed := handler.RequireReadToken(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = shared.UserFromContext(r.Context())
+	}))
+
+	request := func(target string) *httptest.ResponseRecorder {
+		seen = shared.User{}
+		w := httptest.NewRecorder()
+		feed.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		return w
+	}
+
+	// The token alone picks the account: alice's token never reads bob's
+	// habits, whatever else the URL says
+	if w := request("/api/calendar.ics?token=" + aliceFeed + "&user=bob"); w.Code != http.StatusOK || seen.ID != alice.ID {
+		t.Errorf("expected alice's feed, got %d %+v", w.Code, seen)
+	}
+	if w := request("/api/calendar.ics?token=" + bobFeed); w.Code != http.StatusOK || seen.ID != bob.ID {
+		t.Errorf("expected bob's feed, got %d %+v", w.Code, seen)
+	}
+
+	if w := request("/api/calendar.ics?token=" + readWrite); w.Code != http.StatusForbidden || seen.ID != 0 {
+		t.Errorf("expected a read-write token to be refused in a URL, got %d", w.Code)
+	}
+	if w := request("/api/calendar.ics?token=abt_unknown"); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401 for an unknown token, got %d", w.Code)
+	}
+	if w := request("/api/calendar.ics"); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401 without a token, got %d", w.Code)
+	}
+}
+
+func TestTokenHandlers(t *testing.T) {
+	handler := newTestHandler(t, true)
+	ctx := shared.WithUser(context.Background(), shared.User{ID: 1, Username: "alice"})
+
+	req := httptest.NewRequest("POST", "/api/tokens", strings.NewReader(`{"name":"cron","scope":"read"}`)).WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	handler.CreateToken(w, req)
+	if w.Code != http.StatusCreated || !strings.Contains(w.Body.String(), `"token":"abt_`) || !strings.Contains(w.Body.String(), `"scope":"read"`) {
+		t.Fatalf("expected the new token with its secret, got %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	handler.CreateToken(w, postForm("/api/tokens", url.Values{"name": {""}}).WithContext(ctx))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 without a name, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.CreateToken(w, postForm("/api/tokens", url.Values{"name": {"shortcut"}}).WithContext(ctx))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "abt_") || !strings.Contains(w.Body.String(), `hx-swap-oob`) {
+		t.Errorf("expected the secret and a new row, got %d %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/api/tokens", nil).WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	w = httptest.NewRecorder()
+	handler.ListTokens(w, req)
+	if !strings.Contains(w.Body.String(), `"name":"cron"`) || strings.Contains(w.Body.String(), "abt_") {
+		t.Errorf("expected the tokens without secrets, got %s", w.Body.String())
+	}
+
+	req = httptest.NewRequest("DELETE", "/api/tokens/1", nil).WithContext(ctx)
+	req.SetPathValue("id", "1")
+	w = httptest.NewRecorder()
+	handler.RevokeToken(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.RevokeToken(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for a revoked token, got %d", w.Code)
+	}
+}
+
+func TestRequireUser_Proxy(t *testing.T) {
+	s := NewService(newMockAuthStore(), false)
+	handler := NewHandler(s, nil, &ProxyAuth{
+		Header:  "Remote-User",
+		Trusted: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("::1/128")},
+	})
+
+	var seen shared.User
+	protected := handler.RequireUser(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = shared.UserFromContext(r.Context())
+	}))
+
+	request := func(remoteAddr, username string) *httptest.ResponseRecorder {
+		seen = shared.User{}
+		req := httptest.NewRequest("GET", "/api/habits", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("Remote-User", username)
+		w := httptest.NewRecorder()
+		protected.ServeHTTP(w, req)
+		return w
+	}
+
+	// The first request from the proxy creates the account, though
+	// registration is closed
+	if w := request("10.1.2.3:51000", "alice"); w.Code != http.StatusOK || seen.Username != "alice" || seen.ID == 0 {
+		t.Fatalf("expected alice to be provisioned, got %d %+v", w.Code, seen)
+	}
+	first := seen.ID
+	if w := request("[::1]:51000", "Alice"); w.Code != http.StatusOK || seen.ID != first {
+		t.Errorf("expected the same account again, got %d %+v", w.Code, seen)
+	}
+
+	// Anyone else sending the header is ignored
+	if w := request("192.168.1.5:51000", "alice"); w.Code != http.StatusUnauthorized || seen.ID != 0 {
+		t.Errorf("expected an untrusted header to be ignored, got %d %+v", w.Code, seen)
+	}
+	if w := request("10.1.2.3:51000", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected a trusted request without the header to need a login, got %d", w.Code)
+	}
+	if w := request("10.1.2.3:51000", "not valid"); w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 for an unusable username, got %d", w.Code)
+	}
+}
@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+)
+
+func TestVerifyJWTSignature_ES256(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	public, err := jwk{
+		Kty: "EC",
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+		Y:   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+	}.publicKey()
+	if err != nil {
+		t.Fatalf("failed to decode key: %v", err)
+	}
+
+	signed := "header.payload"
+	digest := sha256.Sum256([]byte(signed))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	signature := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+
+	if err := verifyJWTSignature("ES256", public, signed, signature); err != nil {
+		t.Errorf("expected a valid signature, got %v", err)
+	}
+	if err := verifyJWTSignature("ES256", public, "header.tampered", signature); err == nil {
+		t.Error("expected a tampered payload to be rejected")
+	}
+	if err := verifyJWTSignature("RS256", public, signed, signature); err == nil {
+		t.Error("expected an algorithm and key type mismatch to be rejected")
+	}
+}
+
+func TestJWKPublicKey_Invalid(t *testing.T) {
+	for name, k := range map[string]jwk{
+		"off curve":   {Kty: "EC", Crv: "P-256", X: "AQ", Y: "AQ"},
+		"other curve": {Kty: "EC", Crv: "P-521", X: "AQ", Y: "AQ"},
+		"no modulus":  {Kty: "RSA", E: "AQAB"},
+		"oct":         {Kty: "oct"},
+	} {
+		if _, err := k.publicKey(); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
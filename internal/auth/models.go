@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrInvalidCredentials is returned for an unknown username or a wrong
+	// password, without telling which
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	// ErrUsernameTaken is returned when registering an existing username
+	ErrUsernameTaken = errors.New("username is already taken")
+	// ErrRegistrationClosed is returned when registration is disabled and
+	// an account already exists
+	ErrRegistrationClosed = errors.New("registration is closed")
+	// ErrInvalidAccount wraps the reason a username or password was
+	// rejected at registration
+	ErrInvalidAccount = errors.New("invalid account")
+	// ErrSessionNotFound is returned for a missing or expired session
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrTokenNotFound is returned for an unknown or revoked API token
+	ErrTokenNotFound = errors.New("API token not found")
+	// ErrInvalidToken wraps the reason a new API token was rejected
+	ErrInvalidToken = errors.New("invalid API token")
+	// ErrIdentityConflict is returned when a new single sign-on identity
+	// names an account that has a password or another identity and did not
+	// confirm the link
+	ErrIdentityConflict = errors.New("an account with this username already exists; log in to it, then use single sign-on again to link them")
+)
+
+// SessionCookie names the cookie holding the session token
+const SessionCookie = "abitudini_session"
+
+// sessionDuration is how long a login lasts
+const sessionDuration = 30 * 24 * time.Hour
+
+// Limits on account fields
+const (
+	maxUsernameLength  = 64
+	minPasswordLength  = 8
+	maxPasswordLength  = 1024
+	maxTokenNameLength = 100
+)
+
+// tokenPrefix starts every API token so leaked ones are easy to spot
+const tokenPrefix = "abt_"
+
+// API token scopes. Write tokens may also read.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+type User struct {
+	ID        int       `json:"id"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Token is a personal API token. The token itself is only shown once, when
+// it is created.
+type Token struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Scope      string     `json:"scope"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// CanWrite reports whether the token may change data
+func (t Token) CanWrite() bool {
+	return t.Scope == ScopeWrite
+}
+
+// NewToken is a just-created API token together with its secret
+type NewToken struct {
+	Token
+	Secret string `json:"token"`
+}
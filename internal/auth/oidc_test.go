@@ -0,0 +1,389 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockOIDCProvider is a minimal OpenID provider. Its authorize endpoint
+// approves every request at once, logging in as the configured user.
+type mockOIDCProvider struct {
+	server      *httptest.Server
+	key         *rsa.PrivateKey
+	clientID    string
+	secret      string
+	authMethods []string
+	// claims are added to, or override, the ID token's standard claims
+	claims map[string]any
+
+	mu     sync.Mutex
+	grants map[string]url.Values
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	m := &mockOIDCProvider{
+		key:      key,
+		clientID: "abitudini",
+		secret:   "s3cret",
+		claims:   map[string]any{"sub": "user-1", "preferred_username": "alice"},
+		grants:   make(map[string]url.Values),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                m.server.URL,
+			"authorization_endpoint":                m.server.URL + "/authorize",
+			"token_endpoint":                        m.server.URL + "/token",
+			"jwks_uri":                              m.server.URL + "/jwks",
+			"token_endpoint_auth_methods_supported": m.authMethods,
+		})
+	})
+	mux.HandleFunc("GET /authorize", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		code, _ := randomToken()
+		m.mu.Lock()
+		m.grants[code] = query
+		m.mu.Unlock()
+		http.Redirect(w, r, query.Get("redirect_uri")+"?"+url.Values{"code": {code}, "state": {query.Get("state")}}.Encode(), http.StatusFound)
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		clientID, secret, ok := r.BasicAuth()
+		if !ok {
+			clientID, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+		}
+		m.mu.Lock()
+		grant, found := m.grants[r.PostForm.Get("code")]
+		delete(m.grants, r.PostForm.Get("code"))
+		m.mu.Unlock()
+
+		challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		switch {
+		case clientID != m.clientID || secret != m.secret:
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+		case !found || grant.Get("redirect_uri") != r.PostForm.Get("redirect_uri") ||
+			grant.Get("code_challenge") != base64.RawURLEncoding.EncodeToString(challenge[:]):
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		default:
+			json.NewEncoder(w).Encode(map[string]string{
+				"access_token": "unused",
+				"token_type":   "Bearer",
+				"id_token":     m.idToken(t, grant.Get("nonce")),
+			})
+		}
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "key-1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+		}}})
+	})
+
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+func (m *mockOIDCProvider) config() OIDCConfig {
+	return OIDCConfig{Issuer: m.server.URL, ClientID: m.clientID, ClientSecret: m.secret}
+}
+
+// idToken signs an ID token with the provider's key
+func (m *mockOIDCProvider) idToken(t *testing.T, nonce string) string {
+	claims := map[string]any{
+		"iss":   m.server.URL,
+		"aud":   m.clientID,
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"iat":   time.Now().Unix(),
+		"nonce": nonce,
+	}
+	for k, v := range m.claims {
+		claims[k] = v
+	}
+	return signRS256(t, m.key, "key-1", claims)
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// authorize follows the login redirect to the mock provider and returns
+// the code and state it sends back
+func authorize(t *testing.T, authURL string) url.Values {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("failed to authorize: %v", err)
+	}
+	resp.Body.Close()
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("invalid redirect: %v", err)
+	}
+	return location.Query()
+}
+
+func TestOIDCProvider_CodeFlow(t *testing.T) {
+	mock := newMockOIDCProvider(t)
+	provider := NewOIDCProvider(mock.config())
+	ctx := context.Background()
+	redirect := "http://abitudini.test/login/oidc/callback"
+
+	authURL, err := provider.AuthCodeURL(ctx, redirect, "state-1", "nonce-1", "verifier-1")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	query, _ := url.Parse(authURL)
+	if q := query.Query(); q.Get("code_challenge_method") != "S256" || q.Get("client_id") != "abitudini" || !strings.Contains(q.Get("scope"), "openid") {
+		t.Errorf("unexpected authorization URL %s", authURL)
+	}
+
+	callback := authorize(t, authURL)
+	if callback.Get("state") != "state-1" {
+		t.Errorf("expected the state back, got %q", callback.Get("state"))
+	}
+
+	identity, err := provider.Exchange(ctx, redirect, callback.Get("code"), "verifier-1", "nonce-1")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if identity.Issuer != mock.server.URL || identity.Subject != "user-1" || identity.Username != "alice" {
+		t.Errorf("unexpected identity %+v", identity)
+	}
+}
+
+func TestOIDCProvider_ClientSecretPost(t *testing.T) {
+	mock := newMockOIDCProvider(t)
+	mock.authMethods = []string{"client_secret_post"}
+	mock.claims["email"] = "alice@example.com"
+	cfg := mock.config()
+	cfg.UsernameClaim = "email"
+	provider := NewOIDCProvider(cfg)
+	ctx := context.Background()
+
+	authURL, _ := provider.AuthCodeURL(ctx, "http://abitudini.test/cb", "s", "n", "v")
+	identity, err := provider.Exchange(ctx, "http://abitudini.test/cb", authorize(t, authURL).Get("code"), "v", "n")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if identity.Username != "alice@example.com" {
+		t.Errorf("expected the email claim as username, got %q", identity.Username)
+	}
+}
+
+func TestOIDCProvider_WrongVerifier(t *testing.T) {
+	mock := newMockOIDCProvider(t)
+	provider := NewOIDCProvider(mock.config())
+	ctx := context.Background()
+
+	authURL, _ := provider.AuthCodeURL(ctx, "http://abitudini.test/cb", "s", "n", "right")
+	if _, err := provider.Exchange(ctx, "http://abitudini.test/cb", authorize(t, authURL).Get("code"), "wrong", "n"); err == nil {
+		t.Error("expected the provider to refuse a wrong PKCE verifier")
+	}
+}
+
+func TestOIDCProvider_Verify(t *testing.T) {
+	mock := newMockOIDCProvider(t)
+	provider := NewOIDCProvider(mock.config())
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	valid := func() map[string]any {
+		return map[string]any{
+			"iss":                mock.server.URL,
+			"aud":                []any{"other", "abitudini"},
+			"sub":                "user-1",
+			"preferred_username": "alice",
+			"exp":                time.Now().Add(time.Hour).Unix(),
+			"iat":                time.Now().Unix(),
+			"nonce":              "n",
+		}
+	}
+	with := func(key string, value any) map[string]any {
+		claims := valid()
+		if value == nil {
+			delete(claims, key)
+		} else {
+			claims[key] = value
+		}
+		return claims
+	}
+
+	if _, err := provider.verify(context.Background(), signRS256(t, mock.key, "key-1", valid()), "n"); err != nil {
+		t.Fatalf("expected a valid token, got %v", err)
+	}
+
+	tests := map[string]string{
+		"wrong issuer":   signRS256(t, mock.key, "key-1", with("iss", "https://evil.example")),
+		"wrong audience": signRS256(t, mock.key, "key-1", with("aud", "other")),
+		"other azp":      signRS256(t, mock.key, "key-1", with("azp", "other")),
+		"expired":        signRS256(t, mock.key, "key-1", with("exp", time.Now().Add(-time.Hour).Unix())),
+		"from future":    signRS256(t, mock.key, "key-1", with("iat", time.Now().Add(time.Hour).Unix())),
+		"wrong nonce":    signRS256(t, mock.key, "key-1", with("nonce", "replayed")),
+		"no subject":     signRS256(t, mock.key, "key-1", with("sub", nil)),
+		"no username":    signRS256(t, mock.key, "key-1", with("preferred_username", nil)),
+		"other key":      signRS256(t, otherKey, "key-1", valid()),
+		"unknown kid":    signRS256(t, otherKey, "key-2", valid()),
+		"garbage":        "not.a.jwt",
+	}
+	payload, _ := json.Marshal(valid())
+	tests["alg none"] = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"key-1"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString(payload) + "."
+
+	for name, token := range tests {
+		if _, err := provider.verify(context.Background(), token, "n"); err == nil {
+			t.Errorf("%s: expected the token to be rejected", name)
+		}
+	}
+}
+
+func TestOIDCLogin_EndToEnd(t *testing.T) {
+	mock := newMockOIDCProvider(t)
+	s := NewService(newMockAuthStore(), false)
+	handler := NewHandler(s, NewOIDCProvider(mock.config()), nil)
+
+	// The login page offers single sign-on
+	w := httptest.NewRecorder()
+	handler.LoginPage(w, httptest.NewRequest("GET", "/login", nil))
+	if !strings.Contains(w.Body.String(), `href="/login/oidc"`) {
+		t.Error("expected a single sign-on link")
+	}
+
+	w = httptest.NewRecorder()
+	handler.OIDCLogin(w, httptest.NewRequest("GET", "http://abitudini.test/login/oidc", nil))
+	if w.Code != http.StatusFound || !strings.HasPrefix(w.Header().Get("Location"), mock.server.URL+"/authorize") {
+		t.Fatalf("expected a redirect to the provider, got %d %s", w.Code, w.Header().Get("Location"))
+	}
+	var flow *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == oidcCookie {
+			flow = c
+		}
+	}
+	if flow == nil || !flow.HttpOnly {
+		t.Fatalf("expected an HttpOnly flow cookie, got %+v", flow)
+	}
+	callback := authorize(t, w.Header().Get("Location"))
+
+	// A forged state is refused
+	req := httptest.NewRequest("GET", "http://abitudini.test/login/oidc/callback?code="+callback.Get("code")+"&state=forged", nil)
+	req.AddCookie(flow)
+	w = httptest.NewRecorder()
+	handler.OIDCCallback(w, req)
+	if w.Code != http.StatusBadRequest || sessionCookie(w) != nil {
+		t.Errorf("expected a forged state to be refused, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "http://abitudini.test/login/oidc/callback?"+callback.Encode(), nil)
+	req.AddCookie(flow)
+	w = httptest.NewRecorder()
+	handler.OIDCCallback(w, req)
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/" {
+		t.Fatalf("expected to be logged in, got %d %s", w.Code, w.Body.String())
+	}
+
+	// The first login created the account, though registration is closed
+	user, err := s.Authenticate(sessionCookie(w).Value)
+	if err != nil || user.Username != "alice" {
+		t.Errorf("expected a session for alice, got %+v, %v", user, err)
+	}
+}
+
+func TestOIDCCallback_PasswordAccount(t *testing.T) {
+	mock := newMockOIDCProvider(t)
+	s := NewService(newMockAuthStore(), true)
+	if _, err := s.Register("alice", "long enough"); err != nil {
+		t.Fatalf("failed to register: %v", err)
+	}
+	handler := NewHandler(s, NewOIDCProvider(mock.config()), nil)
+
+	callback := func(session *http.Cookie) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.OIDCLogin(w, httptest.NewRequest("GET", "http://abitudini.test/login/oidc", nil))
+		flow := w.Result().Cookies()[0]
+		query := authorize(t, w.Header().Get("Location"))
+
+		req := httptest.NewRequest("GET", "http://abitudini.test/login/oidc/callback?"+query.Encode(), nil)
+		req.AddCookie(flow)
+		if session != nil {
+			req.AddCookie(session)
+		}
+		w = httptest.NewRecorder()
+		handler.OIDCCallback(w, req)
+		return w
+	}
+
+	// The provider says "alice", but that does not make it the local alice
+	if w := callback(nil); w.Code != http.StatusConflict || sessionCookie(w) != nil {
+		t.Fatalf("expected the login to be refused, got %d", w.Code)
+	}
+
+	// Logged in with the password, alice links the identity
+	login := httptest.NewRecorder()
+	handler.Login(login, postForm("/login", url.Values{"username": {"alice"}, "password": {"long enough"}}))
+	if w := callback(sessionCookie(login)); w.Code != http.StatusSeeOther {
+		t.Fatalf("expected the identity to be linked, got %d %s", w.Code, w.Body.String())
+	}
+	w := callback(nil)
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("expected the linked identity to log in, got %d", w.Code)
+	}
+	if user, err := s.Authenticate(sessionCookie(w).Value); err != nil || user.Username != "alice" {
+		t.Errorf("expected a session for alice, got %+v, %v", user, err)
+	}
+}
+
+func TestOIDCCallback_ProviderError(t *testing.T) {
+	mock := newMockOIDCProvider(t)
+	handler := NewHandler(NewService(newMockAuthStore(), true), NewOIDCProvider(mock.config()), nil)
+
+	w := httptest.NewRecorder()
+	handler.OIDCCallback(w, httptest.NewRequest("GET", "/login/oidc/callback?error=access_denied&error_description=Not+allowed", nil))
+	if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), "Not allowed") {
+		t.Errorf("expected the provider's error on the login page, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestOIDCLogin_Disabled(t *testing.T) {
+	handler := NewHandler(NewService(newMockAuthStore(), true), nil, nil)
+
+	w := httptest.NewRecorder()
+	handler.OIDCLogin(w, httptest.NewRequest("GET", "/login/oidc", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"encoding/hex"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// Full-strength hashing makes every registration and login slow
+	hashIterations = 1000
+	os.Exit(m.Run())
+}
+
+func TestPBKDF2SHA256_Vector(t *testing.T) {
+	// RFC 7914 section 11
+	key := pbkdf2SHA256([]byte("passwd"), []byte("salt"), 1)
+	want := "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc"
+	if got := hex.EncodeToString(key); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestHashAndCheckPassword(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+	if !strings.HasPrefix(hash, "pbkdf2-sha256$1000$") {
+		t.Errorf("unexpected hash format %s", hash)
+	}
+
+	if ok, err := CheckPassword(hash, "correct horse"); !ok || err != nil {
+		t.Errorf("expected password to match, got %v, %v", ok, err)
+	}
+	if ok, err := CheckPassword(hash, "battery staple"); ok || err != nil {
+		t.Errorf("expected wrong password not to match, got %v, %v", ok, err)
+	}
+
+	other, _ := HashPassword("correct horse")
+	if other == hash {
+		t.Error("expected salted hashes to differ")
+	}
+}
+
+func TestCheckPassword_Malformed(t *testing.T) {
+	for _, hash := range []string{"", "bcrypt$10$x$y", "pbkdf2-sha256$0$c2FsdA$a2V5", "pbkdf2-sha256$10$!!$a2V5"} {
+		if _, err := CheckPassword(hash, "password"); err == nil {
+			t.Errorf("expected error for hash %q", hash)
+		}
+	}
+}
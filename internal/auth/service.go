@@ -0,0 +1,337 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// StoreAdapter defines the interface for data access
+type StoreAdapter interface {
+	CreateUser(username, passwordHash string) (*User, error)
+	GetUserByUsername(username string) (*User, string, error)
+	CountUsers() (int, error)
+	CreateSession(tokenHash string, userID int, expiresAt time.Time) error
+	GetSessionUser(tokenHash string, now time.Time) (*User, error)
+	DeleteSession(tokenHash string) error
+	DeleteExpiredSessions(now time.Time) error
+	CreateToken(userID int, name, scope, tokenHash string) (*Token, error)
+	ListTokens(userID int) ([]Token, error)
+	DeleteToken(userID, tokenID int) error
+	GetTokenUser(tokenHash string) (*User, *Token, error)
+	TouchToken(tokenID int, now time.Time) error
+	GetIdentityUser(issuer, subject string) (*User, error)
+	LinkIdentity(issuer, subject string, userID int) error
+	HasIdentity(userID int) (bool, error)
+}
+
+type Service struct {
+	store             StoreAdapter
+	allowRegistration bool
+	now               func() time.Time
+}
+
+// NewService creates the account service. When allowRegistration is false
+// only the first account can register.
+func NewService(store StoreAdapter, allowRegistration bool) *Service {
+	return &Service{store: store, allowRegistration: allowRegistration, now: time.Now}
+}
+
+// RegistrationOpen reports whether new accounts can be created
+func (s *Service) RegistrationOpen() (bool, error) {
+	if s.allowRegistration {
+		return true, nil
+	}
+	users, err := s.store.CountUsers()
+	if err != nil {
+		return false, err
+	}
+	return users == 0, nil
+}
+
+// Register creates an account after validating the username and password
+func (s *Service) Register(username, password string) (*User, error) {
+	open, err := s.RegistrationOpen()
+	if err != nil {
+		return nil, err
+	}
+	if !open {
+		return nil, ErrRegistrationClosed
+	}
+
+	username = strings.TrimSpace(username)
+	if err := validateUsername(username); err != nil {
+		return nil, err
+	}
+	if err := validatePassword(password); err != nil {
+		return nil, err
+	}
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	return s.store.CreateUser(username, hash)
+}
+
+// dummyHash is checked against when a username does not exist, so unknown
+// usernames take as long to reject as wrong passwords
+var dummyHash = sync.OnceValue(func() string {
+	hash, _ := HashPassword("abitudini-dummy-password")
+	return hash
+})
+
+// Login checks the credentials and starts a session. It returns the session
+// token, which only the client keeps, and when it expires.
+func (s *Service) Login(username, password string) (string, time.Time, error) {
+	user, hash, err := s.store.GetUserByUsername(strings.TrimSpace(username))
+	if errors.Is(err, ErrInvalidCredentials) {
+		CheckPassword(dummyHash(), password)
+		return "", time.Time{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	// Accounts created through single sign-on have no password
+	if hash == "" {
+		CheckPassword(dummyHash(), password)
+		return "", time.Time{}, ErrInvalidCredentials
+	}
+
+	ok, err := CheckPassword(hash, password)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if !ok {
+		return "", time.Time{}, ErrInvalidCredentials
+	}
+
+	return s.StartSession(user.ID)
+}
+
+// LoginIdentity starts a session for the account linked to an identity
+// asserted by the single sign-on provider. current is the account the
+// browser is already logged in to, or nil.
+func (s *Service) LoginIdentity(identity Identity, current *User) (string, time.Time, error) {
+	user, err := s.store.GetIdentityUser(identity.Issuer, identity.Subject)
+	if errors.Is(err, ErrInvalidCredentials) {
+		if user, err = s.identityAccount(identity.Username, current); err != nil {
+			return "", time.Time{}, err
+		}
+		err = s.store.LinkIdentity(identity.Issuer, identity.Subject, user.ID)
+	}
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return s.StartSession(user.ID)
+}
+
+// identityAccount picks the account a new identity is linked to: the one
+// with the same username, created without a password if there is none.
+// Providers often let users pick their username, so an account with a
+// password or an identity already linked is only linked when current shows
+// its owner is logged in.
+func (s *Service) identityAccount(username string, current *User) (*User, error) {
+	user, hash, err := s.store.GetUserByUsername(strings.TrimSpace(username))
+	switch {
+	case errors.Is(err, ErrInvalidCredentials):
+		return s.provision(username)
+	case err != nil:
+		return nil, err
+	case current != nil && current.ID == user.ID:
+		return user, nil
+	case hash != "":
+		return nil, ErrIdentityConflict
+	}
+
+	linked, err := s.store.HasIdentity(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if linked {
+		return nil, ErrIdentityConflict
+	}
+	return user, nil
+}
+
+// ProxyUser returns the account an authenticating proxy named, creating
+// it on first sight
+func (s *Service) ProxyUser(username string) (*User, error) {
+	return s.provision(username)
+}
+
+// provision returns the account with the given username, creating it
+// without a password when there is none. Registration being closed does
+// not apply: whoever vouches for the username decides who gets in.
+func (s *Service) provision(username string) (*User, error) {
+	username = strings.TrimSpace(username)
+	if err := validateUsername(username); err != nil {
+		return nil, err
+	}
+
+	user, _, err := s.store.GetUserByUsername(username)
+	if !errors.Is(err, ErrInvalidCredentials) {
+		return user, err
+	}
+	user, err = s.store.CreateUser(username, "")
+	if errors.Is(err, ErrUsernameTaken) {
+		// Another request created it first
+		user, _, err = s.store.GetUserByUsername(username)
+	}
+	return user, err
+}
+
+// StartSession creates a session for the user and returns its token and
+// expiry. Expired sessions are cleaned up on the way.
+func (s *Service) StartSession(userID int) (string, time.Time, error) {
+	now := s.now()
+	if err := s.store.DeleteExpiredSessions(now); err != nil {
+		return "", time.Time{}, err
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to generate session token: %w", err)
+	}
+
+	expiresAt := now.Add(sessionDuration)
+	if err := s.store.CreateSession(hashToken(token), userID, expiresAt); err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// Authenticate returns the user of a live session
+func (s *Service) Authenticate(token string) (*User, error) {
+	if token == "" {
+		return nil, ErrSessionNotFound
+	}
+	return s.store.GetSessionUser(hashToken(token), s.now())
+}
+
+// Logout ends a session
+func (s *Service) Logout(token string) error {
+	if token == "" {
+		return nil
+	}
+	return s.store.DeleteSession(hashToken(token))
+}
+
+// CreateToken issues an API token for the user. The returned secret is
+// the only copy; just its hash is stored. An empty scope means write.
+func (s *Service) CreateToken(userID int, name, scope string) (string, *Token, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil, fmt.Errorf("%w: name is required", ErrInvalidToken)
+	}
+	if len([]rune(name)) > maxTokenNameLength {
+		return "", nil, fmt.Errorf("%w: name must be at most %d characters", ErrInvalidToken, maxTokenNameLength)
+	}
+	if scope == "" {
+		scope = ScopeWrite
+	}
+	if scope != ScopeRead && scope != ScopeWrite {
+		return "", nil, fmt.Errorf("%w: scope must be %q or %q", ErrInvalidToken, ScopeRead, ScopeWrite)
+	}
+
+	secret, err := randomToken()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate API token: %w", err)
+	}
+	secret = tokenPrefix + secret
+
+	token, err := s.store.CreateToken(userID, name, scope, hashToken(secret))
+	if err != nil {
+		return "", nil, err
+	}
+	return secret, token, nil
+}
+
+// ListTokens returns the user's API tokens without their secrets
+func (s *Service) ListTokens(userID int) ([]Token, error) {
+	return s.store.ListTokens(userID)
+}
+
+// RevokeToken deletes one of the user's API tokens
+func (s *Service) RevokeToken(userID, tokenID int) error {
+	return s.store.DeleteToken(userID, tokenID)
+}
+
+// AuthenticateToken returns the owner of an API token and the token, and
+// records that it was used
+func (s *Service) AuthenticateToken(secret string) (*User, *Token, error) {
+	if !strings.HasPrefix(secret, tokenPrefix) {
+		return nil, nil, ErrTokenNotFound
+	}
+	user, token, err := s.store.GetTokenUser(hashToken(secret))
+	if err != nil {
+		return nil, nil, err
+	}
+	now := s.now()
+	if err := s.store.TouchToken(token.ID, now); err != nil {
+		return nil, nil, err
+	}
+	token.LastUsedAt = &now
+	return user, token, nil
+}
+
+// ValidToken reports whether secret is a live API token, without recording
+// its use
+func (s *Service) ValidToken(secret string) bool {
+	if !strings.HasPrefix(secret, tokenPrefix) {
+		return false
+	}
+	_, _, err := s.store.GetTokenUser(hashToken(secret))
+	return err == nil
+}
+
+// randomToken returns 32 random bytes, base64url encoded
+func randomToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// hashToken is how session and API tokens are stored, so a leaked database does
+// not hand out live sessions
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// validateUsername allows letters, digits and . _ - @
+func validateUsername(username string) error {
+	if username == "" {
+		return fmt.Errorf("%w: username is required", ErrInvalidAccount)
+	}
+	if len([]rune(username)) > maxUsernameLength {
+		return fmt.Errorf("%w: username must be at most %d characters", ErrInvalidAccount, maxUsernameLength)
+	}
+	for _, r := range username {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("._-@", r) {
+			return fmt.Errorf("%w: username may only contain letters, digits and . _ - @", ErrInvalidAccount)
+		}
+	}
+	return nil
+}
+
+func validatePassword(password string) error {
+	if len([]rune(password)) < minPasswordLength {
+		return fmt.Errorf("%w: password must be at least %d characters", ErrInvalidAccount, minPasswordLength)
+	}
+	if len(password) > maxPasswordLength {
+		return fmt.Errorf("%w: password must be at most %d bytes", ErrInvalidAccount, maxPasswordLength)
+	}
+	return nil
+}
@@ -0,0 +1,411 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type mockAuthStore struct {
+	users    map[string]*User
+	hashes   map[string]string
+	sessions map[string]int
+	expiries map[string]time.Time
+	tokens   map[string]*mockToken
+	links    map[string]int
+}
+
+type mockToken struct {
+	userID int
+	token  Token
+}
+
+func newMockAuthStore() *mockAuthStore {
+	return &mockAuthStore{
+		users:    make(map[string]*User),
+		hashes:   make(map[string]string),
+		sessions: make(map[string]int),
+		expiries: make(map[string]time.Time),
+		tokens:   make(map[string]*mockToken),
+		links:    make(map[string]int),
+	}
+}
+
+func (m *mockAuthStore) CreateUser(username, passwordHash string) (*User, error) {
+	key := strings.ToLower(username)
+	if _, ok := m.users[key]; ok {
+		return nil, ErrUsernameTaken
+	}
+	user := &User{ID: len(m.users) + 1, Username: username}
+	m.users[key] = user
+	m.hashes[key] = passwordHash
+	return user, nil
+}
+
+func (m *mockAuthStore) GetUserByUsername(username string) (*User, string, error) {
+	key := strings.ToLower(username)
+	user, ok := m.users[key]
+	if !ok {
+		return nil, "", ErrInvalidCredentials
+	}
+	return user, m.hashes[key], nil
+}
+
+func (m *mockAuthStore) CountUsers() (int, error) {
+	return len(m.users), nil
+}
+
+func (m *mockAuthStore) CreateSession(tokenHash string, userID int, expiresAt time.Time) error {
+	m.sessions[tokenHash] = userID
+	m.expiries[tokenHash] = expiresAt
+	return nil
+}
+
+func (m *mockAuthStore) GetSessionUser(tokenHash string, now time.Time) (*User, error) {
+	userID, ok := m.sessions[tokenHash]
+	if !ok || !m.expiries[tokenHash].After(now) {
+		return nil, ErrSessionNotFound
+	}
+	for _, user := range m.users {
+		if user.ID == userID {
+			return user, nil
+		}
+	}
+	return nil, ErrSessionNotFound
+}
+
+func (m *mockAuthStore) DeleteSession(tokenHash string) error {
+	delete(m.sessions, tokenHash)
+	return nil
+}
+
+func (m *mockAuthStore) DeleteExpiredSessions(now time.Time) error {
+	for tokenHash, expiresAt := range m.expiries {
+		if !expiresAt.After(now) {
+			delete(m.sessions, tokenHash)
+		}
+	}
+	return nil
+}
+
+func (m *mockAuthStore) CreateToken(userID int, name, scope, tokenHash string) (*Token, error) {
+	entry := &mockToken{userID: userID, token: Token{ID: len(m.tokens) + 1, Name: name, Scope: scope}}
+	m.tokens[tokenHash] = entry
+	token := entry.token
+	return &token, nil
+}
+
+func (m *mockAuthStore) ListTokens(userID int) ([]Token, error) {
+	tokens := []Token{}
+	for _, entry := range m.tokens {
+		if entry.userID == userID {
+			tokens = append(tokens, entry.token)
+		}
+	}
+	return tokens, nil
+}
+
+func (m *mockAuthStore) DeleteToken(userID, tokenID int) error {
+	for tokenHash, entry := range m.tokens {
+		if entry.userID == userID && entry.token.ID == tokenID {
+			delete(m.tokens, tokenHash)
+			return nil
+		}
+	}
+	return ErrTokenNotFound
+}
+
+func (m *mockAuthStore) GetTokenUser(tokenHash string) (*User, *Token, error) {
+	entry, ok := m.tokens[tokenHash]
+	if !ok {
+		return nil, nil, ErrTokenNotFound
+	}
+	for _, user := range m.users {
+		if user.ID == entry.userID {
+			token := entry.token
+			return user, &token, nil
+		}
+	}
+	return nil, nil, ErrTokenNotFound
+}
+
+func (m *mockAuthStore) TouchToken(tokenID int, now time.Time) error {
+	for _, entry := range m.tokens {
+		if entry.token.ID == tokenID {
+			entry.token.LastUsedAt = &now
+		}
+	}
+	return nil
+}
+
+func (m *mockAuthStore) GetIdentityUser(issuer, subject string) (*User, error) {
+	userID, ok := m.links[issuer+" "+subject]
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+	for _, user := range m.users {
+		if user.ID == userID {
+			return user, nil
+		}
+	}
+	return nil, ErrInvalidCredentials
+}
+
+func (m *mockAuthStore) LinkIdentity(issuer, subject string, userID int) error {
+	m.links[issuer+" "+subject] = userID
+	return nil
+}
+
+func (m *mockAuthStore) HasIdentity(userID int) (bool, error) {
+	for _, id := range m.links {
+		if id == userID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestRegister_Validation(t *testing.T) {
+	s := NewService(newMockAuthStore(), true)
+
+	tests := map[string]struct{ username, password string }{
+		"empty username": {"  ", "long enough"},
+		"long username":  {strings.Repeat("a", 65), "long enough"},
+		"spaces":         {"al ice", "long enough"},
+		"short password": {"alice", "short"},
+		"huge password":  {"alice", strings.Repeat("x", 1025)},
+	}
+	for name, tt := range tests {
+		if _, err := s.Register(tt.username, tt.password); !errors.Is(err, ErrInvalidAccount) {
+			t.Errorf("%s: expected ErrInvalidAccount, got %v", name, err)
+		}
+	}
+
+	user, err := s.Register(" alice@example.com ", "long enough")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if user.Username != "alice@example.com" {
+		t.Errorf("expected trimmed username, got %q", user.Username)
+	}
+}
+
+func TestRegister_Closed(t *testing.T) {
+	s := NewService(newMockAuthStore(), false)
+
+	if open, _ := s.RegistrationOpen(); !open {
+		t.Error("expected the first account to be able to register")
+	}
+	if _, err := s.Register("alice", "long enough"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if open, _ := s.RegistrationOpen(); open {
+		t.Error("expected registration to close after the first account")
+	}
+	if _, err := s.Register("bob", "long enough"); !errors.Is(err, ErrRegistrationClosed) {
+		t.Errorf("expected ErrRegistrationClosed, got %v", err)
+	}
+}
+
+func TestLoginAndLogout(t *testing.T) {
+	store := newMockAuthStore()
+	s := NewService(store, true)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	if _, err := s.Register("alice", "long enough"); err != nil {
+		t.Fatalf("failed to register: %v", err)
+	}
+
+	if _, _, err := s.Login("alice", "wrong password"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expected ErrInvalidCredentials for a wrong password, got %v", err)
+	}
+	if _, _, err := s.Login("nobody", "long enough"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expected ErrInvalidCredentials for an unknown user, got %v", err)
+	}
+
+	token, expiresAt, err := s.Login("Alice", "long enough")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !expiresAt.Equal(now.Add(sessionDuration)) {
+		t.Errorf("expected session to last %s, expires %s", sessionDuration, expiresAt)
+	}
+	if _, ok := store.sessions[token]; ok {
+		t.Error("expected the token to be stored hashed")
+	}
+
+	user, err := s.Authenticate(token)
+	if err != nil || user.Username != "alice" {
+		t.Errorf("expected alice, got %+v, %v", user, err)
+	}
+
+	if err := s.Logout(token); err != nil {
+		t.Fatalf("failed to log out: %v", err)
+	}
+	if _, err := s.Authenticate(token); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected session to end, got %v", err)
+	}
+}
+
+func TestAuthenticate_Expired(t *testing.T) {
+	s := NewService(newMockAuthStore(), true)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	user, _ := s.Register("alice", "long enough")
+	token, _, err := s.StartSession(user.ID)
+	if err != nil {
+		t.Fatalf("failed to start session: %v", err)
+	}
+
+	now = now.Add(sessionDuration)
+	if _, err := s.Authenticate(token); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected expired session to be rejected, got %v", err)
+	}
+	if _, err := s.Authenticate(""); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected empty token to be rejected, got %v", err)
+	}
+}
+
+func TestCreateToken(t *testing.T) {
+	store := newMockAuthStore()
+	s := NewService(store, true)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	user, _ := s.Register("alice", "long enough")
+
+	for name, tt := range map[string]struct{ name, scope string }{
+		"no name":   {"  ", ""},
+		"long name": {strings.Repeat("n", 101), ""},
+		"bad scope": {"cron", "admin"},
+	} {
+		if _, _, err := s.CreateToken(user.ID, tt.name, tt.scope); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: expected ErrInvalidToken, got %v", name, err)
+		}
+	}
+
+	secret, token, err := s.CreateToken(user.ID, " cron ", "")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !strings.HasPrefix(secret, "abt_") || token.Name != "cron" || token.Scope != ScopeWrite {
+		t.Errorf("unexpected token %q %+v", secret, token)
+	}
+	if _, ok := store.tokens[secret]; ok {
+		t.Error("expected the token to be stored hashed")
+	}
+
+	if !s.ValidToken(secret) || s.ValidToken("abt_unknown") || s.ValidToken("not-a-token") {
+		t.Error("expected only the created token to be valid")
+	}
+	if tokens, _ := s.ListTokens(user.ID); len(tokens) != 1 || tokens[0].LastUsedAt != nil {
+		t.Errorf("expected checking a token not to record its use, got %+v", tokens)
+	}
+
+	owner, used, err := s.AuthenticateToken(secret)
+	if err != nil || owner.ID != user.ID || used.ID != token.ID {
+		t.Fatalf("expected alice's token, got %+v, %+v, %v", owner, used, err)
+	}
+	tokens, _ := s.ListTokens(user.ID)
+	if len(tokens) != 1 || tokens[0].LastUsedAt == nil || !tokens[0].LastUsedAt.Equal(now) {
+		t.Errorf("expected last use to be recorded, got %+v", tokens)
+	}
+
+	if _, _, err := s.AuthenticateToken("not-a-token"); !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("expected ErrTokenNotFound, got %v", err)
+	}
+	if err := s.RevokeToken(user.ID+1, token.ID); !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("expected another user's revoke to fail, got %v", err)
+	}
+	if err := s.RevokeToken(user.ID, token.ID); err != nil {
+		t.Fatalf("failed to revoke token: %v", err)
+	}
+	if _, _, err := s.AuthenticateToken(secret); !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("expected revoked token to be rejected, got %v", err)
+	}
+}
+
+func TestLoginIdentity(t *testing.T) {
+	store := newMockAuthStore()
+	s := NewService(store, false)
+	alice, _ := s.Register("alice", "long enough")
+	carol, _ := s.ProxyUser("carol")
+
+	// An account with a password is not taken over by whoever gets the
+	// provider to assert its username
+	if _, _, err := s.LoginIdentity(Identity{Issuer: "https://id.example", Subject: "1", Username: "Alice"}, nil); !errors.Is(err, ErrIdentityConflict) {
+		t.Fatalf("expected ErrIdentityConflict, got %v", err)
+	}
+	if _, _, err := s.LoginIdentity(Identity{Issuer: "https://id.example", Subject: "1", Username: "Alice"}, carol); !errors.Is(err, ErrIdentityConflict) {
+		t.Fatalf("expected ErrIdentityConflict while logged in as someone else, got %v", err)
+	}
+
+	// Its owner confirms the link by being logged in
+	token, _, err := s.LoginIdentity(Identity{Issuer: "https://id.example", Subject: "1", Username: "Alice"}, alice)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if user, _ := s.Authenticate(token); user == nil || user.ID != alice.ID {
+		t.Errorf("expected to log in as alice, got %+v", user)
+	}
+	token, _, err = s.LoginIdentity(Identity{Issuer: "https://id.example", Subject: "1", Username: "Alice"}, nil)
+	if err != nil {
+		t.Fatalf("expected the linked identity to log in, got %v", err)
+	}
+	if user, _ := s.Authenticate(token); user == nil || user.ID != alice.ID {
+		t.Errorf("expected to log in as alice again, got %+v", user)
+	}
+
+	// Accounts without a password are linked by username
+	token, _, err = s.LoginIdentity(Identity{Issuer: "https://id.example", Subject: "4", Username: "carol"}, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if user, _ := s.Authenticate(token); user == nil || user.ID != carol.ID {
+		t.Errorf("expected to log in as carol, got %+v", user)
+	}
+
+	// Only the first identity is linked by username: another subject
+	// asserting the same username does not share the account
+	if _, _, err := s.LoginIdentity(Identity{Issuer: "https://id.example", Subject: "5", Username: "carol"}, nil); !errors.Is(err, ErrIdentityConflict) {
+		t.Errorf("expected ErrIdentityConflict for a second subject, got %v", err)
+	}
+	if _, _, err := s.LoginIdentity(Identity{Issuer: "https://other.example", Subject: "4", Username: "carol"}, nil); !errors.Is(err, ErrIdentityConflict) {
+		t.Errorf("expected ErrIdentityConflict for another issuer, got %v", err)
+	}
+	// unless the account's owner is logged in to confirm it
+	if _, _, err := s.LoginIdentity(Identity{Issuer: "https://other.example", Subject: "4", Username: "carol"}, carol); err != nil {
+		t.Errorf("expected the owner to link a second identity, got %v", err)
+	}
+
+	// New usernames get an account even with registration closed
+	token, _, err = s.LoginIdentity(Identity{Issuer: "https://id.example", Subject: "2", Username: "bob"}, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	bob, _ := s.Authenticate(token)
+	if bob == nil || bob.Username != "bob" {
+		t.Fatalf("expected a new account for bob, got %+v", bob)
+	}
+	if _, _, err := s.Login("bob", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expected accounts without a password to refuse password logins, got %v", err)
+	}
+
+	// Later logins follow the subject, not the username
+	token, _, err = s.LoginIdentity(Identity{Issuer: "https://id.example", Subject: "2", Username: "robert"}, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if user, _ := s.Authenticate(token); user == nil || user.ID != bob.ID {
+		t.Errorf("expected the linked account, got %+v", user)
+	}
+	if _, _, err := s.LoginIdentity(Identity{Issuer: "https://id.example", Subject: "6", Username: "bob"}, nil); !errors.Is(err, ErrIdentityConflict) {
+		t.Errorf("expected ErrIdentityConflict for a second subject named bob, got %v", err)
+	}
+
+	if _, _, err := s.LoginIdentity(Identity{Issuer: "https://id.example", Subject: "3", Username: "not valid"}, nil); !errors.Is(err, ErrInvalidAccount) {
+		t.Errorf("expected ErrInvalidAccount for an unusable username, got %v", err)
+	}
+}
@@ -0,0 +1,295 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// CreateUser adds an account. The first account also takes over the habits
+// created before accounts existed.
+func (s *Store) CreateUser(username, passwordHash string) (*User, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin registration: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`INSERT INTO users (username, password_hash) VALUES (?, ?)`, username, passwordHash)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return nil, ErrUsernameTaken
+		}
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user id: %w", err)
+	}
+
+	var users int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&users); err != nil {
+		return nil, fmt.Errorf("failed to count users: %w", err)
+	}
+	if users == 1 {
+		if _, err := tx.Exec(`UPDATE habits SET user_id = ? WHERE user_id = 0`, id); err != nil {
+			return nil, fmt.Errorf("failed to claim existing habits: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit registration: %w", err)
+	}
+	return s.GetUserByID(int(id))
+}
+
+// GetUserByID returns the account with the given ID
+func (s *Store) GetUserByID(userID int) (*User, error) {
+	user, _, err := s.scanUser(s.db.QueryRow(
+		`SELECT id, username, password_hash, created_at FROM users WHERE id = ?`, userID,
+	))
+	return user, err
+}
+
+// GetUserByUsername returns the account and its password hash. Usernames
+// match case-insensitively.
+func (s *Store) GetUserByUsername(username string) (*User, string, error) {
+	return s.scanUser(s.db.QueryRow(
+		`SELECT id, username, password_hash, created_at FROM users WHERE username = ?`, username,
+	))
+}
+
+func (s *Store) scanUser(row *sql.Row) (*User, string, error) {
+	var user User
+	var hash, createdAt string
+	if err := row.Scan(&user.ID, &user.Username, &hash, &createdAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, "", ErrInvalidCredentials
+		}
+		return nil, "", fmt.Errorf("failed to get user: %w", err)
+	}
+	user.CreatedAt, _ = time.Parse("2006-01-02 15:04:05", createdAt)
+	return &user, hash, nil
+}
+
+// CountUsers returns the number of accounts
+func (s *Store) CountUsers() (int, error) {
+	var count int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count users: %w", err)
+	}
+	return count, nil
+}
+
+// CreateSession stores a session by the hash of its token
+func (s *Store) CreateSession(tokenHash string, userID int, expiresAt time.Time) error {
+	_, err := s.db.Exec(
+		`INSERT INTO sessions (token_hash, user_id, expires_at) VALUES (?, ?, ?)`,
+		tokenHash, userID, expiresAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return nil
+}
+
+// GetSessionUser returns the user of a session that has not expired by now
+func (s *Store) GetSessionUser(tokenHash string, now time.Time) (*User, error) {
+	var user User
+	var createdAt string
+	err := s.db.QueryRow(
+		`SELECT u.id, u.username, u.created_at
+		 FROM sessions s JOIN users u ON u.id = s.user_id
+		 WHERE s.token_hash = ? AND s.expires_at > ?`,
+		tokenHash, now.UTC().Format(time.RFC3339),
+	).Scan(&user.ID, &user.Username, &createdAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	user.CreatedAt, _ = time.Parse("2006-01-02 15:04:05", createdAt)
+	return &user, nil
+}
+
+// DeleteSession removes a session, if it exists
+func (s *Store) DeleteSession(tokenHash string) error {
+	if _, err := s.db.Exec(`DELETE FROM sessions WHERE token_hash = ?`, tokenHash); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredSessions removes every session that expired by now
+func (s *Store) DeleteExpiredSessions(now time.Time) error {
+	if _, err := s.db.Exec(`DELETE FROM sessions WHERE expires_at <= ?`, now.UTC().Format(time.RFC3339)); err != nil {
+		return fmt.Errorf("failed to delete expired sessions: %w", err)
+	}
+	return nil
+}
+
+// CreateToken stores an API token by the hash of its secret
+func (s *Store) CreateToken(userID int, name, scope, tokenHash string) (*Token, error) {
+	result, err := s.db.Exec(
+		`INSERT INTO api_tokens (user_id, name, token_hash, scope) VALUES (?, ?, ?, ?)`,
+		userID, name, tokenHash, scope,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create API token: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API token id: %w", err)
+	}
+	return scanToken(s.db.QueryRow(
+		`SELECT id, name, scope, created_at, last_used_at FROM api_tokens WHERE id = ?`, id,
+	))
+}
+
+// ListTokens returns the user's API tokens, newest first
+func (s *Store) ListTokens(userID int) ([]Token, error) {
+	rows, err := s.db.Query(
+		`SELECT id, name, scope, created_at, last_used_at FROM api_tokens
+		 WHERE user_id = ? ORDER BY id DESC`, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := []Token{}
+	for rows.Next() {
+		token, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *token)
+	}
+	return tokens, rows.Err()
+}
+
+// DeleteToken revokes one of the user's API tokens
+func (s *Store) DeleteToken(userID, tokenID int) error {
+	result, err := s.db.Exec(`DELETE FROM api_tokens WHERE id = ? AND user_id = ?`, tokenID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete API token: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete API token: %w", err)
+	}
+	if affected == 0 {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+// GetTokenUser returns the API token with the given hash and its owner
+func (s *Store) GetTokenUser(tokenHash string) (*User, *Token, error) {
+	var user User
+	var token Token
+	var userCreatedAt, tokenCreatedAt string
+	var lastUsedAt sql.NullString
+	err := s.db.QueryRow(
+		`SELECT u.id, u.username, u.created_at, t.id, t.name, t.scope, t.created_at, t.last_used_at
+		 FROM api_tokens t JOIN users u ON u.id = t.user_id
+		 WHERE t.token_hash = ?`, tokenHash,
+	).Scan(&user.ID, &user.Username, &userCreatedAt, &token.ID, &token.Name, &token.Scope, &tokenCreatedAt, &lastUsedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, ErrTokenNotFound
+		}
+		return nil, nil, fmt.Errorf("failed to get API token: %w", err)
+	}
+	user.CreatedAt, _ = time.Parse("2006-01-02 15:04:05", userCreatedAt)
+	token.CreatedAt, _ = time.Parse("2006-01-02 15:04:05", tokenCreatedAt)
+	token.LastUsedAt = parseLastUsed(lastUsedAt)
+	return &user, &token, nil
+}
+
+// TouchToken records when an API token was last used
+func (s *Store) TouchToken(tokenID int, now time.Time) error {
+	if _, err := s.db.Exec(
+		`UPDATE api_tokens SET last_used_at = ? WHERE id = ?`, now.UTC().Format(time.RFC3339), tokenID,
+	); err != nil {
+		return fmt.Errorf("failed to record API token use: %w", err)
+	}
+	return nil
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanToken(row scanner) (*Token, error) {
+	var token Token
+	var createdAt string
+	var lastUsedAt sql.NullString
+	if err := row.Scan(&token.ID, &token.Name, &token.Scope, &createdAt, &lastUsedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTokenNotFound
+		}
+		return nil, fmt.Errorf("failed to scan API token: %w", err)
+	}
+	token.CreatedAt, _ = time.Parse("2006-01-02 15:04:05", createdAt)
+	token.LastUsedAt = parseLastUsed(lastUsedAt)
+	return &token, nil
+}
+
+func parseLastUsed(value sql.NullString) *time.Time {
+	if !value.Valid {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, value.String)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
+// GetIdentityUser returns the account an external identity is linked to
+func (s *Store) GetIdentityUser(issuer, subject string) (*User, error) {
+	user, _, err := s.scanUser(s.db.QueryRow(
+		`SELECT u.id, u.username, u.password_hash, u.created_at
+		 FROM user_identities i JOIN users u ON u.id = i.user_id
+		 WHERE i.issuer = ? AND i.subject = ?`, issuer, subject,
+	))
+	return user, err
+}
+
+// HasIdentity reports whether any external identity is linked to the account
+func (s *Store) HasIdentity(userID int) (bool, error) {
+	var linked bool
+	if err := s.db.QueryRow(
+		`SELECT EXISTS (SELECT 1 FROM user_identities WHERE user_id = ?)`, userID,
+	).Scan(&linked); err != nil {
+		return false, fmt.Errorf("failed to check identities: %w", err)
+	}
+	return linked, nil
+}
+
+// LinkIdentity links an external identity to an account
+func (s *Store) LinkIdentity(issuer, subject string, userID int) error {
+	if _, err := s.db.Exec(
+		`INSERT INTO user_identities (issuer, subject, user_id) VALUES (?, ?, ?)
+		 ON CONFLICT (issuer, subject) DO UPDATE SET user_id = excluded.user_id`,
+		issuer, subject, userID,
+	); err != nil {
+		return fmt.Errorf("failed to link identity: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/epalmerini/abitudini/internal/testhelpers"
+)
+
+func TestStore_CreateUser(t *testing.T) {
+	db := testhelpers.NewTestDB(t)
+	store := NewStore(db)
+
+	if _, err := db.Exec(`INSERT INTO habits (id, description, start_date, color) VALUES (1, 'Read', '2025-01-01', '')`); err != nil {
+		t.Fatalf("failed to create habit: %v", err)
+	}
+
+	alice, err := store.CreateUser("alice", "hash-a")
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	if alice.ID == 0 || alice.Username != "alice" || alice.CreatedAt.IsZero() {
+		t.Errorf("unexpected user %+v", alice)
+	}
+
+	if _, err := store.CreateUser("ALICE", "hash"); !errors.Is(err, ErrUsernameTaken) {
+		t.Errorf("expected ErrUsernameTaken, got %v", err)
+	}
+
+	if _, err := db.Exec(`INSERT INTO habits (id, description, start_date, color) VALUES (2, 'Walk', '2025-01-01', '')`); err != nil {
+		t.Fatalf("failed to create habit: %v", err)
+	}
+	bob, err := store.CreateUser("bob", "hash-b")
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	// Only the first account claims the habits from before accounts existed
+	var owner1, owner2 int
+	db.QueryRow(`SELECT user_id FROM habits WHERE id = 1`).Scan(&owner1)
+	db.QueryRow(`SELECT user_id FROM habits WHERE id = 2`).Scan(&owner2)
+	if owner1 != alice.ID || owner2 != 0 {
+		t.Errorf("expected habit 1 owned by %d and habit 2 unowned, got %d and %d", alice.ID, owner1, owner2)
+	}
+
+	user, hash, err := store.GetUserByUsername("Bob")
+	if err != nil || user.ID != bob.ID || hash != "hash-b" {
+		t.Errorf("expected case-insensitive lookup of bob, got %+v, %q, %v", user, hash, err)
+	}
+	if _, _, err := store.GetUserByUsername("carol"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expected ErrInvalidCredentials, got %v", err)
+	}
+
+	if count, err := store.CountUsers(); err != nil || count != 2 {
+		t.Errorf("expected 2 users, got %d, %v", count, err)
+	}
+}
+
+func TestStore_Sessions(t *testing.T) {
+	db := testhelpers.NewTestDB(t)
+	store := NewStore(db)
+
+	user, err := store.CreateUser("alice", "hash")
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := store.CreateSession("live", user.ID, now.Add(time.Hour)); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	if err := store.CreateSession("stale", user.ID, now.Add(-time.Hour)); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	got, err := store.GetSessionUser("live", now)
+	if err != nil || got.ID != user.ID || got.Username != "alice" {
+		t.Errorf("expected alice's session, got %+v, %v", got, err)
+	}
+	if _, err := store.GetSessionUser("stale", now); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected expired session not to be found, got %v", err)
+	}
+
+	if err := store.DeleteExpiredSessions(now); err != nil {
+		t.Fatalf("failed to delete expired sessions: %v", err)
+	}
+	var count int
+	db.QueryRow(`SELECT COUNT(*) FROM sessions`).Scan(&count)
+	if count != 1 {
+		t.Errorf("expected only the live session to remain, got %d", count)
+	}
+
+	if err := store.DeleteSession("live"); err != nil {
+		t.Fatalf("failed to delete session: %v", err)
+	}
+	if _, err := store.GetSessionUser("live", now); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected deleted session not to be found, got %v", err)
+	}
+}
+
+func TestStore_Tokens(t *testing.T) {
+	db := testhelpers.NewTestDB(t)
+	store := NewStore(db)
+
+	alice, _ := store.CreateUser("alice", "hash")
+	bob, _ := store.CreateUser("bob", "hash")
+
+	token, err := store.CreateToken(alice.ID, "cron", ScopeRead, "hash-1")
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+	if token.Name != "cron" || token.Scope != ScopeRead || token.CreatedAt.IsZero() || token.LastUsedAt != nil {
+		t.Errorf("unexpected token %+v", token)
+	}
+	if _, err := store.CreateToken(alice.ID, "shortcut", ScopeWrite, "hash-2"); err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := store.TouchToken(token.ID, now); err != nil {
+		t.Fatalf("failed to touch token: %v", err)
+	}
+
+	user, got, err := store.GetTokenUser("hash-1")
+	if err != nil || user.ID != alice.ID || got.ID != token.ID {
+		t.Fatalf("expected alice's token, got %+v, %+v, %v", user, got, err)
+	}
+	if got.LastUsedAt == nil || !got.LastUsedAt.Equal(now) {
+		t.Errorf("expected last use %s, got %v", now, got.LastUsedAt)
+	}
+
+	tokens, err := store.ListTokens(alice.ID)
+	if err != nil || len(tokens) != 2 || tokens[0].Name != "shortcut" {
+		t.Errorf("expected alice's tokens newest first, got %+v, %v", tokens, err)
+	}
+	if tokens, _ := store.ListTokens(bob.ID); len(tokens) != 0 {
+		t.Errorf("expected bob to have no tokens, got %+v", tokens)
+	}
+
+	if err := store.DeleteToken(bob.ID, token.ID); !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("expected bob not to revoke alice's token, got %v", err)
+	}
+	if err := store.DeleteToken(alice.ID, token.ID); err != nil {
+		t.Fatalf("failed to delete token: %v", err)
+	}
+	if _, _, err := store.GetTokenUser("hash-1"); !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("expected revoked token not to be found, got %v", err)
+	}
+}
+
+func TestStore_Identities(t *testing.T) {
+	db := testhelpers.NewTestDB(t)
+	store := NewStore(db)
+
+	alice, err := store.CreateUser("alice", "")
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	if linked, err := store.HasIdentity(alice.ID); err != nil || linked {
+		t.Errorf("expected no identity yet, got %v (%v)", linked, err)
+	}
+	if _, err := store.GetIdentityUser("https://id.example", "1"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expected an unknown identity not to be found, got %v", err)
+	}
+
+	if err := store.LinkIdentity("https://id.example", "1", alice.ID); err != nil {
+		t.Fatalf("failed to link identity: %v", err)
+	}
+	if linked, err := store.HasIdentity(alice.ID); err != nil || !linked {
+		t.Errorf("expected a linked identity, got %v (%v)", linked, err)
+	}
+	user, err := store.GetIdentityUser("https://id.example", "1")
+	if err != nil || user.ID != alice.ID {
+		t.Errorf("expected the identity to log in as alice, got %+v (%v)", user, err)
+	}
+}
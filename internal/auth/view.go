@@ -0,0 +1,222 @@
+package auth
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"time"
+)
+
+var pageTemplate = template.Must(template.New("account").Parse(accountPageHTML))
+
+var tokenTemplates = template.Must(template.New("tokens").Funcs(template.FuncMap{
+	"formatTime": func(t time.Time) string { return t.Local().Format("2006-01-02 15:04") },
+}).Parse(tokensPageHTML + tokenRowHTML + newTokenHTML))
+
+type pageData struct {
+	Title      string
+	Action     string
+	Submit     string
+	Username   string
+	Error      string
+	Register   bool
+	CanSwitch  bool
+	PasswordAC string
+	SSO        bool
+	CSRFToken  string
+}
+
+// RenderLogin renders the login page, linking to registration while it is
+// open and offering single sign-on when it is configured
+func RenderLogin(username, errMsg string, registrationOpen, sso bool, csrfToken string) string {
+	return renderPage(pageData{
+		Title:      "Log in",
+		Action:     "/login",
+		Submit:     "Log in",
+		Username:   username,
+		Error:      errMsg,
+		CanSwitch:  registrationOpen,
+		PasswordAC: "current-password",
+		SSO:        sso,
+		CSRFToken:  csrfToken,
+	})
+}
+
+// RenderRegister renders the registration page
+func RenderRegister(username, errMsg, csrfToken string) string {
+	return renderPage(pageData{
+		Title:      "Create account",
+		Action:     "/register",
+		Submit:     "Create account",
+		Username:   username,
+		Error:      errMsg,
+		Register:   true,
+		CanSwitch:  true,
+		PasswordAC: "new-password",
+		CSRFToken:  csrfToken,
+	})
+}
+
+func renderPage(data pageData) string {
+	var buf bytes.Buffer
+	if err := pageTemplate.Execute(&buf, data); err != nil {
+		return fmt.Sprintf("Error rendering page: %v", err)
+	}
+	return buf.String()
+}
+
+// RenderTokensPage renders the API token management page
+func RenderTokensPage(username string, tokens []Token, csrfToken string) string {
+	return renderTokens("tokens-page", struct {
+		Username  string
+		Tokens    []Token
+		CSRFToken string
+	}{username, tokens, csrfToken})
+}
+
+// RenderTokenList renders the token rows (useful for HTMX updates)
+func RenderTokenList(tokens []Token) string {
+	var buf bytes.Buffer
+	for _, token := range tokens {
+		if err := tokenTemplates.ExecuteTemplate(&buf, "token-row", token); err != nil {
+			return fmt.Sprintf("Error rendering tokens: %v", err)
+		}
+	}
+	return buf.String()
+}
+
+// RenderNewToken renders a created token's secret, shown only once, and
+// its row for the token list
+func RenderNewToken(token NewToken) string {
+	return renderTokens("new-token", token)
+}
+
+func renderTokens(name string, data any) string {
+	var buf bytes.Buffer
+	if err := tokenTemplates.ExecuteTemplate(&buf, name, data); err != nil {
+		return fmt.Sprintf("Error rendering tokens: %v", err)
+	}
+	return buf.String()
+}
+
+const accountPageHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{.Title}} - Abitudini</title>
+    <link rel="icon" type="image/svg+xml" href="/static/logo.svg">
+    <link rel="stylesheet" href="/static/style.css">
+</head>
+<body>
+    <header>
+        <div class="container">
+            <h1><img src="/static/logo.svg" alt="A" class="logo">bitudini</h1>
+        </div>
+    </header>
+
+    <main class="container">
+        <form class="account-form" method="post" action="{{.Action}}">
+            <h2>{{.Title}}</h2>
+            <input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+            {{if .Error}}<p class="account-error" role="alert">{{.Error}}</p>{{end}}
+            <label>Username
+                <input type="text" name="username" value="{{.Username}}" autocomplete="username" required autofocus>
+            </label>
+            <label>Password
+                <input type="password" name="password" autocomplete="{{.PasswordAC}}" required{{if .Register}} minlength="8"{{end}}>
+            </label>
+            <button type="submit" class="btn btn-primary">{{.Submit}}</button>
+            {{if .SSO}}
+            <a class="btn" href="/login/oidc">Log in with single sign-on</a>
+            {{end}}
+            {{if .CanSwitch}}
+                {{if .Register}}
+                <p>Already have an account? <a href="/login">Log in</a></p>
+                {{else}}
+                <p>No account yet? <a href="/register">Create one</a></p>
+                {{end}}
+            {{end}}
+        </form>
+    </main>
+</body>
+</html>
+`
+
+const tokensPageHTML = `
+{{define "tokens-page"}}
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>API tokens - Abitudini</title>
+    <link rel="icon" type="image/svg+xml" href="/static/logo.svg">
+    <link rel="stylesheet" href="/static/style.css">
+    <script src="https://cdn.jsdelivr.net/npm/htmx.org@1.9.10"></script>
+</head>
+<body hx-headers='{"X-CSRF-Token": "{{.CSRFToken}}"}'>
+    <header>
+        <div class="container">
+            <h1><a href="/"><img src="/static/logo.svg" alt="A" class="logo">bitudini</a></h1>
+            <div class="header-actions">
+                <span class="header-user">{{.Username}}</span>
+                <a class="btn" href="/">Habits</a>
+            </div>
+        </div>
+    </header>
+
+    <main class="container tokens">
+        <h2>API tokens</h2>
+        <p class="tokens-help">Send a token as <code>Authorization: Bearer &lt;token&gt;</code> to use the <code>/api/habits</code> endpoints from scripts.</p>
+
+        <form class="token-form" hx-post="/api/tokens" hx-target="#new-token" hx-on::after-request="if (event.detail.successful) this.reset()">
+            <input type="text" name="name" placeholder="What is it for?" maxlength="100" required>
+            <select name="scope">
+                <option value="write">Read and write</option>
+                <option value="read">Read only</option>
+            </select>
+            <button type="submit" class="btn btn-primary">Create token</button>
+        </form>
+
+        <div id="new-token"></div>
+
+        <div id="token-list">
+            {{range .Tokens}}
+                {{template "token-row" .}}
+            {{end}}
+        </div>
+    </main>
+</body>
+</html>
+{{end}}
+`
+
+const tokenRowHTML = `
+{{define "token-row"}}
+<div class="token-row" id="token-{{.ID}}">
+    <div>
+        <strong>{{.Name}}</strong>
+        <span class="token-scope">{{if .CanWrite}}read and write{{else}}read only{{end}}</span>
+        <div class="token-meta">
+            Created {{formatTime .CreatedAt}} ·
+            {{with .LastUsedAt}}last used {{formatTime .}}{{else}}never used{{end}}
+        </div>
+    </div>
+    <button class="btn btn-danger" hx-delete="/api/tokens/{{.ID}}" hx-target="#token-{{.ID}}" hx-swap="outerHTML"
+        hx-confirm="Revoke {{.Name}}? Scripts using it will stop working.">Revoke</button>
+</div>
+{{end}}
+`
+
+const newTokenHTML = `
+{{define "new-token"}}
+<div class="token-secret" role="status">
+    <p>Copy the token for <strong>{{.Name}}</strong> now. It will not be shown again.</p>
+    <code>{{.Secret}}</code>
+</div>
+<div hx-swap-oob="afterbegin:#token-list">
+    {{template "token-row" .Token}}
+</div>
+{{end}}
+`
@@ -0,0 +1,151 @@
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/epalmerini/abitudini/internal/db"
+	"github.com/mattn/go-sqlite3"
+)
+
+// Create writes a consistent copy of the database to path with VACUUM INTO.
+// It is safe while the server is running: readers and writers only wait for
+// the copy to start. path must not exist yet.
+func Create(database *sql.DB, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("backup file %s already exists", path)
+	}
+	if _, err := database.Exec(`VACUUM INTO ?`, path); err != nil {
+		return fmt.Errorf("failed to back up database: %w", err)
+	}
+	return nil
+}
+
+// Validate checks that path is an intact abitudini database this build can
+// read and returns its schema version. Databases from before versioned
+// migrations report version 0.
+func Validate(path string) (int, error) {
+	if _, err := os.Stat(path); err != nil {
+		return 0, fmt.Errorf("failed to open backup: %w", err)
+	}
+
+	source, err := openReadOnly(path)
+	if err != nil {
+		return 0, err
+	}
+	defer source.Close()
+
+	var check string
+	if err := source.QueryRow(`PRAGMA quick_check`).Scan(&check); err != nil {
+		return 0, fmt.Errorf("not a SQLite database: %w", err)
+	}
+	if check != "ok" {
+		return 0, fmt.Errorf("backup is corrupt: %s", check)
+	}
+
+	tables := make(map[string]bool)
+	rows, err := source.Query(`SELECT name FROM sqlite_master WHERE type = 'table'`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read backup tables: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return 0, fmt.Errorf("failed to read backup tables: %w", err)
+		}
+		tables[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read backup tables: %w", err)
+	}
+	if !tables["habits"] || !tables["records"] {
+		return 0, errors.New("not an abitudini backup: missing habits or records table")
+	}
+
+	version := 0
+	if tables["schema_migrations"] {
+		if err := source.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+			return 0, fmt.Errorf("failed to read backup schema version: %w", err)
+		}
+	}
+	if version > db.LatestVersion() {
+		return 0, fmt.Errorf("backup schema version %d is newer than this build supports (%d); upgrade abitudini first", version, db.LatestVersion())
+	}
+	return version, nil
+}
+
+// Restore validates the backup at path, replaces the contents of database
+// with it using SQLite's online backup API and migrates it to the latest
+// schema. It returns the backup's schema version.
+func Restore(database *sql.DB, path string) (int, error) {
+	version, err := Validate(path)
+	if err != nil {
+		return 0, err
+	}
+
+	source, err := openReadOnly(path)
+	if err != nil {
+		return 0, err
+	}
+	defer source.Close()
+
+	ctx := context.Background()
+	destConn, err := database.Conn(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to restore backup: %w", err)
+	}
+	defer destConn.Close()
+	sourceConn, err := source.Conn(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to restore backup: %w", err)
+	}
+	defer sourceConn.Close()
+
+	err = destConn.Raw(func(dest any) error {
+		return sourceConn.Raw(func(src any) error {
+			destSQLite, ok1 := dest.(*sqlite3.SQLiteConn)
+			srcSQLite, ok2 := src.(*sqlite3.SQLiteConn)
+			if !ok1 || !ok2 {
+				return errors.New("unexpected database driver")
+			}
+
+			copier, err := destSQLite.Backup("main", srcSQLite, "main")
+			if err != nil {
+				return err
+			}
+			if _, err := copier.Step(-1); err != nil {
+				copier.Finish()
+				return err
+			}
+			return copier.Finish()
+		})
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to restore backup: %w", err)
+	}
+
+	if err := db.Migrate(database); err != nil {
+		return 0, fmt.Errorf("restored backup but failed to migrate it: %w", err)
+	}
+	return version, nil
+}
+
+// openReadOnly opens a database file without allowing writes
+func openReadOnly(path string) (*sql.DB, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve backup path: %w", err)
+	}
+	dsn := (&url.URL{Scheme: "file", Path: absPath, RawQuery: "mode=ro"}).String()
+	source, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open backup: %w", err)
+	}
+	return source, nil
+}
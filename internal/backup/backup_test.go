@@ -0,0 +1,149 @@
+package backup
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/epalmerini/abitudini/internal/db"
+)
+
+// newDB creates a migrated database file holding habits with the given
+// descriptions
+func newDB(t *testing.T, descriptions ...string) (*sql.DB, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "abitudini.db")
+	database, err := db.Init(path)
+	if err != nil {
+		t.Fatalf("failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	for _, d := range descriptions {
+		if _, err := database.Exec(`INSERT INTO habits (description, start_date, color) VALUES (?, '2025-01-01', '')`, d); err != nil {
+			t.Fatalf("failed to create habit: %v", err)
+		}
+	}
+	return database, path
+}
+
+func descriptions(t *testing.T, database *sql.DB) []string {
+	t.Helper()
+
+	rows, err := database.Query(`SELECT description FROM habits ORDER BY id`)
+	if err != nil {
+		t.Fatalf("failed to read habits: %v", err)
+	}
+	defer rows.Close()
+
+	var result []string
+	for rows.Next() {
+		var d string
+		if err := rows.Scan(&d); err != nil {
+			t.Fatalf("failed to scan habit: %v", err)
+		}
+		result = append(result, d)
+	}
+	return result
+}
+
+func TestCreateAndRestore(t *testing.T) {
+	database, _ := newDB(t, "Read", "Run")
+	path := filepath.Join(t.TempDir(), "backup.db")
+
+	if err := Create(database, path); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := Create(database, path); err == nil {
+		t.Error("expected an existing backup file to be refused")
+	}
+
+	version, err := Validate(path)
+	if err != nil {
+		t.Fatalf("expected a valid backup, got %v", err)
+	}
+	if version != db.LatestVersion() {
+		t.Errorf("expected version %d, got %d", db.LatestVersion(), version)
+	}
+
+	other, _ := newDB(t, "Meditate")
+	if _, err := Restore(other, path); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got := descriptions(t, other); strings.Join(got, ",") != "Read,Run" {
+		t.Errorf("expected the backup's habits, got %v", got)
+	}
+}
+
+func TestRestore_MigratesOlderBackup(t *testing.T) {
+	source, sourcePath := newDB(t, "Read")
+	if err := db.MigrateTo(source, 6); err != nil {
+		t.Fatalf("failed to downgrade: %v", err)
+	}
+	source.Close()
+
+	database, _ := newDB(t)
+	version, err := Restore(database, sourcePath)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if version != 6 {
+		t.Errorf("expected backup version 6, got %d", version)
+	}
+	if current, _ := db.CurrentVersion(database); current != db.LatestVersion() {
+		t.Errorf("expected the restored database to be migrated, got version %d", current)
+	}
+}
+
+func TestValidate_Rejects(t *testing.T) {
+	dir := t.TempDir()
+
+	garbage := filepath.Join(dir, "garbage.db")
+	if err := os.WriteFile(garbage, []byte("not a database at all, just some text"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	foreign := filepath.Join(dir, "foreign.db")
+	other, err := sql.Open("sqlite3", foreign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Exec(`CREATE TABLE notes (id INTEGER PRIMARY KEY)`); err != nil {
+		t.Fatal(err)
+	}
+	other.Close()
+
+	newer, newerPath := newDB(t)
+	if _, err := newer.Exec(`INSERT INTO schema_migrations (version, name) VALUES (?, 'from the future')`, db.LatestVersion()+1); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		path string
+		want string
+	}{
+		"missing":       {filepath.Join(dir, "missing.db"), "failed to open backup"},
+		"not sqlite":    {garbage, "not a SQLite database"},
+		"other schema":  {foreign, "not an abitudini backup"},
+		"newer version": {newerPath, "newer than this build supports"},
+	}
+
+	for name, tt := range tests {
+		_, err := Validate(tt.path)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", name, tt.want, err)
+		}
+	}
+
+	// A rejected backup leaves the database untouched
+	database, _ := newDB(t, "Read")
+	if _, err := Restore(database, foreign); err == nil {
+		t.Error("expected restore to refuse an invalid backup")
+	}
+	if got := descriptions(t, database); len(got) != 1 {
+		t.Errorf("expected the database to be untouched, got %v", got)
+	}
+}
@@ -0,0 +1,122 @@
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// File names of scheduled backups, which sort chronologically
+const (
+	filePrefix = "abitudini-"
+	fileSuffix = ".db"
+	fileLayout = "20060102-150405"
+)
+
+// Scheduler writes a backup to a directory at a fixed interval and keeps
+// only the most recent ones
+type Scheduler struct {
+	db       *sql.DB
+	dir      string
+	interval time.Duration
+	keep     int
+	now      func() time.Time
+}
+
+func NewScheduler(db *sql.DB, dir string, interval time.Duration, keep int) *Scheduler {
+	return &Scheduler{db: db, dir: dir, interval: interval, keep: keep, now: time.Now}
+}
+
+// Run backs up immediately if the newest backup is older than the interval,
+// then once per interval until ctx is done. Failures are logged and retried
+// at the next tick.
+func (s *Scheduler) Run(ctx context.Context) {
+	if latest, err := s.latest(); err != nil || s.now().Sub(latest) >= s.interval {
+		s.runOnce()
+	}
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.runOnce()
+		}
+	}
+}
+
+func (s *Scheduler) runOnce() {
+	path, err := s.Rotate()
+	if err != nil {
+		log.Printf("Scheduled backup failed: %v", err)
+		return
+	}
+	log.Printf("Backed up database to %s", path)
+}
+
+// Rotate writes a new backup and deletes the oldest ones beyond the
+// retention count. It returns the new backup's path.
+func (s *Scheduler) Rotate() (string, error) {
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	path := filepath.Join(s.dir, filePrefix+s.now().UTC().Format(fileLayout)+fileSuffix)
+	if err := Create(s.db, path); err != nil {
+		return "", err
+	}
+
+	backups, err := s.list()
+	if err != nil {
+		return path, err
+	}
+	for len(backups) > s.keep {
+		if err := os.Remove(filepath.Join(s.dir, backups[0])); err != nil {
+			return path, fmt.Errorf("failed to delete old backup: %w", err)
+		}
+		backups = backups[1:]
+	}
+	return path, nil
+}
+
+// list returns the scheduled backups in the directory, oldest first. Other
+// files are left alone.
+func (s *Scheduler) list() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	var backups []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, filePrefix) || !strings.HasSuffix(name, fileSuffix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, filePrefix), fileSuffix)
+		if _, err := time.Parse(fileLayout, stamp); err != nil {
+			continue
+		}
+		backups = append(backups, name)
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// latest returns when the newest scheduled backup was taken
+func (s *Scheduler) latest() (time.Time, error) {
+	backups, err := s.list()
+	if err != nil || len(backups) == 0 {
+		return time.Time{}, err
+	}
+	name := backups[len(backups)-1]
+	return time.Parse(fileLayout, strings.TrimSuffix(strings.TrimPrefix(name, filePrefix), fileSuffix))
+}
@@ -0,0 +1,52 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScheduler_RotateKeepsNewest(t *testing.T) {
+	database, _ := newDB(t, "Read")
+	dir := filepath.Join(t.TempDir(), "backups")
+
+	// Unrelated files in the directory are never deleted
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewScheduler(database, dir, time.Hour, 2)
+	now := time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	for i := 0; i < 4; i++ {
+		if _, err := s.Rotate(); err != nil {
+			t.Fatalf("rotation %d: expected no error, got %v", i, err)
+		}
+		now = now.Add(time.Hour)
+	}
+
+	backups, err := s.list()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"abitudini-20250301-100000.db", "abitudini-20250301-110000.db"}
+	if len(backups) != len(want) || backups[0] != want[0] || backups[1] != want[1] {
+		t.Errorf("expected %v, got %v", want, backups)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Errorf("expected unrelated files to be kept, got %v", err)
+	}
+	if _, err := Validate(filepath.Join(dir, want[1])); err != nil {
+		t.Errorf("expected a valid backup, got %v", err)
+	}
+
+	latest, err := s.latest()
+	if err != nil || !latest.Equal(time.Date(2025, 3, 1, 11, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the newest backup time, got %v (%v)", latest, err)
+	}
+}
@@ -0,0 +1,75 @@
+package calendar
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/epalmerini/abitudini/internal/shared"
+)
+
+// HandlerService interface for dependency injection
+type HandlerService interface {
+	Feed(userID, habitID int) (*Feed, error)
+}
+
+type Handler struct {
+	shared.BaseHandler
+	service HandlerService
+}
+
+func NewHandler(service HandlerService) *Handler {
+	return &Handler{service: service}
+}
+
+// Feed serves the iCalendar feed of the user whose read-only API token
+// authenticated the request. habit limits the feed to one habit and upcoming=todo lists upcoming
+// occurrences as VTODOs instead of events.
+func (h *Handler) Feed(w http.ResponseWriter, r *http.Request) {
+	if !h.ValidateMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	query := r.URL.Query()
+	habitID := 0
+	if value := query.Get("habit"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			h.RespondError(w, r, "Invalid habit ID", http.StatusBadRequest)
+			return
+		}
+		habitID = id
+	}
+
+	var dueAsTodos bool
+	switch query.Get("upcoming") {
+	case "", "event":
+	case "todo":
+		dueAsTodos = true
+	default:
+		h.RespondError(w, r, "Invalid upcoming value", http.StatusBadRequest)
+		return
+	}
+
+	feed, err := h.service.Feed(h.UserID(r), habitID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrHabitNotFound) {
+			status = http.StatusNotFound
+		}
+		h.RespondError(w, r, err.Error(), status)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := WriteICS(&buf, feed, dueAsTodos); err != nil {
+		h.RespondError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="abitudini.ics"`)
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	w.Write(buf.Bytes())
+}
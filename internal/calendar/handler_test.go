@@ -0,0 +1,72 @@
+package calendar
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/epalmerini/abitudini/internal/shared"
+)
+
+type mockCalendarHandlerService struct {
+	userID  int
+	habitID int
+	err     error
+}
+
+func (m *mockCalendarHandlerService) Feed(userID, habitID int) (*Feed, error) {
+	m.userID = userID
+	m.habitID = habitID
+	if m.err != nil {
+		return nil, m.err
+	}
+	return testFeed(), nil
+}
+
+func TestFeed_ServesCalendar(t *testing.T) {
+	service := &mockCalendarHandlerService{}
+	handler := NewHandler(service)
+	w := httptest.NewRecorder()
+
+	// The account comes from the token, never from the URL
+	ctx := shared.WithUser(context.Background(), shared.User{ID: 3, Username: "alice"})
+	handler.Feed(w, httptest.NewRequest("GET", "/api/calendar.ics?token=abt_read&user=bob&habit=4&upcoming=todo", nil).WithContext(ctx))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/calendar; charset=utf-8" {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	if service.userID != 3 || service.habitID != 4 {
+		t.Errorf("expected feed for alice's habit 4, got user %d's habit %d", service.userID, service.habitID)
+	}
+	if !strings.Contains(w.Body.String(), "BEGIN:VTODO") {
+		t.Errorf("expected upcoming days as todos, got %s", w.Body.String())
+	}
+}
+
+func TestFeed_BadRequests(t *testing.T) {
+	tests := []struct {
+		target     string
+		err        error
+		wantStatus int
+	}{
+		{"/api/calendar.ics?token=abt_read&habit=abc", nil, http.StatusBadRequest},
+		{"/api/calendar.ics?token=abt_read&upcoming=later", nil, http.StatusBadRequest},
+		{"/api/calendar.ics?token=abt_read&habit=9", ErrHabitNotFound, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		handler := NewHandler(&mockCalendarHandlerService{err: tt.err})
+		w := httptest.NewRecorder()
+
+		handler.Feed(w, httptest.NewRequest("GET", tt.target, nil))
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: expected status %d, got %d", tt.target, tt.wantStatus, w.Code)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package calendar
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// WriteICS writes the feed as an iCalendar (RFC 5545) document. Upcoming
+// occurrences become VTODOs when dueAsTodos is set and all-day VEVENTs
+// otherwise, since many calendar clients ignore VTODOs.
+func WriteICS(w io.Writer, feed *Feed, dueAsTodos bool) error {
+	out := bufio.NewWriter(w)
+	line := func(name, value string) {
+		writeFolded(out, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//abitudini//abitudini//EN")
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	line("X-WR-CALNAME", escapeText(feed.Name))
+
+	for _, e := range feed.Events {
+		component := "VEVENT"
+		if e.Kind == KindDue && dueAsTodos {
+			component = "VTODO"
+		}
+
+		line("BEGIN", component)
+		line("UID", e.UID)
+		line("DTSTAMP", e.Stamp.UTC().Format("20060102T150405Z"))
+		line("DTSTART;VALUE=DATE", e.Start.Format("20060102"))
+		if component == "VTODO" {
+			line("DUE;VALUE=DATE", e.End.Format("20060102"))
+		} else {
+			line("DTEND;VALUE=DATE", e.End.Format("20060102"))
+			line("TRANSP", "TRANSPARENT")
+		}
+		line("SUMMARY", escapeText(e.Summary))
+		if e.Description != "" {
+			line("DESCRIPTION", escapeText(e.Description))
+		}
+		if e.Kind == KindCompleted {
+			line("STATUS", "CONFIRMED")
+		}
+		line("CATEGORIES", strings.ToUpper(string(e.Kind)))
+		line("END", component)
+	}
+
+	line("END", "VCALENDAR")
+	return out.Flush()
+}
+
+// escapeText escapes a TEXT property value
+func escapeText(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	).Replace(s)
+}
+
+// writeFolded writes a content line, folding it so no line exceeds 75
+// octets without splitting a UTF-8 sequence
+func writeFolded(out *bufio.Writer, s string) {
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		out.WriteString(s[:cut] + "\r\n ")
+		s = s[cut:]
+		// Continuation lines start with a space, which counts
+		limit = 74
+	}
+	out.WriteString(s + "\r\n")
+}
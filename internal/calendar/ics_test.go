@@ -0,0 +1,92 @@
+package calendar
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testFeed() *Feed {
+	return &Feed{
+		Name: "Abitudini",
+		Events: []Event{
+			{
+				UID:         "habit-1-2025-03-01@abitudini",
+				Kind:        KindCompleted,
+				Summary:     "✓ Read, then sleep; early",
+				Description: "line one\nline two",
+				Start:       day("2025-03-01"),
+				End:         day("2025-03-02"),
+				Stamp:       time.Date(2025, 3, 1, 21, 30, 0, 0, time.UTC),
+			},
+			{
+				UID:     "habit-1-due-2025-03-10@abitudini",
+				Kind:    KindDue,
+				Summary: "Read",
+				Start:   day("2025-03-10"),
+				End:     day("2025-03-11"),
+				Stamp:   day("2025-03-10"),
+			},
+		},
+	}
+}
+
+func TestWriteICS_Events(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteICS(&buf, testFeed(), false); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\nVERSION:2.0\r\n",
+		"DTSTAMP:20250301T213000Z\r\n",
+		"DTSTART;VALUE=DATE:20250301\r\nDTEND;VALUE=DATE:20250302\r\n",
+		`SUMMARY:✓ Read\, then sleep\; early` + "\r\n",
+		`DESCRIPTION:line one\nline two` + "\r\n",
+		"CATEGORIES:DUE\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "VTODO") {
+		t.Error("expected upcoming days as events")
+	}
+}
+
+func TestWriteICS_Todos(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteICS(&buf, testFeed(), true); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	out := buf.String()
+
+	if strings.Count(out, "BEGIN:VEVENT") != 1 || strings.Count(out, "BEGIN:VTODO") != 1 {
+		t.Errorf("expected one event and one todo:\n%s", out)
+	}
+	if !strings.Contains(out, "DUE;VALUE=DATE:20250311\r\n") {
+		t.Errorf("expected a due date on the todo:\n%s", out)
+	}
+}
+
+func TestWriteICS_FoldsLongLines(t *testing.T) {
+	feed := &Feed{Name: strings.Repeat("é", 60)}
+
+	var buf bytes.Buffer
+	if err := WriteICS(&buf, feed, false); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line longer than 75 octets: %q", line)
+		}
+	}
+	unfolded := strings.ReplaceAll(buf.String(), "\r\n ", "")
+	if !strings.Contains(unfolded, "X-WR-CALNAME:"+feed.Name+"\r\n") {
+		t.Errorf("expected the folded name to unfold intact:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,48 @@
+package calendar
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrHabitNotFound is returned when a feed is requested for a missing habit
+var ErrHabitNotFound = errors.New("habit not found")
+
+// upcomingDays is how far ahead the feed lists scheduled occurrences
+const upcomingDays = 14
+
+// Kind tells completed days apart from upcoming ones
+type Kind string
+
+const (
+	KindCompleted Kind = "completed"
+	KindDue       Kind = "due"
+)
+
+// Record is a stored record as the feed needs it. Completed is false for
+// skipped days and for quantities below the daily target.
+type Record struct {
+	HabitID     int
+	Date        time.Time
+	Completed   bool
+	Value       float64
+	Note        string
+	CompletedAt time.Time
+}
+
+// Event is an all-day entry. End is exclusive, as in iCalendar.
+type Event struct {
+	UID         string
+	Kind        Kind
+	Summary     string
+	Description string
+	Start       time.Time
+	End         time.Time
+	Stamp       time.Time
+}
+
+// Feed is a named list of events
+type Feed struct {
+	Name   string
+	Events []Event
+}
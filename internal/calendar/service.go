@@ -0,0 +1,191 @@
+package calendar
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/epalmerini/abitudini/internal/habit"
+)
+
+// StoreAdapter defines the interface for data access
+type StoreAdapter interface {
+	GetHabits(userID int) ([]habit.Habit, error)
+	GetRecords(userID, habitID int) ([]Record, error)
+}
+
+type Service struct {
+	store StoreAdapter
+}
+
+func NewService(store StoreAdapter) *Service {
+	return &Service{store: store}
+}
+
+// Feed lists the completed days and upcoming occurrences of every habit
+// of userID, or of a single one when habitID is not 0
+func (s *Service) Feed(userID, habitID int) (*Feed, error) {
+	habits, err := s.store.GetHabits(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	name := "Abitudini"
+	if habitID != 0 {
+		var selected []habit.Habit
+		for _, h := range habits {
+			if h.ID == habitID {
+				selected = append(selected, h)
+				name += ": " + h.Description
+			}
+		}
+		if len(selected) == 0 {
+			return nil, ErrHabitNotFound
+		}
+		habits = selected
+	}
+
+	records, err := s.store.GetRecords(userID, habitID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Feed{Name: name, Events: buildEvents(habits, records, time.Now())}, nil
+}
+
+// buildEvents turns completions into events and adds the occurrences due
+// from today through the next upcomingDays days
+func buildEvents(habits []habit.Habit, records []Record, now time.Time) []Event {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	byID := make(map[int]habit.Habit, len(habits))
+	for _, h := range habits {
+		byID[h.ID] = h
+	}
+
+	var events []Event
+	recorded := make(map[int]map[string]bool, len(habits))
+	for _, r := range records {
+		h, ok := byID[r.HabitID]
+		if !ok {
+			continue
+		}
+		if recorded[h.ID] == nil {
+			recorded[h.ID] = make(map[string]bool)
+		}
+		date := r.Date.Format("2006-01-02")
+		recorded[h.ID][date] = true
+		if !r.Completed {
+			continue
+		}
+
+		stamp := r.CompletedAt
+		if stamp.IsZero() {
+			stamp = r.Date
+		}
+		summary := "✓ " + h.Description
+		if h.IsQuantitative() {
+			summary += ": " + formatAmount(r.Value, h.Unit)
+		}
+		events = append(events, Event{
+			UID:         fmt.Sprintf("habit-%d-%s@abitudini", h.ID, date),
+			Kind:        KindCompleted,
+			Summary:     summary,
+			Description: r.Note,
+			Start:       r.Date,
+			End:         r.Date.AddDate(0, 0, 1),
+			Stamp:       stamp,
+		})
+	}
+
+	for _, h := range habits {
+		if h.HasFrequency() {
+			events = append(events, periodsDue(h, records, today)...)
+		} else {
+			events = append(events, daysDue(h, recorded[h.ID], today)...)
+		}
+	}
+	return events
+}
+
+// daysDue lists the scheduled days in the window that have no record yet
+func daysDue(h habit.Habit, recorded map[string]bool, today time.Time) []Event {
+	var events []Event
+	for i := 0; i < upcomingDays; i++ {
+		day := today.AddDate(0, 0, i)
+		date := day.Format("2006-01-02")
+		if day.Before(h.StartDate) || !h.IsScheduledOn(day) || recorded[date] {
+			continue
+		}
+
+		description := ""
+		if h.IsQuantitative() {
+			description = "Target: " + formatAmount(h.DailyTarget, h.Unit)
+		}
+		events = append(events, Event{
+			UID:         fmt.Sprintf("habit-%d-due-%s@abitudini", h.ID, date),
+			Kind:        KindDue,
+			Summary:     h.Description,
+			Description: description,
+			Start:       day,
+			End:         day.AddDate(0, 0, 1),
+			Stamp:       today,
+		})
+	}
+	return events
+}
+
+// periodsDue lists one multi-day entry per week or month in the window whose
+// target is not met yet, spanning the days left in that period
+func periodsDue(h habit.Habit, records []Record, today time.Time) []Event {
+	windowEnd := today.AddDate(0, 0, upcomingDays)
+
+	var events []Event
+	for start := h.TargetPeriod.Start(today); start.Before(windowEnd); start = h.TargetPeriod.Next(start) {
+		end := h.TargetPeriod.Next(start)
+
+		done := 0
+		for _, r := range records {
+			if r.HabitID == h.ID && r.Completed && !r.Date.Before(start) && r.Date.Before(end) {
+				done++
+			}
+		}
+		remaining := h.TargetCount - done
+		if remaining <= 0 {
+			continue
+		}
+
+		from := start
+		for _, later := range []time.Time{today, h.StartDate} {
+			if later.After(from) {
+				from = later
+			}
+		}
+		to := end
+		if windowEnd.Before(to) {
+			to = windowEnd
+		}
+		if !from.Before(to) {
+			continue
+		}
+
+		events = append(events, Event{
+			UID:         fmt.Sprintf("habit-%d-due-%s@abitudini", h.ID, start.Format("2006-01-02")),
+			Kind:        KindDue,
+			Summary:     fmt.Sprintf("%s (%d to go)", h.Description, remaining),
+			Description: fmt.Sprintf("Target: %d times per %s", h.TargetCount, h.TargetPeriod),
+			Start:       from,
+			End:         to,
+			Stamp:       today,
+		})
+	}
+	return events
+}
+
+// formatAmount renders a quantity with its unit
+func formatAmount(value float64, unit string) string {
+	amount := strconv.FormatFloat(value, 'f', -1, 64)
+	if unit == "" {
+		return amount
+	}
+	return amount + " " + unit
+}
@@ -0,0 +1,172 @@
+package calendar
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/epalmerini/abitudini/internal/habit"
+)
+
+type mockCalendarStore struct {
+	habits        []habit.Habit
+	records       []Record
+	requestedUser int
+	requestedFeed int
+}
+
+func (m *mockCalendarStore) GetHabits(userID int) ([]habit.Habit, error) {
+	m.requestedUser = userID
+	return m.habits, nil
+}
+
+func (m *mockCalendarStore) GetRecords(userID, habitID int) ([]Record, error) {
+	m.requestedFeed = habitID
+	return m.records, nil
+}
+
+func day(value string) time.Time {
+	d, _ := time.Parse("2006-01-02", value)
+	return d
+}
+
+func eventsOf(events []Event, kind Kind) []Event {
+	var out []Event
+	for _, e := range events {
+		if e.Kind == kind {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func TestBuildEvents_Completions(t *testing.T) {
+	habits := []habit.Habit{
+		{ID: 1, Description: "Read", StartDate: day("2025-01-01")},
+		{ID: 2, Description: "Water", StartDate: day("2025-01-01"), DailyTarget: 8, Unit: "glasses"},
+	}
+	records := []Record{
+		{HabitID: 1, Date: day("2025-03-01"), Completed: true, Value: 1, Note: "chapter 3", CompletedAt: time.Date(2025, 3, 1, 21, 0, 0, 0, time.UTC)},
+		{HabitID: 1, Date: day("2025-03-02")},
+		{HabitID: 2, Date: day("2025-03-01"), Completed: true, Value: 9},
+		{HabitID: 2, Date: day("2025-03-02"), Value: 3},
+	}
+
+	completed := eventsOf(buildEvents(habits, records, day("2025-03-10")), KindCompleted)
+
+	if len(completed) != 2 {
+		t.Fatalf("expected 2 completed events, got %+v", completed)
+	}
+	read := completed[0]
+	if read.Summary != "✓ Read" || read.Description != "chapter 3" || read.UID != "habit-1-2025-03-01@abitudini" {
+		t.Errorf("unexpected event %+v", read)
+	}
+	if !read.End.Equal(day("2025-03-02")) || read.Stamp.Hour() != 21 {
+		t.Errorf("expected an all-day event stamped with the completion time, got %+v", read)
+	}
+	if completed[1].Summary != "✓ Water: 9 glasses" {
+		t.Errorf("expected amount in summary, got %q", completed[1].Summary)
+	}
+}
+
+func TestBuildEvents_ScheduledDays(t *testing.T) {
+	// Monday 2025-03-10; scheduled Monday and Thursday, already done today
+	habits := []habit.Habit{{
+		ID: 1, Description: "Gym", StartDate: day("2025-01-01"),
+		Days: []time.Weekday{time.Monday, time.Thursday},
+	}}
+	records := []Record{{HabitID: 1, Date: day("2025-03-10"), Completed: true, Value: 1}}
+
+	due := eventsOf(buildEvents(habits, records, day("2025-03-10")), KindDue)
+
+	var dates []string
+	for _, e := range due {
+		dates = append(dates, e.Start.Format("2006-01-02"))
+	}
+	if got := strings.Join(dates, ","); got != "2025-03-13,2025-03-17,2025-03-20" {
+		t.Errorf("expected the next scheduled days, got %s", got)
+	}
+}
+
+func TestBuildEvents_NotBeforeStartDate(t *testing.T) {
+	habits := []habit.Habit{{ID: 1, Description: "Run", StartDate: day("2025-03-20")}}
+
+	due := eventsOf(buildEvents(habits, nil, day("2025-03-10")), KindDue)
+
+	if len(due) != 4 || !due[0].Start.Equal(day("2025-03-20")) {
+		t.Errorf("expected occurrences from the start date only, got %+v", due)
+	}
+}
+
+func TestBuildEvents_FrequencyTarget(t *testing.T) {
+	// Wednesday 2025-03-12; 3 times per week with 1 done this week
+	habits := []habit.Habit{{
+		ID: 1, Description: "Swim", StartDate: day("2025-01-01"),
+		TargetCount: 3, TargetPeriod: habit.PeriodWeek,
+	}}
+	records := []Record{{HabitID: 1, Date: day("2025-03-10"), Completed: true, Value: 1}}
+
+	due := eventsOf(buildEvents(habits, records, day("2025-03-12")), KindDue)
+
+	if len(due) != 3 {
+		t.Fatalf("expected this week and the next two, got %+v", due)
+	}
+	if due[0].Summary != "Swim (2 to go)" || !due[0].Start.Equal(day("2025-03-12")) || !due[0].End.Equal(day("2025-03-17")) {
+		t.Errorf("expected the rest of this week, got %+v", due[0])
+	}
+	if due[2].Summary != "Swim (3 to go)" || !due[2].End.Equal(day("2025-03-26")) {
+		t.Errorf("expected the last week cut at the window, got %+v", due[2])
+	}
+}
+
+func TestBuildEvents_FrequencyTargetMet(t *testing.T) {
+	habits := []habit.Habit{{
+		ID: 1, Description: "Swim", StartDate: day("2025-01-01"),
+		TargetCount: 1, TargetPeriod: habit.PeriodMonth,
+	}}
+	records := []Record{{HabitID: 1, Date: day("2025-03-02"), Completed: true, Value: 1}}
+
+	due := eventsOf(buildEvents(habits, records, day("2025-03-12")), KindDue)
+
+	if len(due) != 0 {
+		t.Errorf("expected no entry once the month's target is met, got %+v", due)
+	}
+}
+
+func TestFeed_SingleHabit(t *testing.T) {
+	store := &mockCalendarStore{habits: []habit.Habit{
+		{ID: 1, Description: "Read"},
+		{ID: 2, Description: "Walk"},
+	}}
+	s := NewService(store)
+
+	feed, err := s.Feed(1, 2)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if feed.Name != "Abitudini: Walk" || store.requestedFeed != 2 {
+		t.Errorf("expected a feed for Walk only, got %q", feed.Name)
+	}
+	for _, e := range feed.Events {
+		if !strings.HasPrefix(e.UID, "habit-2-") {
+			t.Errorf("unexpected event %s", e.UID)
+		}
+	}
+
+	if _, err := s.Feed(1, 9); !errors.Is(err, ErrHabitNotFound) {
+		t.Errorf("expected ErrHabitNotFound, got %v", err)
+	}
+}
+
+func TestFeed_User(t *testing.T) {
+	store := &mockCalendarStore{}
+	s := NewService(store)
+
+	if _, err := s.Feed(3, 0); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if store.requestedUser != 3 {
+		t.Errorf("expected user 3's habits, got user %d", store.requestedUser)
+	}
+}
@@ -0,0 +1,62 @@
+package calendar
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/epalmerini/abitudini/internal/habit"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// GetHabits reads userID's habits through the habit slice's store so the
+// column mapping lives in one place
+func (s *Store) GetHabits(userID int) ([]habit.Habit, error) {
+	return habit.NewStore(s.db).GetAll(userID)
+}
+
+// GetRecords returns every record of one of userID's habits, or of all
+// their habits when habitID is 0, oldest first
+func (s *Store) GetRecords(userID, habitID int) ([]Record, error) {
+	rows, err := s.db.Query(
+		`SELECT r.habit_id, r.record_date,
+		        r.status = 'done' AND (h.daily_target <= 0 OR r.value >= h.daily_target),
+		        r.value, COALESCE(r.note, ''), r.completed_at
+		 FROM records r
+		 JOIN habits h ON h.id = r.habit_id
+		 WHERE h.user_id = ? AND (? = 0 OR r.habit_id = ?)
+		 ORDER BY r.record_date, r.habit_id`,
+		userID, habitID, habitID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get records: %w", err)
+	}
+	defer rows.Close()
+
+	var records []Record
+	for rows.Next() {
+		var r Record
+		var date, completedAt string
+		if err := rows.Scan(&r.HabitID, &date, &r.Completed, &r.Value, &r.Note, &completedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan record: %w", err)
+		}
+		if r.Date, err = time.Parse("2006-01-02", date); err != nil {
+			continue
+		}
+		r.CompletedAt, _ = time.Parse("2006-01-02 15:04:05", completedAt)
+		records = append(records, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating records: %w", err)
+	}
+
+	return records, nil
+}
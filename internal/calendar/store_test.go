@@ -0,0 +1,63 @@
+package calendar
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/epalmerini/abitudini/internal/testhelpers"
+)
+
+func TestGetRecords_CompletedFlag(t *testing.T) {
+	db := testhelpers.NewTestDB(t)
+	store := NewStore(db)
+
+	if _, err := db.Exec(
+		`INSERT INTO habits (id, user_id, description, start_date, color, daily_target, unit)
+		 VALUES (1, 1, 'Read', '2025-01-01', '', 0, ''), (2, 1, 'Water', '2025-01-01', '', 8, 'glasses')`,
+	); err != nil {
+		t.Fatalf("failed to create habits: %v", err)
+	}
+	if _, err := db.Exec(
+		`INSERT INTO records (habit_id, record_date, completed_at, value, status, note)
+		 VALUES (1, '2025-03-01', '2025-03-01 21:00:00', 1, 'done', 'good'),
+		        (1, '2025-03-02', '2025-03-02 08:00:00', 0, 'skipped', ''),
+		        (2, '2025-03-01', '2025-03-01 20:00:00', 9, 'done', ''),
+		        (2, '2025-03-02', '2025-03-02 20:00:00', 3, 'done', '')`,
+	); err != nil {
+		t.Fatalf("failed to create records: %v", err)
+	}
+
+	records, err := store.GetRecords(1, 0)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("expected 4 records, got %d", len(records))
+	}
+
+	completed := map[string]bool{}
+	for _, r := range records {
+		completed[fmt.Sprintf("%s/%d", r.Date.Format("2006-01-02"), r.HabitID)] = r.Completed
+	}
+	want := map[string]bool{"2025-03-01/1": true, "2025-03-02/1": false, "2025-03-01/2": true, "2025-03-02/2": false}
+	for key, expected := range want {
+		if completed[key] != expected {
+			t.Errorf("%s: expected completed=%v", key, expected)
+		}
+	}
+	if records[0].Note != "good" || records[0].CompletedAt.Hour() != 21 {
+		t.Errorf("unexpected first record %+v", records[0])
+	}
+
+	only, err := store.GetRecords(1, 2)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(only) != 2 {
+		t.Errorf("expected 2 records for habit 2, got %d", len(only))
+	}
+
+	if others, _ := store.GetRecords(2, 0); len(others) != 0 {
+		t.Errorf("expected no records for another user, got %d", len(others))
+	}
+}
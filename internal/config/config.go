@@ -0,0 +1,334 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the settings shared by the server and the subcommands
+type Config struct {
+	// DBPath is the SQLite database file. Relative paths resolve against
+	// the working directory.
+	DBPath string
+	// Addr is the host:port the server listens on
+	Addr string
+	// BackupDir enables scheduled backups into this directory when not
+	// empty
+	BackupDir string
+	// BackupInterval is the time between scheduled backups
+	BackupInterval time.Duration
+	// BackupKeep is how many scheduled backups are kept
+	BackupKeep int
+	// AllowRegistration lets anyone create an account. When false only
+	// the first account can register.
+	AllowRegistration bool
+	// OIDCIssuer enables single sign-on through this OpenID Connect
+	// provider when not empty
+	OIDCIssuer       string
+	OIDCClientID     string
+	OIDCClientSecret string
+	// OIDCRedirectURL is the callback URL registered with the provider;
+	// empty derives it from the request
+	OIDCRedirectURL string
+	// OIDCUsernameClaim names the ID token claim used as the username
+	OIDCUsernameClaim string
+	// AuthProxyHeader names the header an authenticating reverse proxy
+	// puts the username in; empty disables proxy authentication
+	AuthProxyHeader string
+	// AuthProxyTrusted lists the comma-separated addresses or CIDRs the
+	// header is trusted from; parse it with ParseCIDRs
+	AuthProxyTrusted string
+}
+
+// Default returns the settings used when nothing else is configured
+func Default() Config {
+	return Config{
+		DBPath:            "abitudini.db",
+		Addr:              ":8080",
+		BackupInterval:    24 * time.Hour,
+		BackupKeep:        7,
+		AllowRegistration: true,
+		OIDCUsernameClaim: "preferred_username",
+	}
+}
+
+// setting describes one configuration key and where it can be set. Keys
+// without a flag can only be set in the config file or the environment,
+// which keeps secrets out of process listings.
+type setting struct {
+	key   string // config file key
+	env   string // environment variable
+	flag  string // command-line flag, empty for none
+	usage string
+	// server marks settings that only the server uses; subcommands do not
+	// offer their flags
+	server bool
+	apply  func(c *Config, value string) error
+}
+
+var settings = []setting{
+	{
+		key: "db_path", env: "ABITUDINI_DB", flag: "db",
+		usage: "Path to the SQLite database",
+		apply: func(c *Config, v string) error { c.DBPath = v; return nil },
+	},
+	{
+		key: "port", env: "ABITUDINI_PORT", flag: "p", server: true,
+		usage: "Port to listen on on all interfaces (shorthand for -addr :PORT)",
+		apply: func(c *Config, v string) error {
+			port, err := strconv.Atoi(v)
+			if err != nil || port < 0 || port > 65535 {
+				return fmt.Errorf("invalid port %q", v)
+			}
+			c.Addr = fmt.Sprintf(":%d", port)
+			return nil
+		},
+	},
+	{
+		key: "addr", env: "ABITUDINI_ADDR", flag: "addr", server: true,
+		usage: "Address to listen on, such as 127.0.0.1:8080",
+		apply: func(c *Config, v string) error { c.Addr = v; return nil },
+	},
+	{
+		// The feed used to share one token between all accounts; refuse it
+		// so the old URLs are not silently left to break
+		key: "calendar_token", env: "ABITUDINI_CALENDAR_TOKEN",
+		apply: func(c *Config, v string) error {
+			return errors.New("no longer used: subscribe to the calendar feed with a read-only API token instead")
+		},
+	},
+	{
+		key: "backup_dir", env: "ABITUDINI_BACKUP_DIR", flag: "backup-dir", server: true,
+		usage: "Directory for scheduled backups (default: no scheduled backups)",
+		apply: func(c *Config, v string) error { c.BackupDir = v; return nil },
+	},
+	{
+		key: "backup_interval", env: "ABITUDINI_BACKUP_INTERVAL", flag: "backup-interval", server: true,
+		usage: "Time between scheduled backups, such as 6h (default 24h)",
+		apply: func(c *Config, v string) error {
+			interval, err := time.ParseDuration(v)
+			if err != nil || interval < time.Minute {
+				return fmt.Errorf("invalid interval %q: use a duration of at least 1m, such as 6h", v)
+			}
+			c.BackupInterval = interval
+			return nil
+		},
+	},
+	{
+		key: "backup_keep", env: "ABITUDINI_BACKUP_KEEP", flag: "backup-keep", server: true,
+		usage: "Number of scheduled backups to keep (default 7)",
+		apply: func(c *Config, v string) error {
+			keep, err := strconv.Atoi(v)
+			if err != nil || keep < 1 {
+				return fmt.Errorf("invalid count %q: keep at least 1 backup", v)
+			}
+			c.BackupKeep = keep
+			return nil
+		},
+	},
+	{
+		key: "allow_registration", env: "ABITUDINI_ALLOW_REGISTRATION", flag: "allow-registration", server: true,
+		usage: "Whether new accounts can register once one exists: true or false (default true)",
+		apply: func(c *Config, v string) error {
+			allow, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("invalid value %q: use true or false", v)
+			}
+			c.AllowRegistration = allow
+			return nil
+		},
+	},
+	{
+		key: "oidc_issuer", env: "ABITUDINI_OIDC_ISSUER", flag: "oidc-issuer", server: true,
+		usage: "OpenID Connect issuer URL for single sign-on (default: disabled)",
+		apply: func(c *Config, v string) error {
+			if err := checkURL(v); err != nil {
+				return err
+			}
+			c.OIDCIssuer = v
+			return nil
+		},
+	},
+	{
+		key: "oidc_client_id", env: "ABITUDINI_OIDC_CLIENT_ID", flag: "oidc-client-id", server: true,
+		usage: "OpenID Connect client ID",
+		apply: func(c *Config, v string) error { c.OIDCClientID = v; return nil },
+	},
+	{
+		key: "oidc_client_secret", env: "ABITUDINI_OIDC_CLIENT_SECRET",
+		apply: func(c *Config, v string) error { c.OIDCClientSecret = v; return nil },
+	},
+	{
+		key: "oidc_redirect_url", env: "ABITUDINI_OIDC_REDIRECT_URL", flag: "oidc-redirect-url", server: true,
+		usage: "Callback URL registered with the provider, ending in /login/oidc/callback (default: from the request)",
+		apply: func(c *Config, v string) error {
+			if err := checkURL(v); err != nil {
+				return err
+			}
+			c.OIDCRedirectURL = v
+			return nil
+		},
+	},
+	{
+		key: "oidc_username_claim", env: "ABITUDINI_OIDC_USERNAME_CLAIM", flag: "oidc-username-claim", server: true,
+		usage: "ID token claim used as the username (default preferred_username)",
+		apply: func(c *Config, v string) error { c.OIDCUsernameClaim = v; return nil },
+	},
+	{
+		key: "auth_proxy_header", env: "ABITUDINI_AUTH_PROXY_HEADER", flag: "auth-proxy-header", server: true,
+		usage: "Header carrying the username from an authenticating proxy, such as Remote-User (default: disabled)",
+		apply: func(c *Config, v string) error { c.AuthProxyHeader = v; return nil },
+	},
+	{
+		key: "auth_proxy_trusted", env: "ABITUDINI_AUTH_PROXY_TRUSTED", flag: "auth-proxy-trusted", server: true,
+		usage: "Comma-separated proxy addresses or CIDRs the username header is trusted from",
+		apply: func(c *Config, v string) error {
+			if _, err := ParseCIDRs(v); err != nil {
+				return err
+			}
+			c.AuthProxyTrusted = v
+			return nil
+		},
+	},
+}
+
+// ParseCIDRs parses a comma-separated list of CIDRs. A bare address stands
+// for itself alone.
+func ParseCIDRs(v string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if !strings.Contains(part, "/") {
+			addr, err := netip.ParseAddr(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address %q", part)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", part)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// checkURL accepts absolute http and https URLs
+func checkURL(v string) error {
+	u, err := url.Parse(v)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid URL %q: use an absolute http or https URL", v)
+	}
+	return nil
+}
+
+// configEnv names the environment variable pointing at the config file
+const configEnv = "ABITUDINI_CONFIG"
+
+// Load registers the server's configuration flags on flags, parses args and
+// builds the configuration. Each source overrides the previous one: defaults,
+// then the config file (-config or ABITUDINI_CONFIG), then environment
+// variables, then flags. Within a source, addr wins over port.
+func Load(flags *flag.FlagSet, args []string, getenv func(string) string) (*Config, error) {
+	return load(flags, args, getenv, true)
+}
+
+// LoadCommand is Load for subcommands, which register their own flags on
+// flags first and do not take the server's listen flags
+func LoadCommand(flags *flag.FlagSet, args []string, getenv func(string) string) (*Config, error) {
+	return load(flags, args, getenv, false)
+}
+
+func load(flags *flag.FlagSet, args []string, getenv func(string) string, server bool) (*Config, error) {
+	configPath := flags.String("config", "", "Path to a TOML or YAML config file (default: $"+configEnv+")")
+	flagValues := make(map[string]*string)
+	for _, s := range settings {
+		if s.flag != "" && (server || !s.server) {
+			flagValues[s.key] = flags.String(s.flag, "", s.usage)
+		}
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+
+	path := *configPath
+	if path == "" {
+		path = getenv(configEnv)
+	}
+	if path != "" {
+		values, err := ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for key := range values {
+			if !knownKey(key) {
+				return nil, fmt.Errorf("%s: unknown setting %q", path, key)
+			}
+		}
+		if err := cfg.apply(func(s setting) string { return values[s.key] }, path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.apply(func(s setting) string { return getenv(s.env) }, "environment"); err != nil {
+		return nil, err
+	}
+
+	// Only flags given on the command line count, so their empty defaults
+	// never hide the other sources
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if err := cfg.apply(func(s setting) string {
+		if flagValues[s.key] == nil || !set[s.flag] {
+			return ""
+		}
+		return *flagValues[s.key]
+	}, "flags"); err != nil {
+		return nil, err
+	}
+
+	if cfg.OIDCIssuer != "" && cfg.OIDCClientID == "" {
+		return nil, errors.New("oidc_client_id is required with oidc_issuer")
+	}
+	if cfg.AuthProxyHeader != "" && strings.TrimSpace(strings.ReplaceAll(cfg.AuthProxyTrusted, ",", "")) == "" {
+		return nil, errors.New("auth_proxy_trusted is required with auth_proxy_header")
+	}
+
+	return &cfg, nil
+}
+
+// apply sets every non-empty value returned by lookup, in settings order
+func (c *Config) apply(lookup func(s setting) string, source string) error {
+	for _, s := range settings {
+		value := lookup(s)
+		if value == "" {
+			continue
+		}
+		if err := s.apply(c, value); err != nil {
+			return fmt.Errorf("%s: %s: %w", source, s.key, err)
+		}
+	}
+	return nil
+}
+
+func knownKey(key string) bool {
+	for _, s := range settings {
+		if s.key == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,206 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func env(values map[string]string) func(string) string {
+	return func(key string) string { return values[key] }
+}
+
+func newFlags() *flag.FlagSet {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	return flags
+}
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := Load(newFlags(), nil, env(nil))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if *cfg != Default() {
+		t.Errorf("expected defaults, got %+v", cfg)
+	}
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	path := writeConfig(t, "abitudini.toml", `
+db_path = "/from/file.db"
+addr = "127.0.0.1:7000"
+backup_dir = "/from/file/backups"
+`)
+
+	// File only
+	cfg, err := Load(newFlags(), []string{"-config", path}, env(nil))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.DBPath != "/from/file.db" || cfg.Addr != "127.0.0.1:7000" || cfg.BackupDir != "/from/file/backups" {
+		t.Errorf("expected file values, got %+v", cfg)
+	}
+
+	// Environment overrides the file, found through ABITUDINI_CONFIG
+	environment := env(map[string]string{
+		"ABITUDINI_CONFIG": path,
+		"ABITUDINI_DB":     "/from/env.db",
+		"ABITUDINI_PORT":   "9000",
+	})
+	cfg, err = Load(newFlags(), nil, environment)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.DBPath != "/from/env.db" || cfg.Addr != ":9000" || cfg.BackupDir != "/from/file/backups" {
+		t.Errorf("expected environment values over the file, got %+v", cfg)
+	}
+
+	// Flags override both
+	cfg, err = Load(newFlags(), []string{"-db", "/from/flag.db", "-addr", "localhost:1234"}, environment)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.DBPath != "/from/flag.db" || cfg.Addr != "localhost:1234" {
+		t.Errorf("expected flag values over everything, got %+v", cfg)
+	}
+}
+
+func TestLoad_AddrWinsOverPortInSameSource(t *testing.T) {
+	cfg, err := Load(newFlags(), nil, env(map[string]string{
+		"ABITUDINI_PORT": "9000",
+		"ABITUDINI_ADDR": "127.0.0.1:9001",
+	}))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.Addr != "127.0.0.1:9001" {
+		t.Errorf("expected addr to win, got %s", cfg.Addr)
+	}
+}
+
+func TestLoad_Backup(t *testing.T) {
+	path := writeConfig(t, "abitudini.yaml", "backup_dir: /var/backups/abitudini\nbackup_interval: 6h\n")
+
+	cfg, err := Load(newFlags(), []string{"-config", path, "-backup-keep", "30"}, env(nil))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.BackupDir != "/var/backups/abitudini" || cfg.BackupInterval != 6*time.Hour || cfg.BackupKeep != 30 {
+		t.Errorf("expected backup settings, got %+v", cfg)
+	}
+}
+
+func TestLoad_AllowRegistration(t *testing.T) {
+	cfg, err := Load(newFlags(), nil, env(map[string]string{"ABITUDINI_ALLOW_REGISTRATION": "false"}))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.AllowRegistration {
+		t.Error("expected registration to be closed")
+	}
+
+	cfg, err = Load(newFlags(), []string{"-allow-registration=true"}, env(map[string]string{"ABITUDINI_ALLOW_REGISTRATION": "false"}))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !cfg.AllowRegistration {
+		t.Error("expected the flag to reopen registration")
+	}
+}
+
+func TestLoad_OIDC(t *testing.T) {
+	path := writeConfig(t, "abitudini.toml", `
+oidc_issuer = "https://id.example.com"
+oidc_client_id = "abitudini"
+oidc_client_secret = "s3cret"
+`)
+
+	cfg, err := Load(newFlags(), []string{"-config", path, "-oidc-username-claim", "email"}, env(nil))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.OIDCIssuer != "https://id.example.com" || cfg.OIDCClientID != "abitudini" || cfg.OIDCClientSecret != "s3cret" || cfg.OIDCUsernameClaim != "email" {
+		t.Errorf("expected OIDC settings, got %+v", cfg)
+	}
+}
+
+func TestLoad_AuthProxy(t *testing.T) {
+	cfg, err := Load(newFlags(), []string{"-auth-proxy-header", "Remote-User"}, env(map[string]string{
+		"ABITUDINI_AUTH_PROXY_TRUSTED": "10.0.0.0/8, 127.0.0.1,::1",
+	}))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.AuthProxyHeader != "Remote-User" {
+		t.Errorf("expected the proxy header, got %+v", cfg)
+	}
+
+	prefixes, err := ParseCIDRs(cfg.AuthProxyTrusted)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(prefixes) != 3 || prefixes[0].String() != "10.0.0.0/8" || prefixes[1].String() != "127.0.0.1/32" || prefixes[2].String() != "::1/128" {
+		t.Errorf("unexpected prefixes %v", prefixes)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	unknown := writeConfig(t, "abitudini.yaml", "database: x.db\n")
+
+	tests := map[string]struct {
+		args []string
+		env  map[string]string
+	}{
+		"unknown key":    {args: []string{"-config", unknown}},
+		"missing file":   {args: []string{"-config", "/nonexistent/abitudini.toml"}},
+		"bad port flag":  {args: []string{"-p", "http"}},
+		"bad port env":   {env: map[string]string{"ABITUDINI_PORT": "99999"}},
+		"bad interval":   {args: []string{"-backup-interval", "daily"}},
+		"short interval": {env: map[string]string{"ABITUDINI_BACKUP_INTERVAL": "10s"}},
+		"keep nothing":   {args: []string{"-backup-keep", "0"}},
+		"bad allow":      {args: []string{"-allow-registration", "maybe"}},
+		"bad issuer":     {args: []string{"-oidc-issuer", "id.example.com", "-oidc-client-id", "x"}},
+		"no client id":   {env: map[string]string{"ABITUDINI_OIDC_ISSUER": "https://id.example.com"}},
+		"bad cidr":       {args: []string{"-auth-proxy-header", "Remote-User", "-auth-proxy-trusted", "10.0.0.0/33"}},
+		"no trusted":     {args: []string{"-auth-proxy-header", "Remote-User"}},
+		"calendar token": {env: map[string]string{"ABITUDINI_CALENDAR_TOKEN": "s3cret"}},
+	}
+
+	for name, tt := range tests {
+		if _, err := Load(newFlags(), tt.args, env(tt.env)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestLoadCommand_NoServerFlags(t *testing.T) {
+	flags := newFlags()
+	mode := flags.String("mode", "merge", "")
+
+	cfg, err := LoadCommand(flags, []string{"-mode", "replace", "-db", "x.db", "input.json"}, env(nil))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if *mode != "replace" || cfg.DBPath != "x.db" || flags.Arg(0) != "input.json" {
+		t.Errorf("expected command flags and arguments to parse, got %+v", cfg)
+	}
+
+	_, err = LoadCommand(newFlags(), []string{"-addr", ":1"}, env(nil))
+	if err == nil || !strings.Contains(err.Error(), "not defined") {
+		t.Errorf("expected -addr to be rejected, got %v", err)
+	}
+}
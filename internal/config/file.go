@@ -0,0 +1,120 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ReadFile reads flat key/value settings from a TOML (.toml) or YAML (.yaml,
+// .yml) file. Only the subset the settings need is supported: one
+// "key = value" (TOML) or "key: value" (YAML) per line, with strings,
+// numbers and booleans as values and # comments.
+func ReadFile(path string) (map[string]string, error) {
+	var separator string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		separator = "="
+	case ".yaml", ".yml":
+		separator = ":"
+	default:
+		return nil, fmt.Errorf("%s: config file must end in .toml, .yaml or .yml", path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer file.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || line == "---" {
+			continue
+		}
+
+		key, raw, ok := strings.Cut(line, separator)
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected key %s value", path, lineNo, separator)
+		}
+		key = strings.TrimSpace(key)
+		if key == "" || strings.ContainsAny(key, " \t[]{}\"'") {
+			return nil, fmt.Errorf("%s:%d: invalid key %q", path, lineNo, key)
+		}
+
+		value, err := parseValue(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+		if _, dup := values[key]; dup {
+			return nil, fmt.Errorf("%s:%d: %q is set twice", path, lineNo, key)
+		}
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	return values, nil
+}
+
+// parseValue reads a double-quoted, single-quoted or bare value followed by
+// an optional comment
+func parseValue(raw string) (string, error) {
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		end := closingQuote(raw)
+		if end < 0 {
+			return "", fmt.Errorf("unterminated string")
+		}
+		value, err := strconv.Unquote(raw[:end+1])
+		if err != nil {
+			return "", fmt.Errorf("invalid string %s", raw[:end+1])
+		}
+		return value, trailing(raw[end+1:])
+	case strings.HasPrefix(raw, "'"):
+		end := strings.Index(raw[1:], "'")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated string")
+		}
+		return raw[1 : end+1], trailing(raw[end+2:])
+	}
+
+	// Bare values end at a comment
+	if i := strings.Index(raw, " #"); i >= 0 {
+		raw = raw[:i]
+	}
+	value := strings.TrimSpace(raw)
+	if strings.ContainsAny(value, "[]{}") {
+		return "", fmt.Errorf("only plain values are supported, got %s", value)
+	}
+	return value, nil
+}
+
+// closingQuote returns the index of the quote ending the double-quoted
+// string at the start of s, or -1
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// trailing checks that only a comment follows a quoted value
+func trailing(rest string) error {
+	rest = strings.TrimSpace(rest)
+	if rest != "" && !strings.HasPrefix(rest, "#") {
+		return fmt.Errorf("unexpected %q after value", rest)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package config
+
+import "testing"
+
+func TestReadFile_TOML(t *testing.T) {
+	path := writeConfig(t, "abitudini.toml", `# abitudini settings
+db_path = "/var/lib/abitudini/abitudini.db"  # absolute path
+port = 8080
+calendar_token = 'literal\value'
+addr = "quote \" inside"
+`)
+
+	values, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	want := map[string]string{
+		"db_path":        "/var/lib/abitudini/abitudini.db",
+		"port":           "8080",
+		"calendar_token": `literal\value`,
+		"addr":           `quote " inside`,
+	}
+	for key, value := range want {
+		if values[key] != value {
+			t.Errorf("%s: expected %q, got %q", key, value, values[key])
+		}
+	}
+}
+
+func TestReadFile_YAML(t *testing.T) {
+	path := writeConfig(t, "abitudini.yml", `---
+db_path: /data/abitudini.db
+addr: 127.0.0.1:8080 # loopback only
+calendar_token: "s3cret"
+`)
+
+	values, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if values["db_path"] != "/data/abitudini.db" || values["addr"] != "127.0.0.1:8080" || values["calendar_token"] != "s3cret" {
+		t.Errorf("unexpected values %v", values)
+	}
+}
+
+func TestReadFile_Errors(t *testing.T) {
+	tests := map[string]string{
+		"abitudini.json": `{"port": 1}`,
+		"section.toml":   "[server]\nport = 1\n",
+		"missing.toml":   "port\n",
+		"open.toml":      `db_path = "unterminated` + "\n",
+		"trailing.toml":  `db_path = "a" b` + "\n",
+		"duplicate.yaml": "port: 1\nport: 2\n",
+		"list.yaml":      "port: [1, 2]\n",
+	}
+
+	for name, content := range tests {
+		if _, err := ReadFile(writeConfig(t, name, content)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -3,25 +3,62 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"strings"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// connectionParams are applied by the driver to every pooled connection:
+// enforce foreign keys so ON DELETE CASCADE works, use WAL so readers do not
+// block the writer, wait up to 5s for a lock instead of failing with
+// SQLITE_BUSY, and start transactions with BEGIN IMMEDIATE so two writers
+// queue on the busy timeout instead of deadlocking on a lock upgrade.
+const connectionParams = "_foreign_keys=on&_journal_mode=WAL&_busy_timeout=5000&_synchronous=NORMAL&_txlock=immediate"
+
+// Pool limits. SQLite allows one writer at a time, so a few connections are
+// enough to serve concurrent reads.
+const (
+	maxOpenConns    = 4
+	connMaxIdleTime = 5 * time.Minute
+)
+
+// Init opens the database and migrates it to the latest schema
 func Init(dbPath string) (*sql.DB, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+	db, err := Open(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Run migrations
+	if err := Migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// Open opens the database without touching its schema
+func Open(dbPath string) (*sql.DB, error) {
+	separator := "?"
+	if strings.Contains(dbPath, "?") {
+		separator = "&"
+	}
+
+	db, err := sql.Open("sqlite3", dbPath+separator+connectionParams)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
+	db.SetMaxOpenConns(maxOpenConns)
+	db.SetMaxIdleConns(maxOpenConns)
+	db.SetConnMaxIdleTime(connMaxIdleTime)
 
 	// Test connection
 	if err := db.Ping(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	// Run migrations
-	if err := Migrate(db); err != nil {
-		return nil, err
-	}
-
 	return db, nil
 }
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestInit_ConnectionSettings(t *testing.T) {
+	database, err := Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	var foreignKeys, busyTimeout int
+	var journalMode string
+	database.QueryRow(`PRAGMA foreign_keys`).Scan(&foreignKeys)
+	database.QueryRow(`PRAGMA journal_mode`).Scan(&journalMode)
+	database.QueryRow(`PRAGMA busy_timeout`).Scan(&busyTimeout)
+
+	if foreignKeys != 1 || journalMode != "wal" || busyTimeout != 5000 {
+		t.Errorf("expected foreign keys, WAL and a 5s busy timeout, got %d, %q, %d", foreignKeys, journalMode, busyTimeout)
+	}
+	if stats := database.Stats(); stats.MaxOpenConnections != maxOpenConns {
+		t.Errorf("expected %d max connections, got %d", maxOpenConns, stats.MaxOpenConnections)
+	}
+}
+
+func TestInit_ForeignKeysCascade(t *testing.T) {
+	database, err := Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	database.Exec(`INSERT INTO habits (id, description, start_date, color) VALUES (1, 'Read', '2025-01-01', '')`)
+	database.Exec(`INSERT INTO records (habit_id, record_date, completed_at) VALUES (1, '2025-01-02', '2025-01-02 20:00:00')`)
+
+	if _, err := database.Exec(`INSERT INTO records (habit_id, record_date, completed_at) VALUES (2, '2025-01-02', '2025-01-02 20:00:00')`); err == nil {
+		t.Error("expected a record of a missing habit to be rejected")
+	}
+
+	if _, err := database.Exec(`DELETE FROM habits WHERE id = 1`); err != nil {
+		t.Fatalf("failed to delete habit: %v", err)
+	}
+	var records int
+	database.QueryRow(`SELECT COUNT(*) FROM records`).Scan(&records)
+	if records != 0 {
+		t.Errorf("expected records to cascade, got %d", records)
+	}
+}
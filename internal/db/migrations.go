@@ -3,35 +3,414 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"time"
 )
 
+// Migration is one step of the schema history. Up and Down run inside a
+// transaction together with the schema_migrations bookkeeping.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *sql.Tx) error
+	Down    func(tx *sql.Tx) error
+}
+
+// MigrationStatus is a migration and when it was applied, if it was
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// migrations is the ordered schema history. Append new migrations with the
+// next version; never edit or reorder released ones.
+//
+// Databases created before versioning have some or all of these tables and
+// columns but no schema_migrations table, so the early migrations skip
+// anything that already exists.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create habits and records",
+		Up: execAll(`
+			CREATE TABLE IF NOT EXISTS habits (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				description TEXT NOT NULL,
+				start_date TEXT NOT NULL,
+				color TEXT NOT NULL,
+				created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+
+			CREATE TABLE IF NOT EXISTS records (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				habit_id INTEGER NOT NULL,
+				record_date TEXT NOT NULL,
+				completed_at TEXT NOT NULL,
+				created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (habit_id) REFERENCES habits(id) ON DELETE CASCADE,
+				UNIQUE(habit_id, record_date)
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_records_habit_id ON records(habit_id);
+			CREATE INDEX IF NOT EXISTS idx_records_date ON records(record_date);
+		`),
+		Down: execAll(`
+			DROP TABLE records;
+			DROP TABLE habits;
+		`),
+	},
+	{
+		Version: 2,
+		Name:    "weekday schedules",
+		Up:      addColumns(column{"habits", "days", "TEXT NOT NULL DEFAULT ''"}),
+		Down:    dropColumns(column{"habits", "days", ""}),
+	},
+	{
+		Version: 3,
+		Name:    "frequency targets",
+		Up: addColumns(
+			column{"habits", "target_count", "INTEGER NOT NULL DEFAULT 0"},
+			column{"habits", "target_period", "TEXT NOT NULL DEFAULT ''"},
+		),
+		Down: dropColumns(
+			column{"habits", "target_count", ""},
+			column{"habits", "target_period", ""},
+		),
+	},
+	{
+		Version: 4,
+		Name:    "quantitative habits",
+		Up: addColumns(
+			column{"habits", "unit", "TEXT NOT NULL DEFAULT ''"},
+			column{"habits", "daily_target", "REAL NOT NULL DEFAULT 0"},
+			column{"records", "value", "REAL NOT NULL DEFAULT 1"},
+		),
+		Down: dropColumns(
+			column{"habits", "unit", ""},
+			column{"habits", "daily_target", ""},
+			column{"records", "value", ""},
+		),
+	},
+	{
+		Version: 5,
+		Name:    "record notes",
+		Up:      addColumns(column{"records", "note", "TEXT NOT NULL DEFAULT ''"}),
+		Down:    dropColumns(column{"records", "note", ""}),
+	},
+	{
+		Version: 6,
+		Name:    "skipped days",
+		Up:      addColumns(column{"records", "status", "TEXT NOT NULL DEFAULT 'done'"}),
+		// Without a status every record is a completion, so skipped days
+		// have to go
+		Down: func(tx *sql.Tx) error {
+			if _, err := tx.Exec(`DELETE FROM records WHERE status = 'skipped'`); err != nil {
+				return fmt.Errorf("failed to delete skipped days: %w", err)
+			}
+			return dropColumns(column{"records", "status", ""})(tx)
+		},
+	},
+	{
+		Version: 7,
+		Name:    "grace days",
+		Up:      addColumns(column{"habits", "grace_days", "INTEGER NOT NULL DEFAULT 0"}),
+		Down:    dropColumns(column{"habits", "grace_days", ""}),
+	},
+	{
+		Version: 8,
+		Name:    "remove orphaned records",
+		// Foreign keys were not enforced before, so a failed delete could
+		// leave records of habits that no longer exist
+		Up:   execAll(`DELETE FROM records WHERE habit_id NOT IN (SELECT id FROM habits)`),
+		Down: func(tx *sql.Tx) error { return nil },
+	},
+	{
+		Version: 9,
+		Name:    "user accounts",
+		// Habits from before accounts have owner 0 until the first account
+		// claims them
+		Up: func(tx *sql.Tx) error {
+			err := execAll(`
+				CREATE TABLE IF NOT EXISTS users (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					username TEXT NOT NULL UNIQUE COLLATE NOCASE,
+					password_hash TEXT NOT NULL,
+					created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+				);
+
+				CREATE TABLE IF NOT EXISTS sessions (
+					token_hash TEXT PRIMARY KEY,
+					user_id INTEGER NOT NULL,
+					expires_at TEXT NOT NULL,
+					created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+					FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+				);
+
+				CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+			`)(tx)
+			if err != nil {
+				return err
+			}
+			if err := addColumns(column{"habits", "user_id", "INTEGER NOT NULL DEFAULT 0"})(tx); err != nil {
+				return err
+			}
+			return execAll(`CREATE INDEX IF NOT EXISTS idx_habits_user_id ON habits(user_id)`)(tx)
+		},
+		Down: func(tx *sql.Tx) error {
+			if err := execAll(`DROP INDEX idx_habits_user_id`)(tx); err != nil {
+				return err
+			}
+			if err := dropColumns(column{"habits", "user_id", ""})(tx); err != nil {
+				return err
+			}
+			return execAll(`
+				DROP TABLE sessions;
+				DROP TABLE users;
+			`)(tx)
+		},
+	},
+	{
+		Version: 10,
+		Name:    "api tokens",
+		Up: execAll(`
+			CREATE TABLE IF NOT EXISTS api_tokens (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				name TEXT NOT NULL,
+				token_hash TEXT NOT NULL UNIQUE,
+				scope TEXT NOT NULL DEFAULT 'write',
+				last_used_at TEXT,
+				created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_api_tokens_user_id ON api_tokens(user_id);
+		`),
+		Down: execAll(`DROP TABLE api_tokens`),
+	},
+	{
+		Version: 11,
+		Name:    "single sign-on identities",
+		// Links an OpenID Connect subject to the local account it logs in as
+		Up: execAll(`
+			CREATE TABLE IF NOT EXISTS user_identities (
+				issuer TEXT NOT NULL,
+				subject TEXT NOT NULL,
+				user_id INTEGER NOT NULL,
+				created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (issuer, subject),
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
+		`),
+		Down: execAll(`DROP TABLE user_identities`),
+	},
+}
+
+// LatestVersion is the schema version this build expects
+func LatestVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// Migrate brings the schema up to LatestVersion. It refuses to touch a
+// database migrated by a newer build.
 func Migrate(db *sql.DB) error {
-	schema := `
-	CREATE TABLE IF NOT EXISTS habits (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		description TEXT NOT NULL,
-		start_date TEXT NOT NULL,
-		color TEXT NOT NULL,
-		created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
-	);
-
-	CREATE TABLE IF NOT EXISTS records (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		habit_id INTEGER NOT NULL,
-		record_date TEXT NOT NULL,
-		completed_at TEXT NOT NULL,
-		created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (habit_id) REFERENCES habits(id) ON DELETE CASCADE,
-		UNIQUE(habit_id, record_date)
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_records_habit_id ON records(habit_id);
-	CREATE INDEX IF NOT EXISTS idx_records_date ON records(record_date);
-	`
-
-	if _, err := db.Exec(schema); err != nil {
-		return fmt.Errorf("migration failed: %w", err)
+	return MigrateTo(db, LatestVersion())
+}
+
+// MigrateTo applies or reverts migrations until the schema is at target.
+// Each migration runs in its own transaction.
+func MigrateTo(db *sql.DB, target int) error {
+	if target < 0 || target > LatestVersion() {
+		return fmt.Errorf("unknown schema version %d (latest is %d)", target, LatestVersion())
+	}
+
+	current, err := CurrentVersion(db)
+	if err != nil {
+		return err
+	}
+	if current > LatestVersion() {
+		return fmt.Errorf("database schema version %d is newer than this build supports (%d); upgrade abitudini", current, LatestVersion())
+	}
+
+	for _, m := range migrations {
+		if m.Version > current && m.Version <= target {
+			if err := apply(db, m, true); err != nil {
+				return err
+			}
+		}
+	}
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.Version <= current && m.Version > target {
+			if err := apply(db, m, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// CurrentVersion returns the highest applied migration, or 0 for a new
+// database
+func CurrentVersion(db *sql.DB) (int, error) {
+	if err := ensureMigrationsTable(db); err != nil {
+		return 0, err
+	}
+
+	var version int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// Status lists every known migration and whether it has been applied
+func Status(db *sql.DB) ([]MigrationStatus, error) {
+	if err := ensureMigrationsTable(db); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt string
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schema migration: %w", err)
+		}
+		applied[version], _ = time.Parse("2006-01-02 15:04:05", appliedAt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read schema migrations: %w", err)
 	}
 
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		appliedAt, ok := applied[m.Version]
+		statuses = append(statuses, MigrationStatus{Version: m.Version, Name: m.Name, Applied: ok, AppliedAt: appliedAt})
+	}
+	return statuses, nil
+}
+
+func ensureMigrationsTable(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+// apply runs one migration up or down and records it in the same transaction
+func apply(db *sql.DB, m Migration, up bool) error {
+	direction := "up"
+	if !up {
+		direction = "down"
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("migration %d (%s) %s failed: %w", m.Version, m.Name, direction, err)
+	}
+	defer tx.Rollback()
+
+	step, bookkeeping := m.Down, `DELETE FROM schema_migrations WHERE version = ?`
+	args := []any{m.Version}
+	if up {
+		step, bookkeeping = m.Up, `INSERT INTO schema_migrations (version, name) VALUES (?, ?)`
+		args = append(args, m.Name)
+	}
+
+	if err := step(tx); err != nil {
+		return fmt.Errorf("migration %d (%s) %s failed: %w", m.Version, m.Name, direction, err)
+	}
+	if _, err := tx.Exec(bookkeeping, args...); err != nil {
+		return fmt.Errorf("migration %d (%s) %s failed: %w", m.Version, m.Name, direction, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("migration %d (%s) %s failed: %w", m.Version, m.Name, direction, err)
+	}
 	return nil
 }
+
+// execAll returns a step running the given statements
+func execAll(statements string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(statements)
+		return err
+	}
+}
+
+// column names a column and, for additions, its definition
+type column struct {
+	table, name, definition string
+}
+
+// addColumns returns a step adding each column unless it already exists
+func addColumns(columns ...column) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for _, c := range columns {
+			exists, err := hasColumn(tx, c.table, c.name)
+			if err != nil {
+				return err
+			}
+			if exists {
+				continue
+			}
+			if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.table, c.name, c.definition)); err != nil {
+				return fmt.Errorf("failed to add %s.%s: %w", c.table, c.name, err)
+			}
+		}
+		return nil
+	}
+}
+
+// dropColumns returns a step dropping each column
+func dropColumns(columns ...column) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for _, c := range columns {
+			if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", c.table, c.name)); err != nil {
+				return fmt.Errorf("failed to drop %s.%s: %w", c.table, c.name, err)
+			}
+		}
+		return nil
+	}
+}
+
+// hasColumn reports whether a table has a column
+func hasColumn(tx *sql.Tx, table, column string) (bool, error) {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return false, fmt.Errorf("failed to scan %s columns: %w", table, err)
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
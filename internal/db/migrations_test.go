@@ -0,0 +1,204 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	database, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+func columnNames(t *testing.T, database *sql.DB, table string) string {
+	t.Helper()
+	rows, err := database.Query(`SELECT name FROM pragma_table_info(?) ORDER BY cid`, table)
+	if err != nil {
+		t.Fatalf("failed to inspect %s: %v", table, err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		rows.Scan(&name)
+		names = append(names, name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestMigrate_FreshDatabase(t *testing.T) {
+	database := openTestDB(t)
+
+	if err := Migrate(database); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	version, err := CurrentVersion(database)
+	if err != nil || version != LatestVersion() {
+		t.Errorf("expected version %d, got %d, %v", LatestVersion(), version, err)
+	}
+	if cols := columnNames(t, database, "records"); !strings.Contains(cols, "status") {
+		t.Errorf("expected records.status, got %s", cols)
+	}
+
+	// Running again is a no-op
+	if err := Migrate(database); err != nil {
+		t.Errorf("expected second run to succeed, got %v", err)
+	}
+}
+
+func TestMigrate_AdoptsUnversionedDatabase(t *testing.T) {
+	database := openTestDB(t)
+
+	// A database from before versioning, with schedules but nothing later
+	if _, err := database.Exec(`
+		CREATE TABLE habits (id INTEGER PRIMARY KEY AUTOINCREMENT, description TEXT NOT NULL,
+			start_date TEXT NOT NULL, color TEXT NOT NULL, created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			days TEXT NOT NULL DEFAULT '');
+		CREATE TABLE records (id INTEGER PRIMARY KEY AUTOINCREMENT, habit_id INTEGER NOT NULL,
+			record_date TEXT NOT NULL, completed_at TEXT NOT NULL, created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(habit_id, record_date));
+		INSERT INTO habits (description, start_date, color, days) VALUES ('Read', '2025-01-01', '', '1,3');
+		INSERT INTO records (habit_id, record_date, completed_at) VALUES (1, '2025-01-06', '2025-01-06 20:00:00');
+	`); err != nil {
+		t.Fatalf("failed to create legacy schema: %v", err)
+	}
+
+	if err := Migrate(database); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var days, status string
+	var value float64
+	if err := database.QueryRow(
+		`SELECT h.days, r.value, r.status FROM habits h JOIN records r ON r.habit_id = h.id`,
+	).Scan(&days, &value, &status); err != nil {
+		t.Fatalf("failed to read migrated data: %v", err)
+	}
+	if days != "1,3" || value != 1 || status != "done" {
+		t.Errorf("expected data kept with new defaults, got %q, %v, %q", days, value, status)
+	}
+}
+
+func TestMigrateTo_DownAndUp(t *testing.T) {
+	database := openTestDB(t)
+	if err := Migrate(database); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	database.Exec(`INSERT INTO habits (description, start_date, color) VALUES ('Read', '2025-01-01', '')`)
+	database.Exec(`INSERT INTO records (habit_id, record_date, completed_at, status) VALUES
+		(1, '2025-01-01', '2025-01-01 20:00:00', 'done'), (1, '2025-01-02', '2025-01-02 20:00:00', 'skipped')`)
+
+	if err := MigrateTo(database, 5); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if cols := columnNames(t, database, "habits"); strings.Contains(cols, "grace_days") {
+		t.Errorf("expected grace_days dropped, got %s", cols)
+	}
+	if cols := columnNames(t, database, "records"); strings.Contains(cols, "status") || !strings.Contains(cols, "note") {
+		t.Errorf("expected status dropped and note kept, got %s", cols)
+	}
+	var records int
+	database.QueryRow(`SELECT COUNT(*) FROM records`).Scan(&records)
+	if records != 1 {
+		t.Errorf("expected the skipped day to be removed with its status, got %d records", records)
+	}
+
+	statuses, err := Status(database)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	for _, s := range statuses {
+		if s.Applied != (s.Version <= 5) {
+			t.Errorf("migration %d: unexpected applied=%v", s.Version, s.Applied)
+		}
+	}
+
+	if err := Migrate(database); err != nil {
+		t.Fatalf("expected migrating back up to succeed, got %v", err)
+	}
+	if cols := columnNames(t, database, "habits"); !strings.Contains(cols, "grace_days") {
+		t.Errorf("expected grace_days restored, got %s", cols)
+	}
+}
+
+func TestMigrate_RefusesNewerSchema(t *testing.T) {
+	database := openTestDB(t)
+	if err := Migrate(database); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	if _, err := database.Exec(`INSERT INTO schema_migrations (version, name) VALUES (?, 'from the future')`, LatestVersion()+1); err != nil {
+		t.Fatalf("failed to record future migration: %v", err)
+	}
+
+	err := Migrate(database)
+	if err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("expected newer schema error, got %v", err)
+	}
+}
+
+func TestMigrate_FailedMigrationRollsBack(t *testing.T) {
+	database := openTestDB(t)
+
+	saved := migrations
+	t.Cleanup(func() { migrations = saved })
+	migrations = append(append([]Migration{}, saved...), Migration{
+		Version: LatestVersion() + 1,
+		Name:    "broken",
+		Up:      execAll(`CREATE TABLE extra (id INTEGER); INSERT INTO missing VALUES (1);`),
+		Down:    execAll(`DROP TABLE extra`),
+	})
+
+	if err := Migrate(database); err == nil {
+		t.Fatal("expected the broken migration to fail")
+	}
+
+	version, _ := CurrentVersion(database)
+	if version != len(saved) {
+		t.Errorf("expected to stop at version %d, got %d", len(saved), version)
+	}
+	var tables int
+	database.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'extra'`).Scan(&tables)
+	if tables != 0 {
+		t.Error("expected the failed migration's changes to be rolled back")
+	}
+}
+
+func TestMigrate_RemovesOrphanedRecords(t *testing.T) {
+	database := openTestDB(t)
+	if err := MigrateTo(database, 7); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	conn, err := database.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("failed to get connection: %v", err)
+	}
+	conn.ExecContext(context.Background(), `PRAGMA foreign_keys = OFF`)
+	if _, err := conn.ExecContext(context.Background(),
+		`INSERT INTO records (habit_id, record_date, completed_at) VALUES (9, '2025-01-02', '2025-01-02 20:00:00')`,
+	); err != nil {
+		t.Fatalf("failed to create orphaned record: %v", err)
+	}
+	conn.ExecContext(context.Background(), `PRAGMA foreign_keys = ON`)
+	conn.Close()
+
+	if err := Migrate(database); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	var records int
+	database.QueryRow(`SELECT COUNT(*) FROM records`).Scan(&records)
+	if records != 0 {
+		t.Errorf("expected the orphaned record to be removed, got %d", records)
+	}
+}
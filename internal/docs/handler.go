@@ -0,0 +1,67 @@
+package docs
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/epalmerini/abitudini/internal/shared"
+)
+
+//go:embed openapi.json
+var spec []byte
+
+//go:embed docs.html
+var page string
+
+type Handler struct {
+	shared.BaseHandler
+}
+
+func NewHandler() *Handler {
+	return &Handler{}
+}
+
+// Spec serves the OpenAPI document
+func (h *Handler) Spec(w http.ResponseWriter, r *http.Request) {
+	if !h.ValidateMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(spec)
+}
+
+// Page serves the API documentation page, which renders the OpenAPI
+// document in the browser
+func (h *Handler) Page(w http.ResponseWriter, r *http.Request) {
+	if !h.ValidateMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	h.WriteHTML(w, page)
+}
+
+// Operations lists the operations the OpenAPI document describes as mux
+// patterns such as "GET /api/habits/{id}"
+func Operations() ([]string, error) {
+	var doc struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI document: %w", err)
+	}
+
+	var operations []string
+	for path, item := range doc.Paths {
+		for method := range item {
+			switch method {
+			case "get", "put", "post", "delete", "patch", "head", "options":
+				operations = append(operations, strings.ToUpper(method)+" "+path)
+			}
+		}
+	}
+	return operations, nil
+}
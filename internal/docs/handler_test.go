@@ -0,0 +1,99 @@
+package docs
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSpec_ServesOpenAPIDocument(t *testing.T) {
+	handler := NewHandler()
+
+	req := httptest.NewRequest("GET", "/api/openapi.json", nil)
+	w := httptest.NewRecorder()
+
+	handler.Spec(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected JSON content type, got %s", ct)
+	}
+
+	var doc struct {
+		OpenAPI string         `json:"openapi"`
+		Paths   map[string]any `json:"paths"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("expected valid JSON, got %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("expected OpenAPI 3 document, got %q", doc.OpenAPI)
+	}
+}
+
+func TestPage_IsSelfContained(t *testing.T) {
+	handler := NewHandler()
+
+	req := httptest.NewRequest("GET", "/api/docs", nil)
+	w := httptest.NewRecorder()
+
+	handler.Page(w, req)
+
+	body := w.Body.String()
+	if !strings.Contains(body, "/api/openapi.json") {
+		t.Error("expected docs page to load the OpenAPI document")
+	}
+	if strings.Contains(body, "https://") {
+		t.Error("expected docs page to work offline without external assets")
+	}
+}
+
+func TestOperations(t *testing.T) {
+	operations, err := Operations()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !slices.Contains(operations, "GET /api/habits/{id}") {
+		t.Errorf("expected GET /api/habits/{id} in %v", operations)
+	}
+	if slices.Contains(operations, "PARAMETERS /api/habits/{id}") {
+		t.Error("expected path-level parameters to be ignored")
+	}
+}
+
+func TestSpec_ReferencesResolve(t *testing.T) {
+	var doc map[string]any
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		t.Fatalf("failed to parse spec: %v", err)
+	}
+
+	var walk func(node any)
+	walk = func(node any) {
+		switch v := node.(type) {
+		case map[string]any:
+			if ref, ok := v["$ref"].(string); ok {
+				var target any = doc
+				for _, key := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+					m, _ := target.(map[string]any)
+					target = m[key]
+				}
+				if target == nil {
+					t.Errorf("unresolved reference %s", ref)
+				}
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(doc)
+}
@@ -0,0 +1,172 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Read decodes a document written by Write. CSV columns are matched by
+// header name, so their order does not matter.
+func Read(r io.Reader, format Format) (*Document, error) {
+	switch format {
+	case FormatJSON:
+		return readJSON(r)
+	case FormatCSV:
+		return readCSV(r)
+	}
+	return nil, fmt.Errorf("unsupported import format %q", format)
+}
+
+func readJSON(r io.Reader) (*Document, error) {
+	var doc Document
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to read JSON: %w", err)
+	}
+	if doc.Version > FormatVersion {
+		return nil, fmt.Errorf("export version %d is newer than supported version %d", doc.Version, FormatVersion)
+	}
+	return &doc, nil
+}
+
+func readCSV(r io.Reader) (*Document, error) {
+	in := csv.NewReader(r)
+	header, err := in.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, required := range []string{"habit_id", "description"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("CSV is missing the %s column", required)
+		}
+	}
+
+	doc := &Document{Version: FormatVersion, Habits: []Habit{}, Records: []Record{}}
+	seen := make(map[int]bool)
+	for line := 2; ; line++ {
+		row, err := in.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV: %w", err)
+		}
+
+		get := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+
+		id, err := strconv.Atoi(get("habit_id"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid habit_id %q", line, get("habit_id"))
+		}
+
+		// The first row of a habit carries its metadata
+		if !seen[id] {
+			h, err := csvHabit(id, get)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			doc.Habits = append(doc.Habits, h)
+			seen[id] = true
+		}
+
+		if get("date") == "" {
+			continue
+		}
+		rec, err := csvRecord(id, get)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		doc.Records = append(doc.Records, rec)
+	}
+
+	return doc, nil
+}
+
+func csvHabit(id int, get func(string) string) (Habit, error) {
+	h := Habit{
+		ID:           id,
+		Description:  get("description"),
+		StartDate:    get("start_date"),
+		Color:        get("color"),
+		Days:         []int{},
+		TargetPeriod: get("target_period"),
+		Unit:         get("unit"),
+		CreatedAt:    get("habit_created_at"),
+	}
+
+	for _, field := range strings.Fields(get("days")) {
+		d, err := strconv.Atoi(field)
+		if err != nil {
+			return h, fmt.Errorf("invalid days %q", get("days"))
+		}
+		h.Days = append(h.Days, d)
+	}
+
+	var err error
+	if h.TargetCount, err = atoiOrZero(get("target_count")); err != nil {
+		return h, fmt.Errorf("invalid target_count: %w", err)
+	}
+	if h.DailyTarget, err = floatOrZero(get("daily_target")); err != nil {
+		return h, fmt.Errorf("invalid daily_target: %w", err)
+	}
+	if h.GraceDays, err = atoiOrZero(get("grace_days")); err != nil {
+		return h, fmt.Errorf("invalid grace_days: %w", err)
+	}
+	return h, nil
+}
+
+func csvRecord(habitID int, get func(string) string) (Record, error) {
+	r := Record{
+		HabitID:     habitID,
+		Date:        get("date"),
+		Status:      get("status"),
+		Note:        get("note"),
+		CompletedAt: get("completed_at"),
+		CreatedAt:   get("record_created_at"),
+	}
+	if r.Status == "" {
+		r.Status = "done"
+	}
+
+	// A missing value means a plain completion
+	if get("value") == "" {
+		if r.Status == "done" {
+			r.Value = 1
+		}
+		return r, nil
+	}
+
+	var err error
+	if r.Value, err = strconv.ParseFloat(get("value"), 64); err != nil {
+		return r, fmt.Errorf("invalid value %q", get("value"))
+	}
+	return r, nil
+}
+
+func atoiOrZero(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
+
+func floatOrZero(value string) (float64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(value, 64)
+}
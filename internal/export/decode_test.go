@@ -0,0 +1,62 @@
+package export
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRead_CSVRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, testDocument(), FormatCSV); err != nil {
+		t.Fatalf("failed to write CSV: %v", err)
+	}
+
+	doc, err := Read(&buf, FormatCSV)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(doc.Habits) != 2 || len(doc.Records) != 2 {
+		t.Fatalf("expected 2 habits and 2 records, got %d and %d", len(doc.Habits), len(doc.Records))
+	}
+	if doc.Habits[0].Description != "Gym, weights" || len(doc.Habits[0].Days) != 3 {
+		t.Errorf("unexpected habit %+v", doc.Habits[0])
+	}
+	if doc.Records[1].Status != "skipped" || doc.Records[1].Note != "sick" {
+		t.Errorf("unexpected record %+v", doc.Records[1])
+	}
+}
+
+func TestRead_CSVMinimalColumns(t *testing.T) {
+	input := "description,habit_id,date\nRead,7,2025-01-02\nRead,7,2025-01-03\nWalk,8,\n"
+
+	doc, err := Read(strings.NewReader(input), FormatCSV)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(doc.Habits) != 2 || len(doc.Records) != 2 {
+		t.Fatalf("expected 2 habits and 2 records, got %d and %d", len(doc.Habits), len(doc.Records))
+	}
+	if r := doc.Records[0]; r.HabitID != 7 || r.Status != "done" || r.Value != 1 {
+		t.Errorf("expected plain completion defaults, got %+v", r)
+	}
+}
+
+func TestRead_CSVErrors(t *testing.T) {
+	inputs := []string{
+		"habit_id,date\n1,2025-01-01\n",
+		"habit_id,description\nabc,Read\n",
+		"habit_id,description,date,value\n1,Read,2025-01-01,lots\n",
+	}
+	for _, input := range inputs {
+		if _, err := Read(strings.NewReader(input), FormatCSV); err == nil {
+			t.Errorf("expected error for %q", input)
+		}
+	}
+}
+
+func TestRead_JSONNewerVersion(t *testing.T) {
+	if _, err := Read(strings.NewReader(`{"version": 99}`), FormatJSON); err == nil {
+		t.Error("expected error for a newer export version")
+	}
+}
@@ -0,0 +1,107 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// CSVHeader is the column layout of CSV exports. Each row is a record
+// together with its habit; habits without records get one row with empty
+// record columns. Days are space-separated weekday numbers (0 = Sunday).
+var CSVHeader = []string{
+	"habit_id", "description", "start_date", "color", "days",
+	"target_count", "target_period", "daily_target", "unit", "grace_days", "habit_created_at",
+	"date", "status", "value", "note", "completed_at", "record_created_at",
+}
+
+// Write encodes the document in the given format
+func Write(w io.Writer, doc *Document, format Format) error {
+	switch format {
+	case FormatJSON:
+		return writeJSON(w, doc)
+	case FormatCSV:
+		return writeCSV(w, doc)
+	}
+	return fmt.Errorf("unsupported export format %q", format)
+}
+
+func writeJSON(w io.Writer, doc *Document) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("failed to write JSON export: %w", err)
+	}
+	return nil
+}
+
+func writeCSV(w io.Writer, doc *Document) error {
+	records := make(map[int][]Record)
+	for _, r := range doc.Records {
+		records[r.HabitID] = append(records[r.HabitID], r)
+	}
+
+	out := csv.NewWriter(w)
+	if err := out.Write(CSVHeader); err != nil {
+		return fmt.Errorf("failed to write CSV export: %w", err)
+	}
+
+	for _, h := range doc.Habits {
+		habitColumns := []string{
+			strconv.Itoa(h.ID),
+			h.Description,
+			h.StartDate,
+			h.Color,
+			formatDays(h.Days),
+			strconv.Itoa(h.TargetCount),
+			h.TargetPeriod,
+			formatNumber(h.DailyTarget),
+			h.Unit,
+			strconv.Itoa(h.GraceDays),
+			h.CreatedAt,
+		}
+
+		if len(records[h.ID]) == 0 {
+			row := append(habitColumns, "", "", "", "", "", "")
+			if err := out.Write(row); err != nil {
+				return fmt.Errorf("failed to write CSV export: %w", err)
+			}
+			continue
+		}
+
+		for _, r := range records[h.ID] {
+			row := append(append([]string{}, habitColumns...),
+				r.Date,
+				r.Status,
+				formatNumber(r.Value),
+				r.Note,
+				r.CompletedAt,
+				r.CreatedAt,
+			)
+			if err := out.Write(row); err != nil {
+				return fmt.Errorf("failed to write CSV export: %w", err)
+			}
+		}
+	}
+
+	out.Flush()
+	if err := out.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV export: %w", err)
+	}
+	return nil
+}
+
+func formatDays(days []int) string {
+	parts := make([]string, len(days))
+	for i, d := range days {
+		parts[i] = strconv.Itoa(d)
+	}
+	return strings.Join(parts, " ")
+}
+
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
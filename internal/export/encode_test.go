@@ -0,0 +1,79 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testDocument() *Document {
+	return &Document{
+		Version:    FormatVersion,
+		ExportedAt: time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC),
+		Habits: []Habit{
+			{ID: 1, Description: "Gym, weights", StartDate: "2025-01-01", Days: []int{1, 3, 5}},
+			{ID: 2, Description: "Read", StartDate: "2025-01-01", Days: []int{}},
+		},
+		Records: []Record{
+			{HabitID: 1, Date: "2025-01-01", Status: "done", Value: 1},
+			{HabitID: 1, Date: "2025-01-03", Status: "skipped", Note: "sick"},
+		},
+	}
+}
+
+func TestWrite_CSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, testDocument(), FormatCSV); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("expected valid CSV, got %v", err)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("expected header and 3 rows, got %d", len(rows))
+	}
+	if strings.Join(rows[0], ",") != strings.Join(CSVHeader, ",") {
+		t.Errorf("unexpected header %v", rows[0])
+	}
+	if rows[1][1] != "Gym, weights" || rows[1][4] != "1 3 5" || rows[1][11] != "2025-01-01" {
+		t.Errorf("unexpected record row %v", rows[1])
+	}
+	if rows[2][12] != "skipped" || rows[2][14] != "sick" {
+		t.Errorf("unexpected skipped row %v", rows[2])
+	}
+	if rows[3][0] != "2" || rows[3][11] != "" {
+		t.Errorf("expected habit without records to have empty record columns, got %v", rows[3])
+	}
+}
+
+func TestWrite_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, testDocument(), FormatJSON); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var doc Document
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("expected valid JSON, got %v", err)
+	}
+	if doc.Version != FormatVersion || len(doc.Habits) != 2 || len(doc.Records) != 2 {
+		t.Errorf("unexpected document %+v", doc)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if f, err := ParseFormat(""); err != nil || f != FormatJSON {
+		t.Errorf("expected JSON default, got %q, %v", f, err)
+	}
+	if f, err := ParseFormat("csv"); err != nil || f != FormatCSV {
+		t.Errorf("expected CSV, got %q, %v", f, err)
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("expected error for unsupported format")
+	}
+}
@@ -0,0 +1,60 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/epalmerini/abitudini/internal/shared"
+)
+
+// HandlerService interface for dependency injection
+type HandlerService interface {
+	Export(userID int) (*Document, error)
+}
+
+type Handler struct {
+	shared.BaseHandler
+	service HandlerService
+}
+
+func NewHandler(service HandlerService) *Handler {
+	return &Handler{service: service}
+}
+
+// Export downloads every habit and record as JSON or CSV, chosen by the
+// format query parameter
+func (h *Handler) Export(w http.ResponseWriter, r *http.Request) {
+	if !h.ValidateMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	format, err := ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		h.RespondError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	doc, err := h.service.Export(h.UserID(r))
+	if err != nil {
+		h.RespondError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Encode fully before writing so a failure can still become an error response
+	var buf bytes.Buffer
+	if err := Write(&buf, doc, format); err != nil {
+		h.RespondError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	contentType := "application/json"
+	if format == FormatCSV {
+		contentType = "text/csv; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="abitudini-%s.%s"`,
+		time.Now().Format("2006-01-02"), format))
+	w.Write(buf.Bytes())
+}
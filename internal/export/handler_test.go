@@ -0,0 +1,82 @@
+package export
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type mockExportHandlerService struct {
+	doc *Document
+	err error
+}
+
+func (m *mockExportHandlerService) Export(userID int) (*Document, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.doc, nil
+}
+
+func TestExport_CSV(t *testing.T) {
+	handler := NewHandler(&mockExportHandlerService{doc: testDocument()})
+
+	req := httptest.NewRequest("GET", "/api/export?format=csv", nil)
+	w := httptest.NewRecorder()
+
+	handler.Export(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("expected CSV content type, got %s", ct)
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.Contains(cd, ".csv") {
+		t.Errorf("expected CSV attachment, got %s", cd)
+	}
+}
+
+func TestExport_DefaultsToJSON(t *testing.T) {
+	handler := NewHandler(&mockExportHandlerService{doc: testDocument()})
+
+	req := httptest.NewRequest("GET", "/api/export", nil)
+	w := httptest.NewRecorder()
+
+	handler.Export(w, req)
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected JSON content type, got %s", ct)
+	}
+	if !strings.Contains(w.Body.String(), `"version": 1`) {
+		t.Errorf("expected export document, got %s", w.Body.String())
+	}
+}
+
+func TestExport_InvalidFormat(t *testing.T) {
+	handler := NewHandler(&mockExportHandlerService{doc: testDocument()})
+
+	req := httptest.NewRequest("GET", "/api/export?format=xml", nil)
+	w := httptest.NewRecorder()
+
+	handler.Export(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+}
+
+func TestExport_ServiceError(t *testing.T) {
+	handler := NewHandler(&mockExportHandlerService{err: errors.New("db down")})
+
+	req := httptest.NewRequest("GET", "/api/export", nil)
+	w := httptest.NewRecorder()
+
+	handler.Export(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", w.Code)
+	}
+}
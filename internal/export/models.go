@@ -0,0 +1,64 @@
+package export
+
+import (
+	"fmt"
+	"time"
+)
+
+// FormatVersion identifies the layout of exported documents. It only changes
+// when fields are renamed or removed.
+const FormatVersion = 1
+
+// Format is an export file format
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatCSV  Format = "csv"
+)
+
+// ParseFormat validates a requested format, defaulting to JSON
+func ParseFormat(value string) (Format, error) {
+	switch Format(value) {
+	case "", FormatJSON:
+		return FormatJSON, nil
+	case FormatCSV:
+		return FormatCSV, nil
+	}
+	return "", fmt.Errorf("unsupported export format %q", value)
+}
+
+// Document is a complete dump of every habit and record
+type Document struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+	Habits     []Habit   `json:"habits"`
+	Records    []Record  `json:"records"`
+}
+
+// Habit is an exported habit. Dates are YYYY-MM-DD and timestamps RFC 3339
+// in UTC.
+type Habit struct {
+	ID           int     `json:"id"`
+	Description  string  `json:"description"`
+	StartDate    string  `json:"start_date"`
+	Color        string  `json:"color"`
+	Days         []int   `json:"days"`
+	TargetCount  int     `json:"target_count"`
+	TargetPeriod string  `json:"target_period"`
+	DailyTarget  float64 `json:"daily_target"`
+	Unit         string  `json:"unit"`
+	GraceDays    int     `json:"grace_days"`
+	CreatedAt    string  `json:"created_at"`
+}
+
+// Record is an exported record, linked to its habit by HabitID
+type Record struct {
+	HabitID     int     `json:"habit_id"`
+	Date        string  `json:"date"`
+	Status      string  `json:"status"`
+	Value       float64 `json:"value"`
+	Note        string  `json:"note"`
+	CompletedAt string  `json:"completed_at"`
+	CreatedAt   string  `json:"created_at"`
+}
@@ -0,0 +1,40 @@
+package export
+
+import (
+	"fmt"
+	"time"
+)
+
+// StoreAdapter defines the interface for data access
+type StoreAdapter interface {
+	GetHabits(userID int) ([]Habit, error)
+	GetRecords(userID int) ([]Record, error)
+}
+
+type Service struct {
+	store StoreAdapter
+}
+
+func NewService(store StoreAdapter) *Service {
+	return &Service{store: store}
+}
+
+// Export collects every habit and record of userID into a single document
+func (s *Service) Export(userID int) (*Document, error) {
+	habits, err := s.store.GetHabits(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export habits: %w", err)
+	}
+
+	records, err := s.store.GetRecords(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export records: %w", err)
+	}
+
+	return &Document{
+		Version:    FormatVersion,
+		ExportedAt: time.Now().UTC().Truncate(time.Second),
+		Habits:     habits,
+		Records:    records,
+	}, nil
+}
@@ -0,0 +1,93 @@
+package export
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/epalmerini/abitudini/internal/habit"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// GetHabits returns every habit owned by userID ordered by ID
+func (s *Store) GetHabits(userID int) ([]Habit, error) {
+	rows, err := s.db.Query(
+		`SELECT id, description, start_date, color, days, target_count, target_period,
+		        daily_target, unit, grace_days, created_at
+		 FROM habits WHERE user_id = ? ORDER BY id`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query habits: %w", err)
+	}
+	defer rows.Close()
+
+	habits := []Habit{}
+	for rows.Next() {
+		var h Habit
+		var days, createdAt string
+		if err := rows.Scan(&h.ID, &h.Description, &h.StartDate, &h.Color, &days, &h.TargetCount,
+			&h.TargetPeriod, &h.DailyTarget, &h.Unit, &h.GraceDays, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan habit: %w", err)
+		}
+
+		h.Days = []int{}
+		for _, d := range habit.DecodeDays(days) {
+			h.Days = append(h.Days, int(d))
+		}
+		h.CreatedAt = timestamp(createdAt)
+
+		habits = append(habits, h)
+	}
+
+	return habits, rows.Err()
+}
+
+// GetRecords returns every record of userID's habits ordered by habit and
+// date. Records left behind by deleted habits are not exported.
+func (s *Store) GetRecords(userID int) ([]Record, error) {
+	rows, err := s.db.Query(
+		`SELECT habit_id, record_date, status, value, note, completed_at, created_at
+		 FROM records
+		 WHERE habit_id IN (SELECT id FROM habits WHERE user_id = ?)
+		 ORDER BY habit_id, record_date`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query records: %w", err)
+	}
+	defer rows.Close()
+
+	records := []Record{}
+	for rows.Next() {
+		var r Record
+		var completedAt, createdAt string
+		if err := rows.Scan(&r.HabitID, &r.Date, &r.Status, &r.Value, &r.Note, &completedAt, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan record: %w", err)
+		}
+
+		r.CompletedAt = timestamp(completedAt)
+		r.CreatedAt = timestamp(createdAt)
+
+		records = append(records, r)
+	}
+
+	return records, rows.Err()
+}
+
+// timestamp converts a stored SQLite timestamp to RFC 3339, keeping values
+// in any other layout as they are
+func timestamp(value string) string {
+	t, err := time.Parse("2006-01-02 15:04:05", value)
+	if err != nil {
+		return value
+	}
+	return t.UTC().Format(time.RFC3339)
+}
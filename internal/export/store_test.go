@@ -0,0 +1,74 @@
+package export
+
+import (
+	"context"
+	"testing"
+
+	"github.com/epalmerini/abitudini/internal/testhelpers"
+)
+
+func TestExportStore_GetHabitsAndRecords(t *testing.T) {
+	db := testhelpers.NewTestDB(t)
+	store := NewStore(db)
+
+	if _, err := db.Exec(
+		`INSERT INTO habits (id, user_id, description, start_date, color, days, daily_target, unit, created_at)
+		 VALUES (1, 1, 'Water', '2025-01-01', 'blue', '1,3', 8, 'glasses', '2025-01-01 08:30:00'),
+		        (3, 2, 'Run', '2025-01-01', 'red', '', 0, '', '2025-01-01 08:30:00')`,
+	); err != nil {
+		t.Fatalf("failed to create habit: %v", err)
+	}
+	if _, err := db.Exec(
+		`INSERT INTO records (habit_id, record_date, completed_at, value, note, created_at)
+		 VALUES (1, '2025-01-02', '2025-01-02 20:00:00', 8, 'all of them', '2025-01-02 20:00:00'),
+		        (3, '2025-01-02', '2025-01-02 07:00:00', 1, '', '2025-01-02 07:00:00')`,
+	); err != nil {
+		t.Fatalf("failed to create records: %v", err)
+	}
+
+	// Databases from before foreign keys were enforced can hold records of
+	// deleted habits
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("failed to get connection: %v", err)
+	}
+	conn.ExecContext(context.Background(), `PRAGMA foreign_keys = OFF`)
+	if _, err := conn.ExecContext(context.Background(),
+		`INSERT INTO records (habit_id, record_date, completed_at, value, note, created_at)
+		 VALUES (2, '2025-01-02', '2025-01-02 20:00:00', 1, '', '2025-01-02 20:00:00')`,
+	); err != nil {
+		t.Fatalf("failed to create orphaned record: %v", err)
+	}
+	conn.ExecContext(context.Background(), `PRAGMA foreign_keys = ON`)
+	conn.Close()
+
+	habits, err := store.GetHabits(1)
+	if err != nil {
+		t.Fatalf("failed to get habits: %v", err)
+	}
+	if len(habits) != 1 {
+		t.Fatalf("expected only user 1's habit, got %d", len(habits))
+	}
+	h := habits[0]
+	if h.StartDate != "2025-01-01" || h.Unit != "glasses" || len(h.Days) != 2 || h.Days[0] != 1 {
+		t.Errorf("unexpected habit %+v", h)
+	}
+	if h.CreatedAt != "2025-01-01T08:30:00Z" {
+		t.Errorf("expected RFC 3339 created_at, got %s", h.CreatedAt)
+	}
+
+	records, err := store.GetRecords(1)
+	if err != nil {
+		t.Fatalf("failed to get records: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("expected orphaned and other users' records to be skipped, got %d records", len(records))
+	}
+	r := records[0]
+	if r.Date != "2025-01-02" || r.Status != "done" || r.Value != 8 || r.Note != "all of them" {
+		t.Errorf("unexpected record %+v", r)
+	}
+	if r.CompletedAt != "2025-01-02T20:00:00Z" {
+		t.Errorf("expected RFC 3339 completed_at, got %s", r.CompletedAt)
+	}
+}
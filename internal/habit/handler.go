@@ -1,7 +1,11 @@
 package habit
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/epalmerini/abitudini/internal/shared"
@@ -9,11 +13,11 @@ import (
 
 // HandlerService interface for dependency injection
 type HandlerService interface {
-	Create(h *Habit) (int, error)
-	Update(h *Habit) error
-	GetByID(habitID int) (*Habit, error)
-	GetAll() ([]Habit, error)
-	Delete(habitID int) error
+	Create(userID int, h *Habit) (int, error)
+	Update(userID int, h *Habit) error
+	GetByID(userID, habitID int) (*Habit, error)
+	GetAll(userID int) ([]Habit, error)
+	Delete(userID, habitID int) error
 }
 
 type Handler struct {
@@ -30,9 +34,9 @@ func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse form-encoded request
-	if err := r.ParseForm(); err != nil {
-		h.WriteError(w, "Invalid request", http.StatusBadRequest)
+	// Parse form-encoded or JSON request
+	if err := h.ParseInput(r); err != nil {
+		h.RespondError(w, r, "Invalid request", http.StatusBadRequest)
 		return
 	}
 
@@ -40,25 +44,34 @@ func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
 	startDateStr := r.FormValue("start_date")
 	color := r.FormValue("color")
 
-	startDate, _ := time.Parse("2006-01-02", startDateStr)
+	startDate := parseStartDate(startDateStr)
 
 	// Convert to domain types
 	domainHabit := &Habit{
 		Description: description,
 		StartDate:   startDate,
 		Color:       color,
+		Days:        parseWeekdays(r.Form["days"]),
 	}
+	domainHabit.TargetCount, domainHabit.TargetPeriod = parseFrequency(r.FormValue("target_count"), r.FormValue("target_period"))
+	domainHabit.DailyTarget, domainHabit.Unit = parseQuantity(r.FormValue("daily_target"), r.FormValue("unit"))
+	domainHabit.GraceDays = parseGraceDays(r.FormValue("grace_days"))
 
-	habitID, err := h.service.Create(domainHabit)
+	habitID, err := h.service.Create(h.UserID(r), domainHabit)
 	if err != nil {
-		h.WriteError(w, err.Error(), http.StatusInternalServerError)
+		h.RespondError(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// Get created habit and return HTML
-	habit, err := h.service.GetByID(habitID)
+	habit, err := h.service.GetByID(h.UserID(r), habitID)
 	if err != nil {
-		h.WriteError(w, err.Error(), http.StatusInternalServerError)
+		h.RespondError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if h.WantsJSON(r) {
+		h.WriteJSON(w, http.StatusCreated, habit)
 		return
 	}
 
@@ -70,9 +83,17 @@ func (h *Handler) GetAll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	domainHabits, err := h.service.GetAll()
+	domainHabits, err := h.service.GetAll(h.UserID(r))
 	if err != nil {
-		h.WriteError(w, err.Error(), http.StatusInternalServerError)
+		h.RespondError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if h.WantsJSON(r) {
+		if domainHabits == nil {
+			domainHabits = []Habit{}
+		}
+		h.WriteJSON(w, http.StatusOK, domainHabits)
 		return
 	}
 
@@ -95,13 +116,18 @@ func (h *Handler) GetByID(w http.ResponseWriter, r *http.Request) {
 
 	habitID, err := h.ExtractIntPathParam(r, "id")
 	if err != nil {
-		h.WriteError(w, "Invalid habit ID", http.StatusBadRequest)
+		h.RespondError(w, r, "Invalid habit ID", http.StatusBadRequest)
 		return
 	}
 
-	domainHabit, err := h.service.GetByID(habitID)
+	domainHabit, err := h.service.GetByID(h.UserID(r), habitID)
 	if err != nil {
-		h.WriteError(w, err.Error(), http.StatusNotFound)
+		h.RespondError(w, r, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if h.WantsJSON(r) {
+		h.WriteJSON(w, http.StatusOK, domainHabit)
 		return
 	}
 
@@ -115,13 +141,13 @@ func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
 
 	habitID, err := h.ExtractIntPathParam(r, "id")
 	if err != nil {
-		h.WriteError(w, "Invalid habit ID", http.StatusBadRequest)
+		h.RespondError(w, r, "Invalid habit ID", http.StatusBadRequest)
 		return
 	}
 
-	// Parse form-encoded request
-	if err := r.ParseForm(); err != nil {
-		h.WriteError(w, "Invalid request", http.StatusBadRequest)
+	// Parse form-encoded or JSON request
+	if err := h.ParseInput(r); err != nil {
+		h.RespondError(w, r, "Invalid request", http.StatusBadRequest)
 		return
 	}
 
@@ -129,7 +155,7 @@ func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
 	startDateStr := r.FormValue("start_date")
 	color := r.FormValue("color")
 
-	startDate, _ := time.Parse("2006-01-02", startDateStr)
+	startDate := parseStartDate(startDateStr)
 
 	// Convert to domain types
 	domainHabit := &Habit{
@@ -137,17 +163,26 @@ func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
 		Description: description,
 		StartDate:   startDate,
 		Color:       color,
+		Days:        parseWeekdays(r.Form["days"]),
 	}
+	domainHabit.TargetCount, domainHabit.TargetPeriod = parseFrequency(r.FormValue("target_count"), r.FormValue("target_period"))
+	domainHabit.DailyTarget, domainHabit.Unit = parseQuantity(r.FormValue("daily_target"), r.FormValue("unit"))
+	domainHabit.GraceDays = parseGraceDays(r.FormValue("grace_days"))
 
-	if err := h.service.Update(domainHabit); err != nil {
-		h.WriteError(w, err.Error(), http.StatusInternalServerError)
+	if err := h.service.Update(h.UserID(r), domainHabit); err != nil {
+		h.RespondError(w, r, err.Error(), errorStatus(err))
 		return
 	}
 
 	// Get updated habit and return HTML
-	habit, err := h.service.GetByID(habitID)
+	habit, err := h.service.GetByID(h.UserID(r), habitID)
 	if err != nil {
-		h.WriteError(w, err.Error(), http.StatusInternalServerError)
+		h.RespondError(w, r, err.Error(), errorStatus(err))
+		return
+	}
+
+	if h.WantsJSON(r) {
+		h.WriteJSON(w, http.StatusOK, habit)
 		return
 	}
 
@@ -161,12 +196,17 @@ func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
 
 	habitID, err := h.ExtractIntPathParam(r, "id")
 	if err != nil {
-		h.WriteError(w, "Invalid habit ID", http.StatusBadRequest)
+		h.RespondError(w, r, "Invalid habit ID", http.StatusBadRequest)
 		return
 	}
 
-	if err := h.service.Delete(habitID); err != nil {
-		h.WriteError(w, err.Error(), http.StatusInternalServerError)
+	if err := h.service.Delete(h.UserID(r), habitID); err != nil {
+		h.RespondError(w, r, err.Error(), errorStatus(err))
+		return
+	}
+
+	if h.WantsJSON(r) {
+		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
@@ -174,3 +214,71 @@ func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
 }
+
+// parseStartDate accepts a plain date as sent by forms, or a full timestamp
+// as returned in JSON responses
+func parseStartDate(value string) time.Time {
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		return date
+	}
+	date, _ := time.Parse(time.RFC3339, value)
+	return date
+}
+
+// parseWeekdays converts submitted weekday numbers (Sunday = 0) into a schedule,
+// skipping anything out of range
+func parseWeekdays(values []string) []time.Weekday {
+	var days []time.Weekday
+	for _, v := range values {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > 6 {
+			continue
+		}
+		days = append(days, time.Weekday(n))
+	}
+	return days
+}
+
+// parseFrequency validates a submitted frequency target. An unknown period or
+// a non-positive count disables the target.
+func parseFrequency(countStr, periodStr string) (int, Period) {
+	count, err := strconv.Atoi(countStr)
+	period := Period(periodStr)
+	if err != nil || count <= 0 || (period != PeriodWeek && period != PeriodMonth) {
+		return 0, ""
+	}
+	return count, period
+}
+
+// errorStatus maps a service error to its HTTP status
+func errorStatus(err error) int {
+	if errors.Is(err, ErrHabitNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// parseQuantity validates a submitted daily target and unit. A missing or
+// non-positive target makes the habit a plain done/not done habit.
+func parseQuantity(targetStr, unit string) (float64, string) {
+	target, err := strconv.ParseFloat(targetStr, 64)
+	if err != nil || target <= 0 || math.IsInf(target, 0) {
+		return 0, ""
+	}
+	return target, strings.TrimSpace(unit)
+}
+
+// maxGraceDays caps the monthly grace day budget
+const maxGraceDays = 31
+
+// parseGraceDays validates a submitted monthly grace day budget
+func parseGraceDays(value string) int {
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 0 {
+		return 0
+	}
+	if days > maxGraceDays {
+		return maxGraceDays
+	}
+	return days
+}
@@ -1,46 +1,58 @@
 package habit
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/epalmerini/abitudini/internal/shared"
+	"github.com/epalmerini/abitudini/internal/testhelpers"
 )
 
 type mockHandlerService struct {
 	createID int
 	habits   []Habit
 	habit    *Habit
+	created  *Habit
+	userID   int
 	err      error
 }
 
-func (m *mockHandlerService) Create(h *Habit) (int, error) {
+func (m *mockHandlerService) Create(userID int, h *Habit) (int, error) {
+	m.created = h
+	m.userID = userID
 	if m.err != nil {
 		return 0, m.err
 	}
 	return m.createID, nil
 }
 
-func (m *mockHandlerService) Update(h *Habit) error {
+func (m *mockHandlerService) Update(userID int, h *Habit) error {
 	return m.err
 }
 
-func (m *mockHandlerService) GetByID(habitID int) (*Habit, error) {
+func (m *mockHandlerService) GetByID(userID, habitID int) (*Habit, error) {
 	if m.err != nil {
 		return nil, m.err
 	}
 	return m.habit, nil
 }
 
-func (m *mockHandlerService) GetAll() ([]Habit, error) {
+func (m *mockHandlerService) GetAll(userID int) ([]Habit, error) {
+	m.userID = userID
 	if m.err != nil {
 		return nil, m.err
 	}
 	return m.habits, nil
 }
 
-func (m *mockHandlerService) Delete(habitID int) error {
+func (m *mockHandlerService) Delete(userID, habitID int) error {
 	return m.err
 }
 
@@ -110,6 +122,21 @@ func TestGetAll_Success(t *testing.T) {
 	}
 }
 
+func TestGetAll_ScopedToUser(t *testing.T) {
+	service := &mockHandlerService{}
+	handler := NewHandler(service)
+
+	req := httptest.NewRequest("GET", "/api/habits", nil)
+	req = req.WithContext(shared.WithUser(req.Context(), shared.User{ID: 7, Username: "alice"}))
+	w := httptest.NewRecorder()
+
+	handler.GetAll(w, req)
+
+	if service.userID != 7 {
+		t.Errorf("expected the authenticated user 7, got %d", service.userID)
+	}
+}
+
 func TestGetAll_WrongMethod(t *testing.T) {
 	handler := NewHandler(&mockHandlerService{})
 	req := httptest.NewRequest("POST", "/api/habits", nil)
@@ -226,6 +253,29 @@ func TestUpdate_Success(t *testing.T) {
 	}
 }
 
+func TestUpdate_RendersTodayProgress(t *testing.T) {
+	store := &mockHabitStore{habit: &Habit{ID: 1, Description: "Read"}}
+	handler := NewHandler(NewService(store, &mockRecordService{completed: true}))
+
+	update := func(accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/api/habits/1", strings.NewReader("description=Read&start_date=2025-01-01"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", accept)
+		req.SetPathValue("id", "1")
+		w := httptest.NewRecorder()
+		handler.Update(w, req)
+		return w
+	}
+
+	// A habit done today must not offer "Done Today" again after an edit
+	if w := update("text/html"); w.Code != http.StatusOK || strings.Contains(w.Body.String(), "done-today") {
+		t.Errorf("expected the card to show the habit as done today, got %d %s", w.Code, w.Body.String())
+	}
+	if w := update("application/json"); !strings.Contains(w.Body.String(), `"completed_today":true`) {
+		t.Errorf("expected completed_today in the JSON response, got %s", w.Body.String())
+	}
+}
+
 func TestUpdate_WrongMethod(t *testing.T) {
 	handler := NewHandler(&mockHandlerService{})
 	req := httptest.NewRequest("GET", "/api/habits/1", nil)
@@ -324,4 +374,244 @@ func TestDelete_ServiceError(t *testing.T) {
 	}
 }
 
+func TestParseWeekdays(t *testing.T) {
+	days := parseWeekdays([]string{"1", "3", "7", "abc", "0"})
+
+	if len(days) != 3 {
+		t.Fatalf("expected 3 days, got %d", len(days))
+	}
+	if days[0] != time.Monday || days[1] != time.Wednesday || days[2] != time.Sunday {
+		t.Errorf("unexpected days: %v", days)
+	}
+}
+
+func TestRenderHabit_HidesDoneTodayWhenNotDue(t *testing.T) {
+	tomorrow := time.Now().AddDate(0, 0, 1).Weekday()
+	h := &Habit{ID: 1, Description: "Test", Days: []time.Weekday{tomorrow}}
+
+	html := RenderHabit(h)
+
+	if strings.Contains(html, "done-today") {
+		t.Error("expected Done Today button to be hidden on unscheduled day")
+	}
+}
+
+func TestParseFrequency(t *testing.T) {
+	count, period := parseFrequency("3", "week")
+	if count != 3 || period != PeriodWeek {
+		t.Errorf("expected 3 per week, got %d per %q", count, period)
+	}
+
+	count, period = parseFrequency("3", "year")
+	if count != 0 || period != "" {
+		t.Errorf("expected no target for unknown period, got %d per %q", count, period)
+	}
 
+	count, period = parseFrequency("", "month")
+	if count != 0 || period != "" {
+		t.Errorf("expected no target for missing count, got %d per %q", count, period)
+	}
+}
+
+func TestRenderHabit_ShowsPeriodProgress(t *testing.T) {
+	h := &Habit{ID: 1, Description: "Run", TargetCount: 3, TargetPeriod: PeriodWeek, PeriodProgress: 2}
+
+	html := RenderHabit(h)
+
+	if !strings.Contains(html, "2/3 this week") {
+		t.Error("expected period progress in rendered card")
+	}
+}
+
+func TestParseQuantity(t *testing.T) {
+	target, unit := parseQuantity("8", " glasses ")
+	if target != 8 || unit != "glasses" {
+		t.Errorf("expected 8 glasses, got %v %q", target, unit)
+	}
+
+	target, unit = parseQuantity("-1", "pages")
+	if target != 0 || unit != "" {
+		t.Errorf("expected no target for negative value, got %v %q", target, unit)
+	}
+}
+
+func TestParseGraceDays(t *testing.T) {
+	cases := map[string]int{"2": 2, "": 0, "-1": 0, "abc": 0, "100": maxGraceDays}
+	for input, want := range cases {
+		if got := parseGraceDays(input); got != want {
+			t.Errorf("parseGraceDays(%q) = %d, want %d", input, got, want)
+		}
+	}
+}
+
+func TestRenderHabit_QuantitativeShowsAddAmount(t *testing.T) {
+	h := &Habit{ID: 1, Description: "Water", Unit: "glasses", DailyTarget: 8, TodayValue: 3}
+
+	html := RenderHabit(h)
+
+	if !strings.Contains(html, "add-amount") {
+		t.Error("expected add amount control for quantitative habit")
+	}
+	if strings.Contains(html, "done-today") {
+		t.Error("expected Done Today button to be replaced by add amount control")
+	}
+	if !strings.Contains(html, "3/8 glasses today") {
+		t.Error("expected today's progress in rendered card")
+	}
+}
+
+func TestRenderHabit_SkipToday(t *testing.T) {
+	html := RenderHabit(&Habit{ID: 1, Description: "Run"})
+	if !strings.Contains(html, "skip-today") {
+		t.Error("expected Skip today action for a habit due today")
+	}
+
+	html = RenderHabit(&Habit{ID: 1, Description: "Run", SkippedToday: true})
+	if strings.Contains(html, "skip-today") || strings.Contains(html, "done-today") {
+		t.Error("expected no actions once today is skipped")
+	}
+}
+
+func TestCreate_JSON(t *testing.T) {
+	habit := &Habit{ID: 1, Description: "Gym", Color: "blue"}
+	service := &mockHandlerService{createID: 1, habit: habit}
+	handler := NewHandler(service)
+
+	body := `{"description": "Gym", "start_date": "2025-01-01", "days": [1, 3, 5], "grace_days": 2}`
+	req := httptest.NewRequest("POST", "/api/habits", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.Create(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", w.Code)
+	}
+	if service.created.Description != "Gym" || len(service.created.Days) != 3 || service.created.GraceDays != 2 {
+		t.Errorf("expected JSON body to be parsed, got %+v", service.created)
+	}
+	if service.created.StartDate.Format("2006-01-02") != "2025-01-01" {
+		t.Errorf("expected start date 2025-01-01, got %v", service.created.StartDate)
+	}
+
+	var got Habit
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("expected JSON response, got error %v", err)
+	}
+	if got.ID != 1 || got.Description != "Gym" {
+		t.Errorf("unexpected habit in response: %+v", got)
+	}
+}
+
+func TestCreate_InvalidJSON(t *testing.T) {
+	handler := NewHandler(&mockHandlerService{})
+
+	req := httptest.NewRequest("POST", "/api/habits", strings.NewReader(`{"description":`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.Create(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `"error":"Invalid request"`) {
+		t.Errorf("expected JSON error, got %s", w.Body.String())
+	}
+}
+
+func TestGetAll_JSONEmpty(t *testing.T) {
+	handler := NewHandler(&mockHandlerService{})
+
+	req := httptest.NewRequest("GET", "/api/habits", nil)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.GetAll(w, req)
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected JSON content type, got %s", ct)
+	}
+	if strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Errorf("expected empty JSON array, got %s", w.Body.String())
+	}
+}
+
+func TestGetByID_JSONNotFound(t *testing.T) {
+	handler := NewHandler(&mockHandlerService{err: errors.New("habit not found")})
+
+	req := httptest.NewRequest("GET", "/api/habits/9", nil)
+	req.Header.Set("Accept", "application/json")
+	req.SetPathValue("id", "9")
+	w := httptest.NewRecorder()
+
+	handler.GetByID(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `"status":404`) {
+		t.Errorf("expected JSON error, got %s", w.Body.String())
+	}
+}
+
+func TestHandler_OtherUsersHabit(t *testing.T) {
+	db := testhelpers.NewTestDB(t)
+	store := NewStore(db)
+	id, err := store.Create(2, &Habit{Description: "Theirs", StartDate: time.Now(), Color: "blue"})
+	if err != nil {
+		t.Fatalf("failed to create habit: %v", err)
+	}
+	handler := NewHandler(NewService(store))
+	path := fmt.Sprintf("/api/habits/%d", id)
+
+	tests := []struct {
+		name  string
+		req   *http.Request
+		serve http.HandlerFunc
+	}{
+		{"update", httptest.NewRequest("PUT", path, strings.NewReader("description=Mine&start_date=2025-01-01")), handler.Update},
+		{"delete", httptest.NewRequest("DELETE", path, nil), handler.Delete},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.req.WithContext(shared.WithUser(tt.req.Context(), shared.User{ID: 1, Username: "alice"}))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("Accept", "application/json")
+			req.SetPathValue("id", strconv.Itoa(id))
+			w := httptest.NewRecorder()
+
+			tt.serve(w, req)
+
+			if w.Code != http.StatusNotFound {
+				t.Errorf("expected status 404, got %d: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+
+	h, err := store.GetByID(2, id)
+	if err != nil {
+		t.Fatalf("expected the owner's habit to survive, got %v", err)
+	}
+	if h.Description != "Theirs" {
+		t.Errorf("expected the owner's habit unchanged, got %q", h.Description)
+	}
+}
+
+func TestDelete_JSON(t *testing.T) {
+	handler := NewHandler(&mockHandlerService{})
+
+	req := httptest.NewRequest("DELETE", "/api/habits/1", nil)
+	req.Header.Set("Accept", "application/json")
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	handler.Delete(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected status 204, got %d", w.Code)
+	}
+}
@@ -1,12 +1,98 @@
 package habit
 
-import "time"
+import (
+	"errors"
+	"time"
+)
+
+// ErrHabitNotFound is returned when a habit does not exist or belongs to
+// another user
+var ErrHabitNotFound = errors.New("habit not found")
+
+// Period is the span a frequency target applies to
+type Period string
+
+const (
+	PeriodWeek  Period = "week"
+	PeriodMonth Period = "month"
+)
+
+// Start returns the first day of the period containing date.
+// Weeks start on Monday.
+func (p Period) Start(date time.Time) time.Time {
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	switch p {
+	case PeriodWeek:
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case PeriodMonth:
+		return day.AddDate(0, 0, 1-day.Day())
+	}
+	return day
+}
+
+// Next returns the start of the period following the one starting at start
+func (p Period) Next(start time.Time) time.Time {
+	switch p {
+	case PeriodWeek:
+		return start.AddDate(0, 0, 7)
+	case PeriodMonth:
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// Bounds returns the first and last day of the period containing date
+func (p Period) Bounds(date time.Time) (time.Time, time.Time) {
+	start := p.Start(date)
+	return start, p.Next(start).AddDate(0, 0, -1)
+}
 
 type Habit struct {
-	ID               int       `json:"id"`
-	Description      string    `json:"description"`
-	StartDate        time.Time `json:"start_date"`
-	Color            string    `json:"color"`
-	CreatedAt        time.Time `json:"created_at"`
-	CompletedToday   bool      `json:"completed_today"`
+	ID             int            `json:"id"`
+	Description    string         `json:"description"`
+	StartDate      time.Time      `json:"start_date"`
+	Color          string         `json:"color"`
+	Days           []time.Weekday `json:"days"`
+	TargetCount    int            `json:"target_count"`
+	TargetPeriod   Period         `json:"target_period"`
+	Unit           string         `json:"unit"`
+	DailyTarget    float64        `json:"daily_target"`
+	GraceDays      int            `json:"grace_days"`
+	CreatedAt      time.Time      `json:"created_at"`
+	CompletedToday bool           `json:"completed_today"`
+	SkippedToday   bool           `json:"skipped_today"`
+	PeriodProgress int            `json:"period_progress"`
+	TodayValue     float64        `json:"today_value"`
+}
+
+// IsQuantitative reports whether the habit tracks an amount per day
+// rather than a simple done/not done
+func (h Habit) IsQuantitative() bool {
+	return h.DailyTarget > 0
+}
+
+// HasFrequency reports whether the habit uses a per-period target
+// instead of a weekday schedule
+func (h Habit) HasFrequency() bool {
+	return h.TargetCount > 0 && (h.TargetPeriod == PeriodWeek || h.TargetPeriod == PeriodMonth)
+}
+
+// IsScheduledOn reports whether the habit is due on the given date.
+// A habit without explicit days, or with a frequency target, is due every day.
+func (h Habit) IsScheduledOn(date time.Time) bool {
+	if len(h.Days) == 0 || h.HasFrequency() {
+		return true
+	}
+	for _, d := range h.Days {
+		if d == date.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// DueToday reports whether the habit is scheduled for the current day
+func (h Habit) DueToday() bool {
+	return h.IsScheduledOn(time.Now())
 }
@@ -1,15 +1,22 @@
 package habit
 
+import "time"
+
+// StoreAdapter defines the interface for data access. Every method is
+// scoped to the habits owned by userID.
 type StoreAdapter interface {
-	Create(h *Habit) (int, error)
-	Update(h *Habit) error
-	GetByID(habitID int) (*Habit, error)
-	GetAll() ([]Habit, error)
-	Delete(habitID int) error
+	Create(userID int, h *Habit) (int, error)
+	Update(userID int, h *Habit) error
+	GetByID(userID, habitID int) (*Habit, error)
+	GetAll(userID int) ([]Habit, error)
+	Delete(userID, habitID int) error
 }
 
 type RecordServiceAdapter interface {
-	IsCompletedToday(habitID int) (bool, error)
+	IsCompletedToday(userID, habitID int) (bool, error)
+	IsSkippedToday(userID, habitID int) (bool, error)
+	CountCompletions(userID, habitID int, from, to time.Time) (int, error)
+	GetTodayValue(userID, habitID int) (float64, error)
 }
 
 type Service struct {
@@ -25,37 +32,70 @@ func NewService(store StoreAdapter, recordService ...RecordServiceAdapter) *Serv
 	return s
 }
 
-func (s *Service) Create(h *Habit) (int, error) {
-	return s.store.Create(h)
+func (s *Service) Create(userID int, h *Habit) (int, error) {
+	return s.store.Create(userID, h)
 }
 
-func (s *Service) Update(h *Habit) error {
-	return s.store.Update(h)
+func (s *Service) Update(userID int, h *Habit) error {
+	return s.store.Update(userID, h)
 }
 
-func (s *Service) GetByID(habitID int) (*Habit, error) {
-	return s.store.GetByID(habitID)
+// GetByID returns one of userID's habits with today's progress filled in
+func (s *Service) GetByID(userID, habitID int) (*Habit, error) {
+	h, err := s.store.GetByID(userID, habitID)
+	if err != nil {
+		return nil, err
+	}
+	s.fillToday(userID, h)
+	return h, nil
 }
 
-func (s *Service) GetAll() ([]Habit, error) {
-	habits, err := s.store.GetAll()
+func (s *Service) GetAll(userID int) ([]Habit, error) {
+	habits, err := s.store.GetAll(userID)
 	if err != nil {
 		return nil, err
 	}
-	
-	// Populate CompletedToday if recordService is available
-	if s.recordService != nil {
-		for i := range habits {
-			completed, err := s.recordService.IsCompletedToday(habits[i].ID)
-			if err == nil {
-				habits[i].CompletedToday = completed
-			}
-		}
+	for i := range habits {
+		s.fillToday(userID, &habits[i])
 	}
-	
 	return habits, nil
 }
 
-func (s *Service) Delete(habitID int) error {
-	return s.store.Delete(habitID)
+// fillToday populates CompletedToday, SkippedToday, TodayValue and
+// PeriodProgress if recordService is available. A failed lookup leaves its
+// field unset rather than failing the whole page.
+func (s *Service) fillToday(userID int, h *Habit) {
+	if s.recordService == nil || h == nil {
+		return
+	}
+
+	completed, err := s.recordService.IsCompletedToday(userID, h.ID)
+	if err == nil {
+		h.CompletedToday = completed
+	}
+
+	skipped, err := s.recordService.IsSkippedToday(userID, h.ID)
+	if err == nil {
+		h.SkippedToday = skipped
+	}
+
+	if h.IsQuantitative() {
+		value, err := s.recordService.GetTodayValue(userID, h.ID)
+		if err == nil {
+			h.TodayValue = value
+		}
+	}
+
+	if h.HasFrequency() {
+		from, to := h.TargetPeriod.Bounds(time.Now())
+		count, err := s.recordService.CountCompletions(userID, h.ID, from, to)
+		if err == nil {
+			h.PeriodProgress = count
+		}
+	}
+}
+
+func (s *Service) Delete(userID, habitID int) error {
+	return s.store.Delete(userID, habitID)
 }
+
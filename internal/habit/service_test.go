@@ -3,6 +3,7 @@ package habit
 import (
 	"errors"
 	"testing"
+	"time"
 )
 
 type mockHabitStore struct {
@@ -12,54 +13,78 @@ type mockHabitStore struct {
 	err    error
 }
 
-func (m *mockHabitStore) Create(h *Habit) (int, error) {
+func (m *mockHabitStore) Create(userID int, h *Habit) (int, error) {
 	if m.err != nil {
 		return 0, m.err
 	}
 	return m.id, nil
 }
 
-func (m *mockHabitStore) Update(h *Habit) error {
+func (m *mockHabitStore) Update(userID int, h *Habit) error {
 	return m.err
 }
 
-func (m *mockHabitStore) GetByID(habitID int) (*Habit, error) {
+func (m *mockHabitStore) GetByID(userID, habitID int) (*Habit, error) {
 	if m.err != nil {
 		return nil, m.err
 	}
 	return m.habit, nil
 }
 
-func (m *mockHabitStore) GetAll() ([]Habit, error) {
+func (m *mockHabitStore) GetAll(userID int) ([]Habit, error) {
 	if m.err != nil {
 		return nil, m.err
 	}
 	return m.habits, nil
 }
 
-func (m *mockHabitStore) Delete(habitID int) error {
+func (m *mockHabitStore) Delete(userID, habitID int) error {
 	return m.err
 }
 
 type mockRecordService struct {
 	completed bool
+	skipped   bool
+	count     int
+	value     float64
 	err       error
 }
 
-func (m *mockRecordService) IsCompletedToday(habitID int) (bool, error) {
+func (m *mockRecordService) IsCompletedToday(userID, habitID int) (bool, error) {
 	if m.err != nil {
 		return false, m.err
 	}
 	return m.completed, nil
 }
 
+func (m *mockRecordService) IsSkippedToday(userID, habitID int) (bool, error) {
+	if m.err != nil {
+		return false, m.err
+	}
+	return m.skipped, nil
+}
+
+func (m *mockRecordService) CountCompletions(userID, habitID int, from, to time.Time) (int, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+	return m.count, nil
+}
+
+func (m *mockRecordService) GetTodayValue(userID, habitID int) (float64, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+	return m.value, nil
+}
+
 func TestHabitCreate_Success(t *testing.T) {
 	store := &mockHabitStore{id: 42}
 	s := NewService(store)
 
 	habit := &Habit{Description: "Test"}
 	
-	id, err := s.Create(habit)
+	id, err := s.Create(1, habit)
 	if err != nil {
 		t.Errorf("expected no error, got %v", err)
 	}
@@ -72,7 +97,7 @@ func TestHabitCreate_Error(t *testing.T) {
 	store := &mockHabitStore{err: errors.New("create failed")}
 	s := NewService(store)
 
-	_, err := s.Create(&Habit{})
+	_, err := s.Create(1, &Habit{})
 	if err == nil {
 		t.Error("expected error when create fails")
 	}
@@ -84,7 +109,7 @@ func TestHabitUpdate_Success(t *testing.T) {
 
 	habit := &Habit{ID: 1}
 	
-	err := s.Update(habit)
+	err := s.Update(1, habit)
 	if err != nil {
 		t.Errorf("expected no error, got %v", err)
 	}
@@ -94,7 +119,7 @@ func TestHabitUpdate_Error(t *testing.T) {
 	store := &mockHabitStore{err: errors.New("update failed")}
 	s := NewService(store)
 
-	err := s.Update(&Habit{})
+	err := s.Update(1, &Habit{})
 	if err == nil {
 		t.Error("expected error when update fails")
 	}
@@ -105,7 +130,7 @@ func TestHabitGetByID_Success(t *testing.T) {
 	store := &mockHabitStore{habit: expected}
 	s := NewService(store)
 
-	habit, err := s.GetByID(1)
+	habit, err := s.GetByID(1, 1)
 	if err != nil {
 		t.Errorf("expected no error, got %v", err)
 	}
@@ -117,11 +142,24 @@ func TestHabitGetByID_Success(t *testing.T) {
 	}
 }
 
+func TestHabitGetByID_WithRecordService(t *testing.T) {
+	store := &mockHabitStore{habit: &Habit{ID: 1, DailyTarget: 8, TargetCount: 3, TargetPeriod: PeriodWeek}}
+	s := NewService(store, &mockRecordService{completed: true, skipped: true, count: 2, value: 5})
+
+	h, err := s.GetByID(1, 1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !h.CompletedToday || !h.SkippedToday || h.TodayValue != 5 || h.PeriodProgress != 2 {
+		t.Errorf("expected today's progress to be filled in, got %+v", h)
+	}
+}
+
 func TestHabitGetByID_Error(t *testing.T) {
 	store := &mockHabitStore{err: errors.New("not found")}
 	s := NewService(store)
 
-	_, err := s.GetByID(1)
+	_, err := s.GetByID(1, 1)
 	if err == nil {
 		t.Error("expected error when habit not found")
 	}
@@ -135,7 +173,7 @@ func TestHabitGetAll_Success(t *testing.T) {
 	store := &mockHabitStore{habits: habits}
 	s := NewService(store)
 
-	result, err := s.GetAll()
+	result, err := s.GetAll(1)
 	if err != nil {
 		t.Errorf("expected no error, got %v", err)
 	}
@@ -153,7 +191,7 @@ func TestHabitGetAll_WithRecordService_AllCompleted(t *testing.T) {
 	recordService := &mockRecordService{completed: true}
 	s := NewService(store, recordService)
 
-	result, err := s.GetAll()
+	result, err := s.GetAll(1)
 	if err != nil {
 		t.Errorf("expected no error, got %v", err)
 	}
@@ -178,7 +216,7 @@ func TestHabitGetAll_WithRecordService_NoneCompleted(t *testing.T) {
 	recordService := &mockRecordService{completed: false}
 	s := NewService(store, recordService)
 
-	result, err := s.GetAll()
+	result, err := s.GetAll(1)
 	if err != nil {
 		t.Errorf("expected no error, got %v", err)
 	}
@@ -199,7 +237,7 @@ func TestHabitGetAll_WithRecordService_Error(t *testing.T) {
 	recordService := &mockRecordService{err: errors.New("check failed")}
 	s := NewService(store, recordService)
 
-	result, err := s.GetAll()
+	result, err := s.GetAll(1)
 	if err != nil {
 		t.Errorf("expected no error (should skip errors), got %v", err)
 	}
@@ -212,11 +250,67 @@ func TestHabitGetAll_WithRecordService_Error(t *testing.T) {
 	}
 }
 
+func TestHabitGetAll_WithRecordService_PeriodProgress(t *testing.T) {
+	habits := []Habit{
+		{ID: 1, Description: "Run", TargetCount: 3, TargetPeriod: PeriodWeek},
+		{ID: 2, Description: "Read"},
+	}
+	store := &mockHabitStore{habits: habits}
+	recordService := &mockRecordService{count: 2}
+	s := NewService(store, recordService)
+
+	result, err := s.GetAll(1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if result[0].PeriodProgress != 2 {
+		t.Errorf("expected progress 2 for frequency habit, got %d", result[0].PeriodProgress)
+	}
+	if result[1].PeriodProgress != 0 {
+		t.Errorf("expected no progress for daily habit, got %d", result[1].PeriodProgress)
+	}
+}
+
+func TestHabitGetAll_WithRecordService_TodayValue(t *testing.T) {
+	habits := []Habit{
+		{ID: 1, Description: "Water", Unit: "glasses", DailyTarget: 8},
+		{ID: 2, Description: "Read"},
+	}
+	store := &mockHabitStore{habits: habits}
+	recordService := &mockRecordService{value: 3}
+	s := NewService(store, recordService)
+
+	result, err := s.GetAll(1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if result[0].TodayValue != 3 {
+		t.Errorf("expected today value 3, got %v", result[0].TodayValue)
+	}
+	if result[1].TodayValue != 0 {
+		t.Errorf("expected no value for boolean habit, got %v", result[1].TodayValue)
+	}
+}
+
+func TestPeriodBounds(t *testing.T) {
+	wednesday := time.Date(2025, 1, 15, 18, 30, 0, 0, time.UTC)
+
+	start, end := PeriodWeek.Bounds(wednesday)
+	if start.Format("2006-01-02") != "2025-01-13" || end.Format("2006-01-02") != "2025-01-19" {
+		t.Errorf("unexpected week bounds %s..%s", start.Format("2006-01-02"), end.Format("2006-01-02"))
+	}
+
+	start, end = PeriodMonth.Bounds(wednesday)
+	if start.Format("2006-01-02") != "2025-01-01" || end.Format("2006-01-02") != "2025-01-31" {
+		t.Errorf("unexpected month bounds %s..%s", start.Format("2006-01-02"), end.Format("2006-01-02"))
+	}
+}
+
 func TestHabitGetAll_Error(t *testing.T) {
 	store := &mockHabitStore{err: errors.New("fetch failed")}
 	s := NewService(store)
 
-	_, err := s.GetAll()
+	_, err := s.GetAll(1)
 	if err == nil {
 		t.Error("expected error when fetch fails")
 	}
@@ -230,7 +324,7 @@ func TestHabitGetAll_WithoutRecordService(t *testing.T) {
 	store := &mockHabitStore{habits: habits}
 	s := NewService(store) // No record service
 
-	result, err := s.GetAll()
+	result, err := s.GetAll(1)
 	if err != nil {
 		t.Errorf("expected no error, got %v", err)
 	}
@@ -249,7 +343,7 @@ func TestHabitDelete_Success(t *testing.T) {
 	store := &mockHabitStore{}
 	s := NewService(store)
 
-	err := s.Delete(1)
+	err := s.Delete(1, 1)
 	if err != nil {
 		t.Errorf("expected no error, got %v", err)
 	}
@@ -259,7 +353,7 @@ func TestHabitDelete_Error(t *testing.T) {
 	store := &mockHabitStore{err: errors.New("delete failed")}
 	s := NewService(store)
 
-	err := s.Delete(1)
+	err := s.Delete(1, 1)
 	if err == nil {
 		t.Error("expected error when delete fails")
 	}
@@ -3,9 +3,15 @@ package habit
 import (
 	"database/sql"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// habitColumns lists the columns read by scanHabit, in order
+const habitColumns = `id, description, start_date, color, days, target_count, target_period, unit, daily_target, grace_days, created_at`
+
 type Store struct {
 	db *sql.DB
 }
@@ -14,13 +20,21 @@ func NewStore(db *sql.DB) *Store {
 	return &Store{db: db}
 }
 
-func (s *Store) Create(h *Habit) (int, error) {
+// Create adds a habit owned by userID
+func (s *Store) Create(userID int, h *Habit) (int, error) {
 	result, err := s.db.Exec(
-		`INSERT INTO habits (description, start_date, color)
-		 VALUES (?, ?, ?)`,
+		`INSERT INTO habits (user_id, description, start_date, color, days, target_count, target_period, unit, daily_target, grace_days)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		userID,
 		h.Description,
 		h.StartDate.Format("2006-01-02"),
 		h.Color,
+		EncodeDays(h.Days),
+		h.TargetCount,
+		string(h.TargetPeriod),
+		h.Unit,
+		h.DailyTarget,
+		h.GraceDays,
 	)
 	if err != nil {
 		return 0, fmt.Errorf("failed to create habit: %w", err)
@@ -34,53 +48,55 @@ func (s *Store) Create(h *Habit) (int, error) {
 	return int(habitID), nil
 }
 
-func (s *Store) Update(h *Habit) error {
-	_, err := s.db.Exec(
+// Update changes one of userID's habits. Other users' habits are left alone
+// and yield ErrHabitNotFound.
+func (s *Store) Update(userID int, h *Habit) error {
+	result, err := s.db.Exec(
 		`UPDATE habits 
-		 SET description = ?, start_date = ?, color = ?
-		 WHERE id = ?`,
+		 SET description = ?, start_date = ?, color = ?, days = ?, target_count = ?, target_period = ?,
+		     unit = ?, daily_target = ?, grace_days = ?
+		 WHERE id = ? AND user_id = ?`,
 		h.Description,
 		h.StartDate.Format("2006-01-02"),
 		h.Color,
+		EncodeDays(h.Days),
+		h.TargetCount,
+		string(h.TargetPeriod),
+		h.Unit,
+		h.DailyTarget,
+		h.GraceDays,
 		h.ID,
+		userID,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to update habit: %w", err)
 	}
 
-	return nil
+	return requireRow(result)
 }
 
-
-
-func (s *Store) GetByID(habitID int) (*Habit, error) {
-	h := &Habit{}
-	var startDate string
-	var createdAt string
-
-	err := s.db.QueryRow(
-		`SELECT id, description, start_date, color, created_at 
-		 FROM habits WHERE id = ?`,
+// GetByID returns one of userID's habits. Other users' habits are not found.
+func (s *Store) GetByID(userID, habitID int) (*Habit, error) {
+	h, err := scanHabit(s.db.QueryRow(
+		`SELECT `+habitColumns+` FROM habits WHERE id = ? AND user_id = ?`,
 		habitID,
-	).Scan(&h.ID, &h.Description, &startDate, &h.Color, &createdAt)
-
+		userID,
+	))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("habit not found")
+			return nil, ErrHabitNotFound
 		}
 		return nil, fmt.Errorf("failed to get habit: %w", err)
 	}
 
-	h.StartDate, _ = time.Parse("2006-01-02", startDate)
-	h.CreatedAt, _ = time.Parse("2006-01-02 15:04:05", createdAt)
-
 	return h, nil
 }
 
-func (s *Store) GetAll() ([]Habit, error) {
+// GetAll returns userID's habits, newest first
+func (s *Store) GetAll(userID int) ([]Habit, error) {
 	rows, err := s.db.Query(
-		`SELECT id, description, start_date, color, created_at 
-		 FROM habits ORDER BY created_at DESC`,
+		`SELECT `+habitColumns+` FROM habits WHERE user_id = ? ORDER BY created_at DESC`,
+		userID,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get habits: %w", err)
@@ -89,18 +105,11 @@ func (s *Store) GetAll() ([]Habit, error) {
 
 	var habits []Habit
 	for rows.Next() {
-		h := Habit{}
-		var startDate string
-		var createdAt string
-
-		if err := rows.Scan(&h.ID, &h.Description, &startDate, &h.Color, &createdAt); err != nil {
+		h, err := scanHabit(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan habit: %w", err)
 		}
-
-		h.StartDate, _ = time.Parse("2006-01-02", startDate)
-		h.CreatedAt, _ = time.Parse("2006-01-02 15:04:05", createdAt)
-
-		habits = append(habits, h)
+		habits = append(habits, *h)
 	}
 
 	if err = rows.Err(); err != nil {
@@ -110,20 +119,106 @@ func (s *Store) GetAll() ([]Habit, error) {
 	return habits, nil
 }
 
-func (s *Store) IsValidForDate(h *Habit, date time.Time) bool {
-	return true
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
 }
 
-func (s *Store) Delete(habitID int) error {
-	// Delete habit records (cascades due to FK constraint)
-	if _, err := s.db.Exec(`DELETE FROM records WHERE habit_id = ?`, habitID); err != nil {
+// scanHabit reads a habit selected with habitColumns
+func scanHabit(row rowScanner) (*Habit, error) {
+	h := &Habit{}
+	var startDate string
+	var days string
+	var targetPeriod string
+	var createdAt string
+
+	if err := row.Scan(&h.ID, &h.Description, &startDate, &h.Color, &days,
+		&h.TargetCount, &targetPeriod, &h.Unit, &h.DailyTarget, &h.GraceDays, &createdAt); err != nil {
+		return nil, err
+	}
+
+	h.StartDate, _ = time.Parse("2006-01-02", startDate)
+	h.Days = DecodeDays(days)
+	h.TargetPeriod = Period(targetPeriod)
+	h.CreatedAt, _ = time.Parse("2006-01-02 15:04:05", createdAt)
+
+	return h, nil
+}
+
+// Delete removes one of userID's habits and its records in one transaction.
+// The foreign key cascades to records too; deleting them explicitly keeps a
+// database opened without foreign keys consistent. Other users' habits yield
+// ErrHabitNotFound.
+func (s *Store) Delete(userID, habitID int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin delete: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		`DELETE FROM records WHERE habit_id IN (SELECT id FROM habits WHERE id = ? AND user_id = ?)`,
+		habitID, userID,
+	); err != nil {
 		return fmt.Errorf("failed to delete records: %w", err)
 	}
 
-	// Delete habit
-	if _, err := s.db.Exec(`DELETE FROM habits WHERE id = ?`, habitID); err != nil {
+	result, err := tx.Exec(`DELETE FROM habits WHERE id = ? AND user_id = ?`, habitID, userID)
+	if err != nil {
 		return fmt.Errorf("failed to delete habit: %w", err)
 	}
+	if err := requireRow(result); err != nil {
+		return err
+	}
 
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit delete: %w", err)
+	}
 	return nil
 }
+
+// requireRow turns a write that matched none of the user's habits into
+// ErrHabitNotFound
+func requireRow(result sql.Result) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check changed habit: %w", err)
+	}
+	if rows == 0 {
+		return ErrHabitNotFound
+	}
+	return nil
+}
+
+// EncodeDays serializes a weekday schedule as a sorted, comma-separated
+// list of weekday numbers (Sunday = 0). An empty schedule encodes to "".
+func EncodeDays(days []time.Weekday) string {
+	sorted := append([]time.Weekday(nil), days...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	parts := make([]string, 0, len(sorted))
+	for i, d := range sorted {
+		if i > 0 && sorted[i-1] == d {
+			continue
+		}
+		parts = append(parts, strconv.Itoa(int(d)))
+	}
+	return strings.Join(parts, ",")
+}
+
+// DecodeDays parses a schedule produced by EncodeDays, ignoring invalid entries
+func DecodeDays(s string) []time.Weekday {
+	if s == "" {
+		return nil
+	}
+
+	var days []time.Weekday
+	for _, part := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n < 0 || n > 6 {
+			continue
+		}
+		days = append(days, time.Weekday(n))
+	}
+	return days
+}
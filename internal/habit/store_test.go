@@ -1,6 +1,7 @@
 package habit
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -17,7 +18,7 @@ func TestStore_CreateAndGetByID(t *testing.T) {
 		Color:       "blue",
 	}
 
-	id, err := store.Create(habit)
+	id, err := store.Create(1, habit)
 	if err != nil {
 		t.Fatalf("failed to create habit: %v", err)
 	}
@@ -25,7 +26,7 @@ func TestStore_CreateAndGetByID(t *testing.T) {
 		t.Error("expected non-zero habit ID")
 	}
 
-	retrieved, err := store.GetByID(id)
+	retrieved, err := store.GetByID(1, id)
 	if err != nil {
 		t.Fatalf("failed to get habit: %v", err)
 	}
@@ -43,7 +44,7 @@ func TestStore_GetAll(t *testing.T) {
 
 	// Create multiple habits
 	for i := 0; i < 3; i++ {
-		_, err := store.Create(&Habit{
+		_, err := store.Create(1, &Habit{
 			Description: "Habit " + string(rune(i)),
 			StartDate:   time.Now(),
 			Color:       "blue",
@@ -53,7 +54,7 @@ func TestStore_GetAll(t *testing.T) {
 		}
 	}
 
-	habits, err := store.GetAll()
+	habits, err := store.GetAll(1)
 	if err != nil {
 		t.Fatalf("failed to get all habits: %v", err)
 	}
@@ -72,7 +73,7 @@ func TestStore_Update(t *testing.T) {
 		Color:       "blue",
 	}
 
-	id, err := store.Create(habit)
+	id, err := store.Create(1, habit)
 	if err != nil {
 		t.Fatalf("failed to create habit: %v", err)
 	}
@@ -80,12 +81,12 @@ func TestStore_Update(t *testing.T) {
 	habit.ID = id
 	habit.Description = "Updated"
 
-	err = store.Update(habit)
+	err = store.Update(1, habit)
 	if err != nil {
 		t.Fatalf("failed to update habit: %v", err)
 	}
 
-	retrieved, err := store.GetByID(id)
+	retrieved, err := store.GetByID(1, id)
 	if err != nil {
 		t.Fatalf("failed to get habit: %v", err)
 	}
@@ -104,38 +105,140 @@ func TestStore_Delete(t *testing.T) {
 		Color:       "blue",
 	}
 
-	id, err := store.Create(habit)
+	id, err := store.Create(1, habit)
 	if err != nil {
 		t.Fatalf("failed to create habit: %v", err)
 	}
 
-	err = store.Delete(id)
+	err = store.Delete(1, id)
 	if err != nil {
 		t.Fatalf("failed to delete habit: %v", err)
 	}
 
-	_, err = store.GetByID(id)
+	_, err = store.GetByID(1, id)
 	if err == nil {
 		t.Error("expected error when getting deleted habit")
 	}
 }
 
+func TestStore_ScopedToOwner(t *testing.T) {
+	db := testhelpers.NewTestDB(t)
+	store := NewStore(db)
+
+	id, err := store.Create(1, &Habit{Description: "Mine", StartDate: time.Now(), Color: "blue"})
+	if err != nil {
+		t.Fatalf("failed to create habit: %v", err)
+	}
+
+	if _, err := store.GetByID(2, id); err == nil {
+		t.Error("expected another user's habit not to be found")
+	}
+	if habits, err := store.GetAll(2); err != nil || len(habits) != 0 {
+		t.Errorf("expected no habits for another user, got %v (%v)", habits, err)
+	}
+
+	if err := store.Update(2, &Habit{ID: id, Description: "Stolen", StartDate: time.Now()}); !errors.Is(err, ErrHabitNotFound) {
+		t.Errorf("expected ErrHabitNotFound updating another user's habit, got %v", err)
+	}
+	if err := store.Delete(2, id); !errors.Is(err, ErrHabitNotFound) {
+		t.Errorf("expected ErrHabitNotFound deleting another user's habit, got %v", err)
+	}
+
+	retrieved, err := store.GetByID(1, id)
+	if err != nil {
+		t.Fatalf("expected the owner's habit to survive, got %v", err)
+	}
+	if retrieved.Description != "Mine" {
+		t.Errorf("expected another user's update to be ignored, got %q", retrieved.Description)
+	}
+}
+
 func TestStore_GetByID_NotFound(t *testing.T) {
 	db := testhelpers.NewTestDB(t)
 	store := NewStore(db)
 
-	_, err := store.GetByID(999999)
-	if err == nil {
-		t.Error("expected error for non-existent habit")
+	_, err := store.GetByID(1, 999999)
+	if !errors.Is(err, ErrHabitNotFound) {
+		t.Errorf("expected ErrHabitNotFound for non-existent habit, got %v", err)
 	}
 }
 
-func TestStore_IsValidForDate(t *testing.T) {
-	store := NewStore(nil)
-	habit := &Habit{}
+func TestHabit_IsScheduledOn(t *testing.T) {
+	monday := time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC)
+	tuesday := monday.AddDate(0, 0, 1)
 
-	// All habits are valid for all dates now
-	if !store.IsValidForDate(habit, time.Now()) {
-		t.Error("habit should always be valid")
+	// Habits without a schedule are due every day
+	if !(&Habit{}).IsScheduledOn(time.Now()) {
+		t.Error("habit without a schedule should always be due")
+	}
+
+	habit := &Habit{Days: []time.Weekday{time.Monday, time.Wednesday, time.Friday}}
+	if !habit.IsScheduledOn(monday) {
+		t.Error("habit should be due on a scheduled day")
+	}
+	if habit.IsScheduledOn(tuesday) {
+		t.Error("habit should not be due on an unscheduled day")
+	}
+}
+
+func TestStore_DaysRoundTrip(t *testing.T) {
+	db := testhelpers.NewTestDB(t)
+	store := NewStore(db)
+
+	id, err := store.Create(1, &Habit{
+		Description: "Gym",
+		StartDate:   time.Now(),
+		Color:       "blue",
+		Days:        []time.Weekday{time.Friday, time.Monday},
+	})
+	if err != nil {
+		t.Fatalf("failed to create habit: %v", err)
+	}
+
+	retrieved, err := store.GetByID(1, id)
+	if err != nil {
+		t.Fatalf("failed to get habit: %v", err)
+	}
+	if len(retrieved.Days) != 2 || retrieved.Days[0] != time.Monday || retrieved.Days[1] != time.Friday {
+		t.Errorf("expected [Monday Friday], got %v", retrieved.Days)
+	}
+}
+
+func TestEncodeDecodeDays(t *testing.T) {
+	encoded := EncodeDays([]time.Weekday{time.Saturday, time.Sunday, time.Saturday})
+	if encoded != "0,6" {
+		t.Errorf("expected '0,6', got '%s'", encoded)
+	}
+
+	decoded := DecodeDays("1,x,9,3")
+	if len(decoded) != 2 || decoded[0] != time.Monday || decoded[1] != time.Wednesday {
+		t.Errorf("expected [Monday Wednesday], got %v", decoded)
+	}
+
+	if DecodeDays("") != nil {
+		t.Error("expected nil schedule for empty string")
+	}
+}
+
+func TestStore_GraceDaysRoundTrip(t *testing.T) {
+	db := testhelpers.NewTestDB(t)
+	store := NewStore(db)
+
+	id, err := store.Create(1, &Habit{
+		Description: "Meditate",
+		StartDate:   time.Now(),
+		Color:       "blue",
+		GraceDays:   2,
+	})
+	if err != nil {
+		t.Fatalf("failed to create habit: %v", err)
+	}
+
+	retrieved, err := store.GetByID(1, id)
+	if err != nil {
+		t.Fatalf("failed to get habit: %v", err)
+	}
+	if retrieved.GraceDays != 2 {
+		t.Errorf("expected 2 grace days, got %d", retrieved.GraceDays)
 	}
 }
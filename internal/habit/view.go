@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"html/template"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -53,11 +54,29 @@ func getTemplates() *template.Template {
 				}
 				return strings.Join(result, ", ")
 			},
+			// Weekdays in display order for schedule pickers
+			"weekdays": func() []time.Weekday {
+				return []time.Weekday{
+					time.Monday, time.Tuesday, time.Wednesday, time.Thursday,
+					time.Friday, time.Saturday, time.Sunday,
+				}
+			},
+			"formatAmount": func(v float64) string {
+				return strconv.FormatFloat(v, 'f', -1, 64)
+			},
+			"hasDay": func(days []time.Weekday, d time.Weekday) bool {
+				for _, day := range days {
+					if day == d {
+						return true
+					}
+				}
+				return false
+			},
 		}
 
 		// Parse all templates
 		var err error
-		tmpl, err = template.New("root").Funcs(funcMap).Parse(layoutHTML + habitCardHTML + createFormHTML)
+		tmpl, err = template.New("root").Funcs(funcMap).Parse(layoutHTML + habitCardHTML + createFormHTML + weekdayPickerHTML + frequencyPickerHTML + quantityPickerHTML + gracePickerHTML)
 		if err != nil {
 			panic(fmt.Sprintf("failed to parse templates: %v", err))
 		}
@@ -90,14 +109,19 @@ func RenderHabitsList(habits []Habit) string {
 	return buf.String()
 }
 
-// RenderAllHabits renders the full page.
-func RenderAllHabits(habits []Habit) template.HTML {
+// RenderAllHabits renders the full page for the logged-in user. Every HTMX
+// request from the page sends csrfToken back.
+func RenderAllHabits(habits []Habit, username, csrfToken string) template.HTML {
 	var buf bytes.Buffer
 	// We wrap the habits in a struct if the page needs more data later
 	data := struct {
-		Habits []Habit
+		Habits    []Habit
+		Username  string
+		CSRFToken string
 	}{
-		Habits: habits,
+		Habits:    habits,
+		Username:  username,
+		CSRFToken: csrfToken,
 	}
 	
 	err := getTemplates().ExecuteTemplate(&buf, "layout", data)
@@ -122,14 +146,24 @@ const layoutHTML = `
     <script src="https://cdn.jsdelivr.net/npm/htmx.org@1.9.10"></script>
     <script defer src="https://cdn.jsdelivr.net/npm/alpinejs@3.x.x/dist/cdn.min.js"></script>
 </head>
-<body>
+<body hx-headers='{"X-CSRF-Token": "{{.CSRFToken}}"}'>
     <header>
         <div class="container">
             <h1><img src="/static/logo.svg" alt="A" class="logo">bitudini</h1>
-            <button class="btn btn-primary" 
-                onclick="document.querySelector('.create-form').style.display = document.querySelector('.create-form').style.display === 'none' ? 'block' : 'none';">
-                + New
-            </button>
+            <div class="header-actions">
+                {{if .Username}}
+                <span class="header-user">{{.Username}}</span>
+                <a class="btn" href="/tokens">Tokens</a>
+                <form class="logout-form" method="post" action="/logout">
+                    <input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+                    <button type="submit" class="btn">Log out</button>
+                </form>
+                {{end}}
+                <button class="btn btn-primary" 
+                    onclick="document.querySelector('.create-form').style.display = document.querySelector('.create-form').style.display === 'none' ? 'block' : 'none';">
+                    + New
+                </button>
+            </div>
         </div>
     </header>
 
@@ -154,15 +188,64 @@ const createFormHTML = `
     <form hx-post="/api/habits" hx-target="#habits-list" hx-swap="afterbegin" hx-on::after-request="this.reset()">
         <input type="text" name="description" placeholder="What habit?" required>
         <input type="date" name="start_date" value="{{dateNow}}" required>
+        {{template "weekday-picker"}}
+        {{template "frequency-picker"}}
+        {{template "quantity-picker"}}
+        {{template "grace-picker"}}
         <button type="submit" class="btn btn-primary">Create</button>
     </form>
 </div>
 {{end}}
 `
 
+const weekdayPickerHTML = `
+{{define "weekday-picker"}}
+{{$days := .}}
+<fieldset class="weekday-picker">
+    <legend>Days (none = every day)</legend>
+    {{range weekdays}}
+    <label><input type="checkbox" name="days" value="{{printf "%d" .}}"{{if hasDay $days .}} checked{{end}}>{{slice .String 0 3}}</label>
+    {{end}}
+</fieldset>
+{{end}}
+`
+
+const frequencyPickerHTML = `
+{{define "frequency-picker"}}
+<fieldset class="frequency-picker">
+    <legend>Or a flexible target</legend>
+    <input type="number" name="target_count" min="0" placeholder="Times"{{with .}}{{if .TargetCount}} value="{{.TargetCount}}"{{end}}{{end}}>
+    <select name="target_period">
+        <option value="">No target</option>
+        <option value="week"{{with .}}{{if eq .TargetPeriod "week"}} selected{{end}}{{end}}>per week</option>
+        <option value="month"{{with .}}{{if eq .TargetPeriod "month"}} selected{{end}}{{end}}>per month</option>
+    </select>
+</fieldset>
+{{end}}
+`
+
+const quantityPickerHTML = `
+{{define "quantity-picker"}}
+<fieldset class="quantity-picker">
+    <legend>Daily amount (optional)</legend>
+    <input type="number" name="daily_target" min="0" step="any" placeholder="Target"{{with .}}{{if .DailyTarget}} value="{{formatAmount .DailyTarget}}"{{end}}{{end}}>
+    <input type="text" name="unit" placeholder="Unit, e.g. glasses"{{with .}} value="{{.Unit}}"{{end}}>
+</fieldset>
+{{end}}
+`
+
+const gracePickerHTML = `
+{{define "grace-picker"}}
+<fieldset class="grace-picker">
+    <legend>Grace days per month</legend>
+    <input type="number" name="grace_days" min="0" max="31" placeholder="0"{{with .}}{{if .GraceDays}} value="{{.GraceDays}}"{{end}}{{end}}>
+</fieldset>
+{{end}}
+`
+
 const habitCardHTML = `
 {{define "habit-card"}}
-<div id="habit-{{.ID}}" class="card" hx-on::htmx:afterRequest="this.classList.add('pulse')">
+<div id="habit-{{.ID}}" class="card" x-data="{ editing: false }" hx-on::htmx:afterRequest="this.classList.add('pulse')">
     <button class="card-delete-btn"
             hx-delete="/api/habits/{{.ID}}" 
             hx-confirm="Delete this habit and all its data?" 
@@ -174,11 +257,27 @@ const habitCardHTML = `
     </button>
     <div class="card-header">
         <div>
-            <h2>{{.Description}}</h2>
-            <p class="card-meta">Started on {{.StartDate | formatDate}}</p>
+            <h2 @click="editing = !editing" title="Edit habit">{{.Description}}</h2>
+            <p class="card-meta">Started on {{.StartDate | formatDate}}{{if .HasFrequency}} · {{.TargetCount}} times per {{.TargetPeriod}}{{else if .Days}} · {{formatWeekdays .Days}}{{end}}{{if .IsQuantitative}} · {{formatAmount .DailyTarget}} {{.Unit}} per day{{end}}</p>
+            {{if .HasFrequency}}<p class="card-progress">{{.PeriodProgress}}/{{.TargetCount}} this {{.TargetPeriod}}</p>{{end}}
+            {{if .IsQuantitative}}<p class="card-progress">{{formatAmount .TodayValue}}/{{formatAmount .DailyTarget}} {{.Unit}} today</p>{{end}}
         </div>
     </div>
 
+    <form class="edit-form" x-show="editing" x-cloak
+          hx-put="/api/habits/{{.ID}}"
+          hx-target="#habit-{{.ID}}"
+          hx-swap="outerHTML">
+        <input type="text" name="description" value="{{.Description}}" required>
+        <input type="date" name="start_date" value="{{.StartDate | isoDate}}" required>
+        <input type="hidden" name="color" value="{{.Color}}">
+        {{template "weekday-picker" .Days}}
+        {{template "frequency-picker" .}}
+        {{template "quantity-picker" .}}
+        {{template "grace-picker" .}}
+        <button type="submit" class="btn btn-primary">Save</button>
+    </form>
+
     <div id="contribution-{{.ID}}" 
          class="contribution-container"
          hx-get="/api/habits/{{.ID}}/contribution"
@@ -189,18 +288,58 @@ const habitCardHTML = `
         </div>
     </div>
 
+    <details class="journal-toggle"
+             hx-get="/api/habits/{{.ID}}/journal"
+             hx-trigger="toggle once"
+             hx-target="find .journal-entries">
+        <summary>Journal</summary>
+        <div class="journal-entries">Loading...</div>
+    </details>
+
+    <details class="journal-toggle"
+             hx-get="/api/habits/{{.ID}}/streaks"
+             hx-trigger="toggle once"
+             hx-target="find .streak-history">
+        <summary>Streak history</summary>
+        <div class="streak-history">Loading...</div>
+    </details>
+
     <div class="card-actions">
-        {{if not .CompletedToday}}
-        <button class="btn" 
-                hx-post="/api/habits/{{.ID}}/done-today" 
-                hx-target="#habit-{{.ID}}" 
-                hx-swap="outerHTML">
-            ✓ Done Today
-        </button>
+        {{if not .DueToday}}
+        <div></div>
+        {{else if .SkippedToday}}
+        <p class="skipped-today">Skipped today</p>
+        {{else if .IsQuantitative}}
+        <form class="amount-form"
+              hx-post="/api/habits/{{.ID}}/add-amount"
+              hx-target="#habit-{{.ID}}"
+              hx-swap="outerHTML">
+            <input type="number" name="amount" min="0" step="any" placeholder="{{if .Unit}}{{.Unit}}{{else}}Amount{{end}}" required>
+            <button type="submit" class="btn">+ Add</button>
+        </form>
+        {{else if not .CompletedToday}}
+        <form class="done-form"
+              hx-post="/api/habits/{{.ID}}/done-today" 
+              hx-target="#habit-{{.ID}}" 
+              hx-swap="outerHTML">
+            <input type="text" name="note" placeholder="Note (optional)" maxlength="500">
+            <button type="submit" class="btn">✓ Done Today</button>
+        </form>
         {{else}}
         <div></div>
         {{end}}
 
+        {{if and .DueToday (not .CompletedToday) (not .SkippedToday)}}
+        <button class="btn btn-skip"
+                hx-post="/api/habits/{{.ID}}/skip-today"
+                hx-include="#habit-{{.ID}} .done-form [name='note']"
+                hx-target="#habit-{{.ID}}"
+                hx-swap="outerHTML"
+                title="Excuse today without breaking the streak">
+            Skip today
+        </button>
+        {{end}}
+
         <div id="streak-{{.ID}}" hx-get="/api/habits/{{.ID}}/streak" hx-trigger="load"></div>
     </div>
 </div>
@@ -0,0 +1,163 @@
+package importer
+
+import (
+	"errors"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/epalmerini/abitudini/internal/export"
+	"github.com/epalmerini/abitudini/internal/shared"
+)
+
+// maxImportSize caps the size of uploaded imports
+const maxImportSize = 32 << 20
+
+// HandlerService interface for dependency injection
+type HandlerService interface {
+	Import(userID int, doc *export.Document, opts Options) (*Report, error)
+}
+
+type Handler struct {
+	shared.BaseHandler
+	service HandlerService
+}
+
+func NewHandler(service HandlerService) *Handler {
+	return &Handler{service: service}
+}
+
+// Import reads an export from the request body and imports it. The format
+// comes from the format query parameter or the Content-Type, the mode from
+// the mode parameter, and dry_run=true only reports what would change.
+func (h *Handler) Import(w http.ResponseWriter, r *http.Request) {
+	if !h.ValidateMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	format, err := requestFormat(r)
+	if err != nil {
+		h.RespondError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	opts, err := requestOptions(r)
+	if err != nil {
+		h.RespondError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	doc, err := export.Read(http.MaxBytesReader(w, r.Body, maxImportSize), format)
+	if err != nil {
+		h.RespondError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.importDocument(w, r, doc, opts)
+}
+
+// ImportLoop imports a Loop Habit Tracker backup uploaded as the "file" field
+// of a multipart form. It takes the same mode and dry_run parameters as Import.
+func (h *Handler) ImportLoop(w http.ResponseWriter, r *http.Request) {
+	if !h.ValidateMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	opts, err := requestOptions(r)
+	if err != nil {
+		h.RespondError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	upload, _, err := r.FormFile("file")
+	if err != nil {
+		h.RespondError(w, r, "Missing backup file", http.StatusBadRequest)
+		return
+	}
+	defer upload.Close()
+
+	// The SQLite driver and zip reader both need a file on disk
+	tmp, err := os.CreateTemp("", "abitudini-loop-*")
+	if err != nil {
+		h.RespondError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, upload)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		h.RespondError(w, r, "Failed to read backup file", http.StatusBadRequest)
+		return
+	}
+
+	doc, err := ReadLoop(tmp.Name())
+	if err != nil {
+		h.RespondError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.importDocument(w, r, doc, opts)
+}
+
+// importDocument imports doc and responds with the report
+func (h *Handler) importDocument(w http.ResponseWriter, r *http.Request, doc *export.Document, opts Options) {
+	report, err := h.service.Import(h.UserID(r), doc, opts)
+	if err != nil {
+		h.RespondError(w, r, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	if h.WantsJSON(r) {
+		h.WriteJSON(w, http.StatusOK, report)
+		return
+	}
+
+	h.WriteHTML(w, renderReport(report))
+}
+
+// requestFormat picks the import format from the query or the Content-Type
+func requestFormat(r *http.Request) (export.Format, error) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return export.ParseFormat(format)
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "text/csv" {
+		return export.FormatCSV, nil
+	}
+	return export.FormatJSON, nil
+}
+
+// requestOptions reads the mode and dry_run query parameters
+func requestOptions(r *http.Request) (Options, error) {
+	query := r.URL.Query()
+	mode, err := ParseMode(query.Get("mode"))
+	if err != nil {
+		return Options{}, err
+	}
+
+	opts := Options{Mode: mode}
+	if value := query.Get("dry_run"); value != "" {
+		if opts.DryRun, err = strconv.ParseBool(value); err != nil {
+			return Options{}, errors.New("Invalid dry_run value")
+		}
+	}
+	return opts, nil
+}
+
+// renderReport renders the import summary as a list
+func renderReport(report *Report) string {
+	var out strings.Builder
+	out.WriteString(`<ul class="import-report">`)
+	for _, line := range report.Summary() {
+		out.WriteString("<li>" + html.EscapeString(strings.TrimSpace(line)) + "</li>")
+	}
+	out.WriteString(`</ul>`)
+	return out.String()
+}
@@ -0,0 +1,159 @@
+package importer
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/epalmerini/abitudini/internal/export"
+)
+
+type mockImportHandlerService struct {
+	doc  *export.Document
+	opts Options
+	err  error
+}
+
+func (m *mockImportHandlerService) Import(userID int, doc *export.Document, opts Options) (*Report, error) {
+	m.doc, m.opts = doc, opts
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &Report{Mode: opts.Mode, DryRun: opts.DryRun, RecordsCreated: len(doc.Records)}, nil
+}
+
+func TestImport_CSVBody(t *testing.T) {
+	service := &mockImportHandlerService{}
+	handler := NewHandler(service)
+
+	body := "habit_id,description,start_date,date\n1,Read,2025-01-01,2025-01-02\n"
+	req := httptest.NewRequest("POST", "/api/import?mode=replace&dry_run=true", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/csv")
+	w := httptest.NewRecorder()
+
+	handler.Import(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if service.opts.Mode != ModeReplace || !service.opts.DryRun {
+		t.Errorf("expected replace dry run, got %+v", service.opts)
+	}
+	if len(service.doc.Records) != 1 {
+		t.Errorf("expected CSV body to be parsed, got %+v", service.doc)
+	}
+	if !strings.Contains(w.Body.String(), "Dry run (replace)") {
+		t.Errorf("expected report summary, got %s", w.Body.String())
+	}
+}
+
+func TestImport_JSONReport(t *testing.T) {
+	handler := NewHandler(&mockImportHandlerService{})
+
+	body := `{"version": 1, "habits": [{"id": 1, "description": "Read", "start_date": "2025-01-01"}], "records": []}`
+	req := httptest.NewRequest("POST", "/api/import", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.Import(w, req)
+
+	if !strings.Contains(w.Body.String(), `"mode":"merge"`) {
+		t.Errorf("expected JSON report, got %s", w.Body.String())
+	}
+}
+
+func TestImport_BadRequests(t *testing.T) {
+	cases := map[string]string{
+		"/api/import?mode=append":   `{}`,
+		"/api/import?dry_run=maybe": `{}`,
+		"/api/import?format=xml":    `{}`,
+		"/api/import":               `not json`,
+	}
+
+	for target, body := range cases {
+		handler := NewHandler(&mockImportHandlerService{})
+		req := httptest.NewRequest("POST", target, strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		handler.Import(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", target, w.Code)
+		}
+	}
+}
+
+func TestImport_InvalidDocument(t *testing.T) {
+	handler := NewHandler(&mockImportHandlerService{err: errors.New("invalid import: habit 1 has no description")})
+
+	req := httptest.NewRequest("POST", "/api/import", strings.NewReader(`{"habits": []}`))
+	w := httptest.NewRecorder()
+
+	handler.Import(w, req)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status 422, got %d", w.Code)
+	}
+}
+
+func TestImportLoop_Upload(t *testing.T) {
+	service := &mockImportHandlerService{}
+	handler := NewHandler(service)
+
+	backup, err := os.ReadFile(newLoopBackup(t))
+	if err != nil {
+		t.Fatalf("failed to read backup: %v", err)
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", "Loop Habits Backup.db")
+	part.Write(backup)
+	form.Close()
+
+	req := httptest.NewRequest("POST", "/api/import/loop?dry_run=1", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	w := httptest.NewRecorder()
+
+	handler.ImportLoop(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !service.opts.DryRun || len(service.doc.Habits) != 2 || len(service.doc.Records) != 3 {
+		t.Errorf("expected the backup to be converted, got %+v", service.doc)
+	}
+}
+
+func TestImportLoop_BadUploads(t *testing.T) {
+	handler := NewHandler(&mockImportHandlerService{})
+
+	// No file field
+	req := httptest.NewRequest("POST", "/api/import/loop", strings.NewReader(""))
+	w := httptest.NewRecorder()
+	handler.ImportLoop(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 without a file, got %d", w.Code)
+	}
+
+	// Not a Loop backup
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", "notes.txt")
+	part.Write([]byte("hello"))
+	form.Close()
+
+	req = httptest.NewRequest("POST", "/api/import/loop", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	w = httptest.NewRecorder()
+	handler.ImportLoop(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for a non-backup file, got %d", w.Code)
+	}
+}
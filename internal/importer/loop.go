@@ -0,0 +1,471 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/epalmerini/abitudini/internal/export"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Loop Habit Tracker entry values for yes/no habits. Loop also computes
+// "yes, automatic" days that satisfy a frequency without being checked;
+// those are never imported.
+const (
+	loopYesManual = 2
+	loopSkip      = 3
+)
+
+// loopPalette maps Loop's colour indexes to their hex values
+var loopPalette = []string{
+	"#D32F2F", "#E64A19", "#F57C00", "#FF8F00", "#F9A825",
+	"#AFB42B", "#7CB342", "#388E3C", "#00897B", "#00ACC1",
+	"#039BE5", "#1976D2", "#303F9F", "#5E35B1", "#8E24AA",
+	"#D81B60", "#5D4037", "#303030", "#757575", "#AAAAAA",
+}
+
+// loopHabit is a habit as stored by Loop Habit Tracker
+type loopHabit struct {
+	id        int
+	name      string
+	color     string
+	freqNum   int
+	freqDen   int
+	numerical bool
+	atMost    bool
+	target    float64
+	unit      string
+}
+
+// loopEntry is a single checkmark. For numerical habits value is the amount
+// in the habit's unit, otherwise one of Loop's entry values.
+type loopEntry struct {
+	habitID int
+	date    time.Time
+	value   float64
+	note    string
+}
+
+// ReadLoop converts a Loop Habit Tracker backup into an export document that
+// can be imported like any other. It accepts the app's SQLite backup and the
+// zip archive written by its CSV export.
+func ReadLoop(path string) (*export.Document, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Loop backup: %w", err)
+	}
+	magic := make([]byte, 16)
+	n, _ := io.ReadFull(file, magic)
+	file.Close()
+	magic = magic[:n]
+
+	var habits []loopHabit
+	var entries []loopEntry
+	switch {
+	case bytes.HasPrefix(magic, []byte("SQLite format 3\x00")):
+		habits, entries, err = readLoopDatabase(path)
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")):
+		habits, entries, err = readLoopCSV(path)
+	default:
+		return nil, errors.New("not a Loop Habit Tracker backup: expected a .db backup or a CSV export .zip")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return loopDocument(habits, entries, time.Now()), nil
+}
+
+// readLoopDatabase reads habits and repetitions from a Loop SQLite backup.
+// Older backups lack the numerical habit columns, which then default to a
+// yes/no habit.
+func readLoopDatabase(path string) ([]loopHabit, []loopEntry, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to resolve Loop backup path: %w", err)
+	}
+	dsn := (&url.URL{Scheme: "file", Path: absPath, RawQuery: "mode=ro"}).String()
+	database, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open Loop backup: %w", err)
+	}
+	defer database.Close()
+
+	habitColumns, err := tableColumns(database, "Habits")
+	if err != nil {
+		return nil, nil, err
+	}
+	repetitionColumns, err := tableColumns(database, "Repetitions")
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(habitColumns) == 0 || len(repetitionColumns) == 0 {
+		return nil, nil, errors.New("not a Loop Habit Tracker backup: missing Habits or Repetitions table")
+	}
+
+	rows, err := database.Query(fmt.Sprintf(
+		`SELECT id, COALESCE(name, ''), %s, %s, %s, %s, %s, %s, %s FROM Habits ORDER BY %s, id`,
+		columnOr(habitColumns, "color", "-1"),
+		columnOr(habitColumns, "freq_num", "1"),
+		columnOr(habitColumns, "freq_den", "1"),
+		columnOr(habitColumns, "type", "0"),
+		columnOr(habitColumns, "target_type", "0"),
+		columnOr(habitColumns, "target_value", "0"),
+		columnOr(habitColumns, "unit", "''"),
+		columnOr(habitColumns, "position", "0"),
+	))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read Loop habits: %w", err)
+	}
+	defer rows.Close()
+
+	var habits []loopHabit
+	for rows.Next() {
+		var h loopHabit
+		var color, habitType, targetType int
+		if err := rows.Scan(&h.id, &h.name, &color, &h.freqNum, &h.freqDen, &habitType, &targetType, &h.target, &h.unit); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan Loop habit: %w", err)
+		}
+		if color >= 0 && color < len(loopPalette) {
+			h.color = loopPalette[color]
+		}
+		h.numerical = habitType == 1
+		h.atMost = targetType == 1
+		habits = append(habits, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read Loop habits: %w", err)
+	}
+
+	entryRows, err := database.Query(fmt.Sprintf(
+		`SELECT habit, timestamp, %s, %s FROM Repetitions ORDER BY habit, timestamp`,
+		columnOr(repetitionColumns, "value", strconv.Itoa(loopYesManual)),
+		columnOr(repetitionColumns, "notes", "''"),
+	))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read Loop repetitions: %w", err)
+	}
+	defer entryRows.Close()
+
+	numerical := make(map[int]bool, len(habits))
+	for _, h := range habits {
+		numerical[h.id] = h.numerical
+	}
+
+	var entries []loopEntry
+	for entryRows.Next() {
+		var e loopEntry
+		var timestamp, value int64
+		var note sql.NullString
+		if err := entryRows.Scan(&e.habitID, &timestamp, &value, &note); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan Loop repetition: %w", err)
+		}
+		e.date = time.UnixMilli(timestamp).UTC()
+		e.value = float64(value)
+		if numerical[e.habitID] {
+			// Loop stores amounts in thousandths
+			e.value /= 1000
+		}
+		e.note = note.String
+		entries = append(entries, e)
+	}
+	if err := entryRows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read Loop repetitions: %w", err)
+	}
+
+	return habits, entries, nil
+}
+
+// tableColumns returns the column names of a table, or none if it does not
+// exist
+func tableColumns(database *sql.DB, table string) (map[string]bool, error) {
+	rows, err := database.Query(fmt.Sprintf(`PRAGMA table_info(%s)`, table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Loop backup: %w", err)
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return nil, fmt.Errorf("failed to read Loop backup: %w", err)
+		}
+		columns[strings.ToLower(name)] = true
+	}
+	return columns, rows.Err()
+}
+
+// columnOr selects column if the table has it, otherwise the fallback literal
+func columnOr(columns map[string]bool, column, fallback string) string {
+	if columns[column] {
+		return fmt.Sprintf("COALESCE(%s, %s)", column, fallback)
+	}
+	return fallback
+}
+
+// readLoopCSV reads the Habits.csv and Checkmarks.csv files at the top of a
+// Loop CSV export. Habits.csv uses either the current columns
+// (FrequencyNumerator, FrequencyDenominator, Type, Unit, Target Type, Target
+// Value) or the older NumRepetitions and Interval ones.
+func readLoopCSV(path string) ([]loopHabit, []loopEntry, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open Loop CSV export: %w", err)
+	}
+	defer archive.Close()
+
+	habitRows, err := readZipCSV(&archive.Reader, "Habits.csv")
+	if err != nil {
+		return nil, nil, err
+	}
+	checkmarkRows, err := readZipCSV(&archive.Reader, "Checkmarks.csv")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	habits, err := loopCSVHabits(habitRows)
+	if err != nil {
+		return nil, nil, err
+	}
+	entries, err := loopCSVCheckmarks(checkmarkRows, habits)
+	if err != nil {
+		return nil, nil, err
+	}
+	return habits, entries, nil
+}
+
+// readZipCSV parses the named CSV file closest to the root of the archive.
+// Loop also writes a Checkmarks.csv per habit in subfolders.
+func readZipCSV(archive *zip.Reader, name string) ([][]string, error) {
+	var found *zip.File
+	for _, f := range archive.File {
+		if path.Base(f.Name) != name {
+			continue
+		}
+		if found == nil || strings.Count(f.Name, "/") < strings.Count(found.Name, "/") {
+			found = f
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("not a Loop Habit Tracker CSV export: missing %s", name)
+	}
+
+	file, err := found.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("failed to parse %s: empty file", name)
+	}
+	return rows, nil
+}
+
+// loopCSVHabits maps Habits.csv rows to habits numbered by row
+func loopCSVHabits(rows [][]string) ([]loopHabit, error) {
+	columns := make(map[string]int, len(rows[0]))
+	for i, name := range rows[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, errors.New("failed to parse Habits.csv: missing Name column")
+	}
+
+	field := func(row []string, names ...string) string {
+		for _, name := range names {
+			if i, ok := columns[name]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+		}
+		return ""
+	}
+
+	var habits []loopHabit
+	for i, row := range rows[1:] {
+		h := loopHabit{
+			id:        i + 1,
+			name:      field(row, "name"),
+			color:     field(row, "color"),
+			numerical: strings.EqualFold(field(row, "type"), "NUMERICAL"),
+			atMost:    strings.EqualFold(field(row, "target type"), "AT_MOST"),
+			unit:      field(row, "unit"),
+		}
+		h.freqNum, _ = strconv.Atoi(field(row, "frequencynumerator", "numrepetitions"))
+		h.freqDen, _ = strconv.Atoi(field(row, "frequencydenominator", "interval"))
+		h.target, _ = strconv.ParseFloat(field(row, "target value"), 64)
+		habits = append(habits, h)
+	}
+	return habits, nil
+}
+
+// loopCSVCheckmarks reads Checkmarks.csv, which has a Date column followed
+// by one column per habit named after it
+func loopCSVCheckmarks(rows [][]string, habits []loopHabit) ([]loopEntry, error) {
+	byName := make(map[string]loopHabit, len(habits))
+	for i := len(habits) - 1; i >= 0; i-- {
+		byName[habits[i].name] = habits[i]
+	}
+
+	header := rows[0]
+	columns := make(map[int]loopHabit, len(header))
+	for i, name := range header[1:] {
+		if h, ok := byName[strings.TrimSpace(name)]; ok {
+			columns[i+1] = h
+		}
+	}
+
+	var entries []loopEntry
+	for _, row := range rows[1:] {
+		if len(row) == 0 || row[0] == "" {
+			continue
+		}
+		date, err := time.Parse("2006-01-02", strings.TrimSpace(row[0]))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Checkmarks.csv: invalid date %q", row[0])
+		}
+		for i := 1; i < len(row); i++ {
+			h, ok := columns[i]
+			if !ok {
+				continue
+			}
+			raw := strings.TrimSpace(row[i])
+			value, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				continue
+			}
+			// Amounts without a decimal point are in thousandths, as stored
+			// in the app's database
+			if h.numerical && !strings.Contains(raw, ".") {
+				value /= 1000
+			}
+			entries = append(entries, loopEntry{habitID: h.id, date: date, value: value})
+		}
+	}
+	return entries, nil
+}
+
+// loopDocument builds the import document. A habit starts on the day of its
+// first entry, or today if it has none.
+func loopDocument(habits []loopHabit, entries []loopEntry, now time.Time) *export.Document {
+	byID := make(map[int]loopHabit, len(habits))
+	for _, h := range habits {
+		byID[h.id] = h
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].date.Before(entries[j].date) })
+
+	doc := &export.Document{Version: export.FormatVersion, Records: []export.Record{}}
+	first := make(map[int]string)
+	for _, e := range entries {
+		h, ok := byID[e.habitID]
+		if !ok {
+			continue
+		}
+		record, ok := loopRecord(h, e)
+		if !ok {
+			continue
+		}
+		if _, ok := first[h.id]; !ok {
+			first[h.id] = record.Date
+		}
+		doc.Records = append(doc.Records, record)
+	}
+
+	for _, h := range habits {
+		startDate, ok := first[h.id]
+		if !ok {
+			startDate = now.Format("2006-01-02")
+		}
+		converted := export.Habit{
+			ID:          h.id,
+			Description: strings.TrimSpace(h.name),
+			StartDate:   startDate,
+			Color:       h.color,
+		}
+		converted.TargetCount, converted.TargetPeriod = loopFrequency(h.freqNum, h.freqDen)
+		if h.numerical && !h.atMost && h.target > 0 {
+			converted.DailyTarget, converted.Unit = h.target, strings.TrimSpace(h.unit)
+		}
+		doc.Habits = append(doc.Habits, converted)
+	}
+	return doc
+}
+
+// loopRecord converts a checkmark into a record. Numerical habits with an
+// "at most" target become plain habits done on days within the limit.
+func loopRecord(h loopHabit, e loopEntry) (export.Record, bool) {
+	record := export.Record{
+		HabitID: h.id,
+		Date:    e.date.Format("2006-01-02"),
+		Status:  "done",
+		Value:   1,
+		Note:    e.note,
+	}
+
+	switch {
+	case h.numerical && h.atMost:
+		if e.value < 0 || e.value > h.target {
+			return record, false
+		}
+	case h.numerical:
+		if e.value <= 0 {
+			return record, false
+		}
+		record.Value = e.value
+	case e.value == loopYesManual:
+	case e.value == loopSkip:
+		record.Status, record.Value = "skipped", 0
+	default:
+		return record, false
+	}
+	return record, true
+}
+
+// loopFrequency maps Loop's "num times every den days" onto a weekly or
+// monthly target. Every day, or more often than once a day, needs no target.
+// Intervals other than a week or a month are rounded to the closest weekly
+// target, or a monthly one for habits done less than once a week.
+func loopFrequency(num, den int) (int, string) {
+	if num <= 0 || den <= 0 || num >= den {
+		return 0, ""
+	}
+	switch den {
+	case 7:
+		return num, "week"
+	case 30, 31:
+		return num, "month"
+	}
+
+	perWeek := float64(num) * 7 / float64(den)
+	switch {
+	case math.Round(perWeek) >= 7:
+		return 0, ""
+	case perWeek >= 1:
+		return int(math.Round(perWeek)), "week"
+	}
+	return max(1, int(math.Round(float64(num)*30/float64(den)))), "month"
+}
@@ -0,0 +1,187 @@
+package importer
+
+import (
+	"archive/zip"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newLoopBackup writes a Loop Habit Tracker database with a yes/no habit
+// checked twice every week and a numerical habit measured in glasses
+func newLoopBackup(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "Loop Habits Backup.db")
+	database, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("failed to create Loop backup: %v", err)
+	}
+	defer database.Close()
+
+	day := func(date string) int64 {
+		d, _ := time.Parse("2006-01-02", date)
+		return d.UnixMilli()
+	}
+
+	statements := []string{
+		`CREATE TABLE Habits (id INTEGER PRIMARY KEY AUTOINCREMENT, archived INTEGER, color INTEGER,
+			description TEXT, freq_den INTEGER, freq_num INTEGER, highlight INTEGER, name TEXT,
+			position INTEGER, reminder_hour INTEGER, reminder_min INTEGER, reminder_days INTEGER NOT NULL DEFAULT 127,
+			type INTEGER NOT NULL DEFAULT 0, target_type INTEGER NOT NULL DEFAULT 0,
+			target_value REAL NOT NULL DEFAULT 0, unit TEXT NOT NULL DEFAULT "", question TEXT, uuid TEXT)`,
+		`CREATE TABLE Repetitions (id INTEGER PRIMARY KEY AUTOINCREMENT, habit INTEGER NOT NULL REFERENCES Habits(id),
+			timestamp INTEGER NOT NULL, value INTEGER NOT NULL, notes TEXT)`,
+		`INSERT INTO Habits (id, archived, color, freq_den, freq_num, name, position, type, target_type, target_value, unit)
+		 VALUES (1, 0, 11, 7, 2, 'Gym', 1, 0, 0, 0, ''),
+		        (2, 0, 7, 1, 1, 'Water', 0, 1, 0, 8, 'glasses')`,
+	}
+	for _, stmt := range statements {
+		if _, err := database.Exec(stmt); err != nil {
+			t.Fatalf("failed to create Loop backup: %v", err)
+		}
+	}
+
+	repetitions := []struct {
+		habit int
+		date  string
+		value int
+		note  string
+	}{
+		{1, "2025-03-03", 2, "legs"},
+		{1, "2025-03-04", 3, ""},
+		{1, "2025-03-05", 1, ""},
+		{1, "2025-03-06", 0, ""},
+		{2, "2025-03-02", 6500, ""},
+		{2, "2025-03-03", 0, ""},
+	}
+	for _, r := range repetitions {
+		if _, err := database.Exec(
+			`INSERT INTO Repetitions (habit, timestamp, value, notes) VALUES (?, ?, ?, ?)`,
+			r.habit, day(r.date), r.value, r.note,
+		); err != nil {
+			t.Fatalf("failed to insert repetition: %v", err)
+		}
+	}
+
+	return path
+}
+
+func TestReadLoop_Database(t *testing.T) {
+	doc, err := ReadLoop(newLoopBackup(t))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(doc.Habits) != 2 {
+		t.Fatalf("expected 2 habits, got %d", len(doc.Habits))
+	}
+	water, gym := doc.Habits[0], doc.Habits[1]
+
+	if gym.Description != "Gym" || gym.Color != "#1976D2" || gym.TargetCount != 2 || gym.TargetPeriod != "week" {
+		t.Errorf("unexpected gym habit %+v", gym)
+	}
+	if gym.StartDate != "2025-03-03" {
+		t.Errorf("expected start date from the first entry, got %s", gym.StartDate)
+	}
+	if water.DailyTarget != 8 || water.Unit != "glasses" || water.TargetCount != 0 {
+		t.Errorf("unexpected water habit %+v", water)
+	}
+
+	if len(doc.Records) != 3 {
+		t.Fatalf("expected 3 records, got %+v", doc.Records)
+	}
+	if r := doc.Records[0]; r.HabitID != 2 || r.Date != "2025-03-02" || r.Value != 6.5 {
+		t.Errorf("expected 6.5 glasses on 2025-03-02, got %+v", r)
+	}
+	if r := doc.Records[1]; r.Status != "done" || r.Note != "legs" {
+		t.Errorf("expected checked day with note, got %+v", r)
+	}
+	if r := doc.Records[2]; r.Date != "2025-03-04" || r.Status != "skipped" {
+		t.Errorf("expected skipped day, got %+v", r)
+	}
+}
+
+func TestReadLoop_CSVExport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Loop Habits CSV 2025-03-10.zip")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("failed to create export: %v", err)
+	}
+	archive := zip.NewWriter(file)
+	files := map[string]string{
+		"Habits.csv": "Position,Name,Type,Question,Description,FrequencyNumerator,FrequencyDenominator,Color,Unit,Target Type,Target Value,Archived?\n" +
+			"001,Read,YES_NO,,,1,1,#388E3C,,AT_LEAST,0,false\n" +
+			"002,Coffee,NUMERICAL,,,1,1,#5D4037,cups,AT_MOST,2,false\n",
+		"Checkmarks.csv":          "Date,Read,Coffee,\n2025-03-09,2,3000,\n2025-03-08,0,1500,\n2025-03-07,2,-1,\n",
+		"001 Read/Checkmarks.csv": "2025-03-09,2\n",
+	}
+	for name, content := range files {
+		w, _ := archive.Create(name)
+		w.Write([]byte(content))
+	}
+	archive.Close()
+	file.Close()
+
+	doc, err := ReadLoop(path)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(doc.Habits) != 2 {
+		t.Fatalf("expected 2 habits, got %d", len(doc.Habits))
+	}
+	if h := doc.Habits[0]; h.Description != "Read" || h.Color != "#388E3C" || h.StartDate != "2025-03-07" {
+		t.Errorf("unexpected read habit %+v", h)
+	}
+	// An "at most" target becomes a plain habit done on days within the limit
+	if h := doc.Habits[1]; h.DailyTarget != 0 || h.StartDate != "2025-03-08" {
+		t.Errorf("unexpected coffee habit %+v", h)
+	}
+	if len(doc.Records) != 3 {
+		t.Errorf("expected 2 reading days and 1 coffee day, got %+v", doc.Records)
+	}
+}
+
+func TestReadLoop_NotABackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	os.WriteFile(path, []byte("hello"), 0o644)
+
+	if _, err := ReadLoop(path); err == nil {
+		t.Error("expected error for a file that is not a Loop backup")
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty.db")
+	database, _ := sql.Open("sqlite3", empty)
+	database.Exec(`CREATE TABLE other (id INTEGER)`)
+	database.Close()
+
+	if _, err := ReadLoop(empty); err == nil {
+		t.Error("expected error for a database without Loop tables")
+	}
+}
+
+func TestLoopFrequency(t *testing.T) {
+	tests := []struct {
+		num, den  int
+		wantCount int
+		wantUnit  string
+	}{
+		{1, 1, 0, ""},
+		{3, 7, 3, "week"},
+		{10, 30, 10, "month"},
+		{1, 2, 4, "week"},
+		{6, 7, 6, "week"},
+		{1, 14, 2, "month"},
+		{13, 14, 0, ""},
+	}
+
+	for _, tt := range tests {
+		count, unit := loopFrequency(tt.num, tt.den)
+		if count != tt.wantCount || unit != tt.wantUnit {
+			t.Errorf("loopFrequency(%d, %d) = %d, %q; want %d, %q", tt.num, tt.den, count, unit, tt.wantCount, tt.wantUnit)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package importer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Mode decides how imported data combines with what is already stored
+type Mode string
+
+const (
+	// ModeMerge matches imported habits to existing ones by description and
+	// adds only records for days not recorded yet
+	ModeMerge Mode = "merge"
+	// ModeReplace deletes every habit and record before importing
+	ModeReplace Mode = "replace"
+)
+
+// ParseMode validates a requested mode, defaulting to merge
+func ParseMode(value string) (Mode, error) {
+	switch Mode(value) {
+	case "", ModeMerge:
+		return ModeMerge, nil
+	case ModeReplace:
+		return ModeReplace, nil
+	}
+	return "", fmt.Errorf("unsupported import mode %q", value)
+}
+
+// Options control a single import
+type Options struct {
+	Mode Mode
+	// DryRun runs the import and reports what it would do without saving
+	DryRun bool
+}
+
+// Conflict is an imported record that was not saved because its day is
+// already recorded differently
+type Conflict struct {
+	Habit  string `json:"habit"`
+	Date   string `json:"date"`
+	Reason string `json:"reason"`
+}
+
+// Report describes what an import changed, or would change on a dry run
+type Report struct {
+	Mode             Mode       `json:"mode"`
+	DryRun           bool       `json:"dry_run"`
+	HabitsCreated    []string   `json:"habits_created"`
+	HabitsMatched    []string   `json:"habits_matched"`
+	HabitsDeleted    int        `json:"habits_deleted"`
+	RecordsCreated   int        `json:"records_created"`
+	RecordsUnchanged int        `json:"records_unchanged"`
+	RecordsDeleted   int        `json:"records_deleted"`
+	Conflicts        []Conflict `json:"conflicts"`
+}
+
+// Summary describes the report as human-readable lines
+func (r *Report) Summary() []string {
+	var lines []string
+	if r.DryRun {
+		lines = append(lines, fmt.Sprintf("Dry run (%s): nothing was saved", r.Mode))
+	}
+	if r.Mode == ModeReplace {
+		lines = append(lines, fmt.Sprintf("Deleted %d habits and %d records", r.HabitsDeleted, r.RecordsDeleted))
+	}
+	if len(r.HabitsCreated) > 0 {
+		lines = append(lines, fmt.Sprintf("Created %d habits: %s", len(r.HabitsCreated), strings.Join(r.HabitsCreated, ", ")))
+	}
+	if len(r.HabitsMatched) > 0 {
+		lines = append(lines, fmt.Sprintf("Merged into %d existing habits: %s", len(r.HabitsMatched), strings.Join(r.HabitsMatched, ", ")))
+	}
+	lines = append(lines, fmt.Sprintf("Added %d records, %d already present", r.RecordsCreated, r.RecordsUnchanged))
+	if len(r.Conflicts) > 0 {
+		lines = append(lines, fmt.Sprintf("%d conflicts were not imported:", len(r.Conflicts)))
+		for _, c := range r.Conflicts {
+			lines = append(lines, fmt.Sprintf("  %s on %s: %s", c.Habit, c.Date, c.Reason))
+		}
+	}
+	return lines
+}
@@ -0,0 +1,90 @@
+package importer
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/epalmerini/abitudini/internal/export"
+)
+
+// StoreAdapter defines the interface for data access
+type StoreAdapter interface {
+	Import(userID int, doc *export.Document, opts Options) (*Report, error)
+}
+
+type Service struct {
+	store StoreAdapter
+}
+
+func NewService(store StoreAdapter) *Service {
+	return &Service{store: store}
+}
+
+// Import validates the document and writes it into userID's habits according
+// to opts. Invalid documents are rejected as a whole before anything is
+// written.
+func (s *Service) Import(userID int, doc *export.Document, opts Options) (*Report, error) {
+	if err := normalize(doc); err != nil {
+		return nil, fmt.Errorf("invalid import: %w", err)
+	}
+	return s.store.Import(userID, doc, opts)
+}
+
+// normalize checks references, dates and statuses, and clears settings the
+// app would not accept from its own forms
+func normalize(doc *export.Document) error {
+	habits := make(map[int]bool, len(doc.Habits))
+	for i := range doc.Habits {
+		h := &doc.Habits[i]
+		if habits[h.ID] {
+			return fmt.Errorf("habit id %d appears more than once", h.ID)
+		}
+		habits[h.ID] = true
+
+		h.Description = strings.TrimSpace(h.Description)
+		if h.Description == "" {
+			return fmt.Errorf("habit %d has no description", h.ID)
+		}
+		if _, err := time.Parse("2006-01-02", h.StartDate); err != nil {
+			return fmt.Errorf("habit %q has an invalid start date %q", h.Description, h.StartDate)
+		}
+
+		days := []int{}
+		for _, d := range h.Days {
+			if d >= 0 && d <= 6 {
+				days = append(days, d)
+			}
+		}
+		h.Days = days
+
+		if h.TargetCount <= 0 || (h.TargetPeriod != "week" && h.TargetPeriod != "month") {
+			h.TargetCount, h.TargetPeriod = 0, ""
+		}
+		if h.DailyTarget <= 0 || math.IsInf(h.DailyTarget, 0) || math.IsNaN(h.DailyTarget) {
+			h.DailyTarget, h.Unit = 0, ""
+		}
+		h.GraceDays = min(max(h.GraceDays, 0), 31)
+	}
+
+	for i := range doc.Records {
+		r := &doc.Records[i]
+		if !habits[r.HabitID] {
+			return fmt.Errorf("record on %s refers to unknown habit id %d", r.Date, r.HabitID)
+		}
+		if _, err := time.Parse("2006-01-02", r.Date); err != nil {
+			return fmt.Errorf("record has an invalid date %q", r.Date)
+		}
+		if r.Status == "" {
+			r.Status = "done"
+		}
+		if r.Status != "done" && r.Status != "skipped" {
+			return fmt.Errorf("record on %s has an unknown status %q", r.Date, r.Status)
+		}
+		if math.IsInf(r.Value, 0) || math.IsNaN(r.Value) || r.Value < 0 {
+			return fmt.Errorf("record on %s has an invalid value", r.Date)
+		}
+	}
+	return nil
+}
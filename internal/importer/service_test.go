@@ -0,0 +1,90 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/epalmerini/abitudini/internal/export"
+)
+
+type mockImportStore struct {
+	doc *export.Document
+}
+
+func (m *mockImportStore) Import(userID int, doc *export.Document, opts Options) (*Report, error) {
+	m.doc = doc
+	return &Report{Mode: opts.Mode, DryRun: opts.DryRun}, nil
+}
+
+func TestImport_NormalizesHabits(t *testing.T) {
+	store := &mockImportStore{}
+	s := NewService(store)
+
+	doc := &export.Document{
+		Habits: []export.Habit{{
+			ID: 1, Description: "  Read ", StartDate: "2025-01-01",
+			Days: []int{1, 9}, TargetCount: 3, TargetPeriod: "year", DailyTarget: -2, Unit: "pages", GraceDays: 99,
+		}},
+		Records: []export.Record{{HabitID: 1, Date: "2025-01-02"}},
+	}
+
+	if _, err := s.Import(1, doc, Options{Mode: ModeMerge}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	h := store.doc.Habits[0]
+	if h.Description != "Read" || len(h.Days) != 1 || h.TargetCount != 0 || h.Unit != "" || h.GraceDays != 31 {
+		t.Errorf("unexpected normalized habit %+v", h)
+	}
+	if store.doc.Records[0].Status != "done" {
+		t.Errorf("expected missing status to default to done, got %q", store.doc.Records[0].Status)
+	}
+}
+
+func TestImport_RejectsInvalidDocuments(t *testing.T) {
+	habit := export.Habit{ID: 1, Description: "Read", StartDate: "2025-01-01"}
+	cases := map[string]*export.Document{
+		"no description": {Habits: []export.Habit{{ID: 1, StartDate: "2025-01-01"}}},
+		"bad start date": {Habits: []export.Habit{{ID: 1, Description: "Read", StartDate: "yesterday"}}},
+		"duplicate id":   {Habits: []export.Habit{habit, habit}},
+		"unknown habit":  {Habits: []export.Habit{habit}, Records: []export.Record{{HabitID: 2, Date: "2025-01-02"}}},
+		"bad date":       {Habits: []export.Habit{habit}, Records: []export.Record{{HabitID: 1, Date: "02/01/2025"}}},
+		"bad status":     {Habits: []export.Habit{habit}, Records: []export.Record{{HabitID: 1, Date: "2025-01-02", Status: "maybe"}}},
+	}
+
+	for name, doc := range cases {
+		store := &mockImportStore{}
+		_, err := NewService(store).Import(1, doc, Options{Mode: ModeMerge})
+		if err == nil || !strings.Contains(err.Error(), "invalid import") {
+			t.Errorf("%s: expected invalid import error, got %v", name, err)
+		}
+		if store.doc != nil {
+			t.Errorf("%s: expected nothing to reach the store", name)
+		}
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	if m, err := ParseMode(""); err != nil || m != ModeMerge {
+		t.Errorf("expected merge default, got %q, %v", m, err)
+	}
+	if _, err := ParseMode("append"); err == nil {
+		t.Error("expected error for unsupported mode")
+	}
+}
+
+func TestReport_Summary(t *testing.T) {
+	report := &Report{
+		Mode:          ModeMerge,
+		DryRun:        true,
+		HabitsCreated: []string{"Walk"},
+		Conflicts:     []Conflict{{Habit: "Read", Date: "2025-01-03", Reason: "already recorded"}},
+	}
+
+	summary := strings.Join(report.Summary(), "\n")
+	for _, want := range []string{"Dry run", "Created 1 habits: Walk", "Read on 2025-01-03: already recorded"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("expected %q in summary:\n%s", want, summary)
+		}
+	}
+}
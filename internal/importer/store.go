@@ -0,0 +1,196 @@
+package importer
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/epalmerini/abitudini/internal/export"
+	"github.com/epalmerini/abitudini/internal/habit"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Import writes the document into userID's habits inside a single
+// transaction. Records whose day is already recorded differently are
+// reported as conflicts instead of overwritten. A dry run rolls the
+// transaction back after building the report.
+func (s *Store) Import(userID int, doc *export.Document, opts Options) (*Report, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin import: %w", err)
+	}
+	defer tx.Rollback()
+
+	report := &Report{
+		Mode:          opts.Mode,
+		DryRun:        opts.DryRun,
+		HabitsCreated: []string{},
+		HabitsMatched: []string{},
+		Conflicts:     []Conflict{},
+	}
+
+	if opts.Mode == ModeReplace {
+		if report.RecordsDeleted, err = execCount(tx,
+			`DELETE FROM records WHERE habit_id IN (SELECT id FROM habits WHERE user_id = ?)`, userID,
+		); err != nil {
+			return nil, fmt.Errorf("failed to delete records: %w", err)
+		}
+		if report.HabitsDeleted, err = execCount(tx, `DELETE FROM habits WHERE user_id = ?`, userID); err != nil {
+			return nil, fmt.Errorf("failed to delete habits: %w", err)
+		}
+	}
+
+	existing, err := habitsByDescription(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(map[int]int, len(doc.Habits))
+	names := make(map[int]string, len(doc.Habits))
+	for _, h := range doc.Habits {
+		names[h.ID] = h.Description
+		if id, ok := existing[h.Description]; ok {
+			ids[h.ID] = id
+			report.HabitsMatched = append(report.HabitsMatched, h.Description)
+			continue
+		}
+
+		id, err := insertHabit(tx, userID, h)
+		if err != nil {
+			return nil, err
+		}
+		ids[h.ID] = id
+		existing[h.Description] = id
+		report.HabitsCreated = append(report.HabitsCreated, h.Description)
+	}
+
+	imported := make(map[string]bool, len(doc.Records))
+	for _, r := range doc.Records {
+		habitID := ids[r.HabitID]
+		key := fmt.Sprintf("%d|%s", habitID, r.Date)
+		if imported[key] {
+			report.Conflicts = append(report.Conflicts, Conflict{
+				Habit:  names[r.HabitID],
+				Date:   r.Date,
+				Reason: "the import contains this day more than once",
+			})
+			continue
+		}
+		imported[key] = true
+
+		var status, note string
+		var value float64
+		err := tx.QueryRow(
+			`SELECT status, value, note FROM records WHERE habit_id = ? AND record_date = ?`,
+			habitID, r.Date,
+		).Scan(&status, &value, &note)
+		if err == nil {
+			if status == r.Status && value == r.Value && note == r.Note {
+				report.RecordsUnchanged++
+				continue
+			}
+			report.Conflicts = append(report.Conflicts, Conflict{
+				Habit: names[r.HabitID],
+				Date:  r.Date,
+				Reason: fmt.Sprintf("already recorded as %s (value %g), import has %s (value %g)",
+					status, value, r.Status, r.Value),
+			})
+			continue
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("failed to check record: %w", err)
+		}
+
+		if _, err := tx.Exec(
+			`INSERT INTO records (habit_id, record_date, completed_at, value, note, status, created_at)
+			 VALUES (?, ?, COALESCE(?, CURRENT_TIMESTAMP), ?, ?, ?, COALESCE(?, CURRENT_TIMESTAMP))`,
+			habitID, r.Date, storedTimestamp(r.CompletedAt), r.Value, r.Note, r.Status, storedTimestamp(r.CreatedAt),
+		); err != nil {
+			return nil, fmt.Errorf("failed to import record: %w", err)
+		}
+		report.RecordsCreated++
+	}
+
+	if opts.DryRun {
+		return report, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit import: %w", err)
+	}
+	return report, nil
+}
+
+// habitsByDescription maps descriptions of userID's habits to their IDs,
+// keeping the oldest habit when several share a description
+func habitsByDescription(tx *sql.Tx, userID int) (map[string]int, error) {
+	rows, err := tx.Query(`SELECT id, description FROM habits WHERE user_id = ? ORDER BY id DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query habits: %w", err)
+	}
+	defer rows.Close()
+
+	habits := make(map[string]int)
+	for rows.Next() {
+		var id int
+		var description string
+		if err := rows.Scan(&id, &description); err != nil {
+			return nil, fmt.Errorf("failed to scan habit: %w", err)
+		}
+		habits[description] = id
+	}
+	return habits, rows.Err()
+}
+
+func insertHabit(tx *sql.Tx, userID int, h export.Habit) (int, error) {
+	days := make([]time.Weekday, len(h.Days))
+	for i, d := range h.Days {
+		days[i] = time.Weekday(d)
+	}
+
+	result, err := tx.Exec(
+		`INSERT INTO habits (user_id, description, start_date, color, days, target_count, target_period,
+		                     unit, daily_target, grace_days, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, COALESCE(?, CURRENT_TIMESTAMP))`,
+		userID, h.Description, h.StartDate, h.Color, habit.EncodeDays(days), h.TargetCount, h.TargetPeriod,
+		h.Unit, h.DailyTarget, h.GraceDays, storedTimestamp(h.CreatedAt),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to import habit %q: %w", h.Description, err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to import habit %q: %w", h.Description, err)
+	}
+	return int(id), nil
+}
+
+func execCount(tx *sql.Tx, query string, args ...any) (int, error) {
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// storedTimestamp converts an exported RFC 3339 timestamp to the layout
+// SQLite's CURRENT_TIMESTAMP uses. Missing or invalid values become nil so
+// the database fills in the current time.
+func storedTimestamp(value string) any {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC().Format("2006-01-02 15:04:05")
+	}
+	if _, err := time.Parse("2006-01-02 15:04:05", value); err == nil {
+		return value
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package importer
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/epalmerini/abitudini/internal/export"
+	"github.com/epalmerini/abitudini/internal/testhelpers"
+)
+
+// seed gives user 1 a habit with two records
+func seed(t *testing.T, db *sql.DB) {
+	t.Helper()
+	if _, err := db.Exec(`INSERT INTO habits (id, user_id, description, start_date, color) VALUES (1, 1, 'Read', '2025-01-01', '')`); err != nil {
+		t.Fatalf("failed to create habit: %v", err)
+	}
+	if _, err := db.Exec(
+		`INSERT INTO records (habit_id, record_date, completed_at, value, status)
+		 VALUES (1, '2025-01-02', '2025-01-02 20:00:00', 1, 'done'),
+		        (1, '2025-01-03', '2025-01-03 20:00:00', 0, 'skipped')`,
+	); err != nil {
+		t.Fatalf("failed to create records: %v", err)
+	}
+}
+
+func importDocument() *export.Document {
+	return &export.Document{
+		Version: export.FormatVersion,
+		Habits: []export.Habit{
+			{ID: 10, Description: "Read", StartDate: "2025-01-01"},
+			{ID: 11, Description: "Walk", StartDate: "2025-01-01", Days: []int{1, 3}},
+		},
+		Records: []export.Record{
+			{HabitID: 10, Date: "2025-01-02", Status: "done", Value: 1},            // identical
+			{HabitID: 10, Date: "2025-01-03", Status: "done", Value: 1},            // conflict
+			{HabitID: 10, Date: "2025-01-04", Status: "done", Value: 1},            // new
+			{HabitID: 11, Date: "2025-01-06", Status: "done", Value: 1, Note: "x"}, // new habit
+		},
+	}
+}
+
+func count(t *testing.T, db *sql.DB, query string) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(query).Scan(&n); err != nil {
+		t.Fatalf("failed to count: %v", err)
+	}
+	return n
+}
+
+func TestImportStore_Merge(t *testing.T) {
+	db := testhelpers.NewTestDB(t)
+	seed(t, db)
+	store := NewStore(db)
+
+	report, err := store.Import(1, importDocument(), Options{Mode: ModeMerge})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(report.HabitsMatched) != 1 || len(report.HabitsCreated) != 1 {
+		t.Errorf("expected 1 matched and 1 created habit, got %+v", report)
+	}
+	if report.RecordsCreated != 2 || report.RecordsUnchanged != 1 {
+		t.Errorf("expected 2 created and 1 unchanged record, got %+v", report)
+	}
+	if len(report.Conflicts) != 1 || report.Conflicts[0].Date != "2025-01-03" {
+		t.Fatalf("expected a conflict on 2025-01-03, got %+v", report.Conflicts)
+	}
+
+	var status string
+	db.QueryRow(`SELECT status FROM records WHERE habit_id = 1 AND record_date = '2025-01-03'`).Scan(&status)
+	if status != "skipped" {
+		t.Errorf("expected conflicting record to be kept, got %s", status)
+	}
+	if n := count(t, db, `SELECT COUNT(*) FROM records`); n != 4 {
+		t.Errorf("expected 4 records, got %d", n)
+	}
+}
+
+func TestImportStore_DryRunSavesNothing(t *testing.T) {
+	db := testhelpers.NewTestDB(t)
+	seed(t, db)
+	store := NewStore(db)
+
+	report, err := store.Import(1, importDocument(), Options{Mode: ModeMerge, DryRun: true})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if report.RecordsCreated != 2 || len(report.HabitsCreated) != 1 {
+		t.Errorf("expected dry run to report the changes, got %+v", report)
+	}
+	if n := count(t, db, `SELECT COUNT(*) FROM habits`); n != 1 {
+		t.Errorf("expected habits to be untouched, got %d", n)
+	}
+	if n := count(t, db, `SELECT COUNT(*) FROM records`); n != 2 {
+		t.Errorf("expected records to be untouched, got %d", n)
+	}
+}
+
+func TestImportStore_Replace(t *testing.T) {
+	db := testhelpers.NewTestDB(t)
+	seed(t, db)
+	store := NewStore(db)
+
+	// Another user's habits are neither replaced nor matched
+	if _, err := db.Exec(`INSERT INTO habits (id, user_id, description, start_date, color) VALUES (2, 2, 'Walk', '2025-01-01', '')`); err != nil {
+		t.Fatalf("failed to create habit: %v", err)
+	}
+
+	report, err := store.Import(1, importDocument(), Options{Mode: ModeReplace})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if report.HabitsDeleted != 1 || report.RecordsDeleted != 2 {
+		t.Errorf("expected 1 habit and 2 records deleted, got %+v", report)
+	}
+	if report.RecordsCreated != 4 || len(report.Conflicts) != 0 {
+		t.Errorf("expected all 4 records imported without conflicts, got %+v", report)
+	}
+	if n := count(t, db, `SELECT COUNT(*) FROM habits WHERE user_id = 1`); n != 2 {
+		t.Errorf("expected 2 habits, got %d", n)
+	}
+	if n := count(t, db, `SELECT COUNT(*) FROM habits WHERE id = 2`); n != 1 {
+		t.Errorf("expected the other user's habit to be kept, got %d", n)
+	}
+
+	var days string
+	db.QueryRow(`SELECT days FROM habits WHERE description = 'Walk' AND user_id = 1`).Scan(&days)
+	if days != "1,3" {
+		t.Errorf("expected schedule 1,3, got %q", days)
+	}
+}
+
+func TestImportStore_DuplicateInImport(t *testing.T) {
+	db := testhelpers.NewTestDB(t)
+	store := NewStore(db)
+
+	doc := &export.Document{
+		Habits: []export.Habit{{ID: 1, Description: "Read", StartDate: "2025-01-01"}},
+		Records: []export.Record{
+			{HabitID: 1, Date: "2025-01-02", Status: "done", Value: 1},
+			{HabitID: 1, Date: "2025-01-02", Status: "skipped"},
+		},
+	}
+
+	report, err := store.Import(1, doc, Options{Mode: ModeMerge})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if report.RecordsCreated != 1 || len(report.Conflicts) != 1 {
+		t.Errorf("expected the duplicate day to be reported, got %+v", report)
+	}
+}
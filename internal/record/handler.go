@@ -1,8 +1,12 @@
 package record
 
 import (
+	"errors"
 	"fmt"
+	"html"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/epalmerini/abitudini/internal/habit"
@@ -11,9 +15,14 @@ import (
 
 // HandlerService interface for dependency injection
 type HandlerService interface {
-	MarkDoneToday(habitID int) error
-	GetContributionData(habitID int, from, to time.Time) ([]ContributionDay, error)
-	GetHabit(habitID int) (*habit.Habit, error)
+	MarkDoneToday(userID, habitID int, note string) error
+	SkipToday(userID, habitID int, note string) error
+	AddAmountToday(userID, habitID int, amount float64) error
+	MarkDate(userID, habitID int, date time.Time, note string) error
+	UnmarkDate(userID, habitID int, date time.Time) error
+	GetContributionData(userID, habitID int, from, to time.Time) ([]ContributionDay, error)
+	GetHabit(userID, habitID int) (*habit.Habit, error)
+	GetJournal(userID, habitID int) ([]Record, error)
 }
 
 type Handler struct {
@@ -32,19 +41,29 @@ func (h *Handler) MarkDoneToday(w http.ResponseWriter, r *http.Request) {
 
 	habitID, err := h.ExtractIntPathParam(r, "id")
 	if err != nil {
-		h.WriteError(w, "Invalid habit ID", http.StatusBadRequest)
+		h.RespondError(w, r, "Invalid habit ID", http.StatusBadRequest)
 		return
 	}
 
-	if err := h.service.MarkDoneToday(habitID); err != nil {
-		h.WriteError(w, err.Error(), http.StatusInternalServerError)
+	if err := h.ParseInput(r); err != nil {
+		h.RespondError(w, r, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.MarkDoneToday(h.UserID(r), habitID, r.FormValue("note")); err != nil {
+		h.RespondError(w, r, err.Error(), errorStatus(err))
 		return
 	}
 
 	// Get updated habit and return it
-	habitData, err := h.service.GetHabit(habitID)
+	habitData, err := h.service.GetHabit(h.UserID(r), habitID)
 	if err != nil {
-		h.WriteError(w, err.Error(), http.StatusInternalServerError)
+		h.RespondError(w, r, err.Error(), errorStatus(err))
+		return
+	}
+
+	if h.WantsJSON(r) {
+		h.WriteJSON(w, http.StatusOK, habitData)
 		return
 	}
 
@@ -52,6 +71,195 @@ func (h *Handler) MarkDoneToday(w http.ResponseWriter, r *http.Request) {
 	h.WriteHTML(w, response)
 }
 
+// SkipToday records today as an excused day that does not break the streak
+func (h *Handler) SkipToday(w http.ResponseWriter, r *http.Request) {
+	if !h.ValidateMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	habitID, err := h.ExtractIntPathParam(r, "id")
+	if err != nil {
+		h.RespondError(w, r, "Invalid habit ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.ParseInput(r); err != nil {
+		h.RespondError(w, r, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.SkipToday(h.UserID(r), habitID, r.FormValue("note")); err != nil {
+		h.RespondError(w, r, err.Error(), errorStatus(err))
+		return
+	}
+
+	habitData, err := h.service.GetHabit(h.UserID(r), habitID)
+	if err != nil {
+		h.RespondError(w, r, err.Error(), errorStatus(err))
+		return
+	}
+
+	h.writeHabit(w, r, habitData)
+}
+
+func (h *Handler) AddAmount(w http.ResponseWriter, r *http.Request) {
+	if !h.ValidateMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	habitID, err := h.ExtractIntPathParam(r, "id")
+	if err != nil {
+		h.RespondError(w, r, "Invalid habit ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.ParseInput(r); err != nil {
+		h.RespondError(w, r, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	amount, err := strconv.ParseFloat(r.FormValue("amount"), 64)
+	if err != nil || amount <= 0 || math.IsInf(amount, 0) {
+		h.RespondError(w, r, "Invalid amount", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.AddAmountToday(h.UserID(r), habitID, amount); err != nil {
+		h.RespondError(w, r, err.Error(), errorStatus(err))
+		return
+	}
+
+	habitData, err := h.service.GetHabit(h.UserID(r), habitID)
+	if err != nil {
+		h.RespondError(w, r, err.Error(), errorStatus(err))
+		return
+	}
+
+	h.writeHabit(w, r, habitData)
+}
+
+// MarkDate marks the day in the {date} path parameter as done
+func (h *Handler) MarkDate(w http.ResponseWriter, r *http.Request) {
+	if !h.ValidateMethod(w, r, http.MethodPut) {
+		return
+	}
+	if err := h.ParseInput(r); err != nil {
+		h.RespondError(w, r, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	h.changeDate(w, r, func(habitID int, date time.Time) error {
+		return h.service.MarkDate(h.UserID(r), habitID, date, r.FormValue("note"))
+	})
+}
+
+// UnmarkDate removes the record for the day in the {date} path parameter
+func (h *Handler) UnmarkDate(w http.ResponseWriter, r *http.Request) {
+	if !h.ValidateMethod(w, r, http.MethodDelete) {
+		return
+	}
+	h.changeDate(w, r, func(habitID int, date time.Time) error {
+		return h.service.UnmarkDate(h.UserID(r), habitID, date)
+	})
+}
+
+// changeDate applies change to the habit and date from the path and
+// responds with the re-rendered habit card
+func (h *Handler) changeDate(w http.ResponseWriter, r *http.Request, change func(habitID int, date time.Time) error) {
+	habitID, err := h.ExtractIntPathParam(r, "id")
+	if err != nil {
+		h.RespondError(w, r, "Invalid habit ID", http.StatusBadRequest)
+		return
+	}
+
+	date, err := time.Parse("2006-01-02", r.PathValue("date"))
+	if err != nil {
+		h.RespondError(w, r, "Invalid date", http.StatusBadRequest)
+		return
+	}
+
+	if err := change(habitID, date); err != nil {
+		h.RespondError(w, r, err.Error(), errorStatus(err))
+		return
+	}
+
+	habitData, err := h.service.GetHabit(h.UserID(r), habitID)
+	if err != nil {
+		h.RespondError(w, r, err.Error(), errorStatus(err))
+		return
+	}
+
+	h.writeHabit(w, r, habitData)
+}
+
+// errorStatus maps a service error to its HTTP status
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrDateOutOfRange), errors.Is(err, ErrNotScheduled):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrHabitNotFound):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// writeHabit responds with the habit as JSON or as its re-rendered card
+func (h *Handler) writeHabit(w http.ResponseWriter, r *http.Request, habitData *habit.Habit) {
+	if h.WantsJSON(r) {
+		h.WriteJSON(w, http.StatusOK, habitData)
+		return
+	}
+	h.WriteHTML(w, habit.RenderHabit(habitData))
+}
+
+// GetJournal renders the habit's notes in reverse chronological order
+func (h *Handler) GetJournal(w http.ResponseWriter, r *http.Request) {
+	if !h.ValidateMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	habitID, err := h.ExtractIntPathParam(r, "id")
+	if err != nil {
+		h.RespondError(w, r, "Invalid habit ID", http.StatusBadRequest)
+		return
+	}
+
+	entries, err := h.service.GetJournal(h.UserID(r), habitID)
+	if err != nil {
+		h.RespondError(w, r, err.Error(), errorStatus(err))
+		return
+	}
+
+	if h.WantsJSON(r) {
+		if entries == nil {
+			entries = []Record{}
+		}
+		h.WriteJSON(w, http.StatusOK, entries)
+		return
+	}
+
+	if len(entries) == 0 {
+		h.WriteHTML(w, `<p class="journal-empty">No notes yet</p>`)
+		return
+	}
+
+	out := `<ul class="journal">`
+	for _, e := range entries {
+		status := ""
+		if e.Status == StatusSkipped {
+			status = ` <span class="journal-status">skipped</span>`
+		}
+		out += fmt.Sprintf(`<li><time datetime="%s">%s</time>%s %s</li>`,
+			e.RecordDate.Format("2006-01-02"),
+			e.RecordDate.Format("Jan 02, 2006"),
+			status,
+			html.EscapeString(e.Note),
+		)
+	}
+	out += `</ul>`
+
+	h.WriteHTML(w, out)
+}
+
 func (h *Handler) GetContribution(w http.ResponseWriter, r *http.Request) {
 	if !h.ValidateMethod(w, r, http.MethodGet) {
 		return
@@ -59,7 +267,7 @@ func (h *Handler) GetContribution(w http.ResponseWriter, r *http.Request) {
 
 	habitID, err := h.ExtractIntPathParam(r, "id")
 	if err != nil {
-		h.WriteError(w, "Invalid habit ID", http.StatusBadRequest)
+		h.RespondError(w, r, "Invalid habit ID", http.StatusBadRequest)
 		return
 	}
 
@@ -74,9 +282,17 @@ func (h *Handler) GetContribution(w http.ResponseWriter, r *http.Request) {
 		to = time.Now()
 	}
 
-	contributions, err := h.service.GetContributionData(habitID, from, to)
+	contributions, err := h.service.GetContributionData(h.UserID(r), habitID, from, to)
 	if err != nil {
-		h.WriteError(w, err.Error(), http.StatusInternalServerError)
+		h.RespondError(w, r, err.Error(), errorStatus(err))
+		return
+	}
+
+	if h.WantsJSON(r) {
+		if contributions == nil {
+			contributions = []ContributionDay{}
+		}
+		h.WriteJSON(w, http.StatusOK, contributions)
 		return
 	}
 
@@ -118,14 +334,7 @@ func (h *Handler) GetContribution(w http.ResponseWriter, r *http.Request) {
 			// Write current week
 			html += `<div class="contribution-week">`
 			for _, d := range currentWeek {
-				level := "level-0"
-				if d.Completed {
-					level = "level-4"
-				}
-				html += fmt.Sprintf(`<div class="day %s" title="%s"></div>`,
-					level,
-					d.Date.Format("2006-01-02"),
-				)
+				html += renderDay(habitID, d)
 			}
 			html += `</div>`
 			currentWeek = []ContributionDay{}
@@ -138,14 +347,7 @@ func (h *Handler) GetContribution(w http.ResponseWriter, r *http.Request) {
 		if i == len(contributions)-1 {
 			html += `<div class="contribution-week">`
 			for _, d := range currentWeek {
-				level := "level-0"
-				if d.Completed {
-					level = "level-4"
-				}
-				html += fmt.Sprintf(`<div class="day %s" title="%s"></div>`,
-					level,
-					d.Date.Format("2006-01-02"),
-				)
+				html += renderDay(habitID, d)
 			}
 			html += `</div>`
 		}
@@ -155,3 +357,39 @@ func (h *Handler) GetContribution(w http.ResponseWriter, r *http.Request) {
 
 	h.WriteHTML(w, html)
 }
+
+// renderDay renders a single contribution grid cell. Editable cells toggle
+// the day's record when clicked.
+func renderDay(habitID int, d ContributionDay) string {
+	class := fmt.Sprintf("level-%d", d.Level)
+	if d.Skipped {
+		class = "skipped"
+	} else if d.Level == 0 && !d.Scheduled {
+		class = "off-schedule"
+	}
+
+	date := d.Date.Format("2006-01-02")
+	title := date
+	if d.Value > 0 {
+		title += ": " + strconv.FormatFloat(d.Value, 'f', -1, 64)
+	}
+	if d.Skipped {
+		title += " (skipped)"
+	}
+	if d.Note != "" {
+		title += " — " + d.Note
+	}
+
+	toggle := ""
+	if d.Editable {
+		method := "hx-put"
+		if d.Completed || d.Skipped {
+			method = "hx-delete"
+		}
+		toggle = fmt.Sprintf(` %s="/api/habits/%d/records/%s" hx-target="#habit-%d" hx-swap="outerHTML"`,
+			method, habitID, date, habitID)
+		class += " editable"
+	}
+
+	return fmt.Sprintf(`<div class="day %s" title="%s"%s></div>`, class, html.EscapeString(title), toggle)
+}
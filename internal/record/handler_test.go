@@ -9,26 +9,56 @@ import (
 	"time"
 
 	"github.com/epalmerini/abitudini/internal/habit"
+	"github.com/epalmerini/abitudini/internal/shared"
+	"github.com/epalmerini/abitudini/internal/testhelpers"
 )
 
 type mockRecordHandlerService struct {
 	contributions []ContributionDay
 	habit         *habit.Habit
+	journal       []Record
+	note          string
 	err           error
 }
 
-func (m *mockRecordHandlerService) MarkDoneToday(habitID int) error {
+func (m *mockRecordHandlerService) MarkDoneToday(userID, habitID int, note string) error {
+	m.note = note
 	return m.err
 }
 
-func (m *mockRecordHandlerService) GetContributionData(habitID int, from, to time.Time) ([]ContributionDay, error) {
+func (m *mockRecordHandlerService) SkipToday(userID, habitID int, note string) error {
+	m.note = note
+	return m.err
+}
+
+func (m *mockRecordHandlerService) AddAmountToday(userID, habitID int, amount float64) error {
+	return m.err
+}
+
+func (m *mockRecordHandlerService) MarkDate(userID, habitID int, date time.Time, note string) error {
+	m.note = note
+	return m.err
+}
+
+func (m *mockRecordHandlerService) UnmarkDate(userID, habitID int, date time.Time) error {
+	return m.err
+}
+
+func (m *mockRecordHandlerService) GetContributionData(userID, habitID int, from, to time.Time) ([]ContributionDay, error) {
 	if m.err != nil {
 		return nil, m.err
 	}
 	return m.contributions, nil
 }
 
-func (m *mockRecordHandlerService) GetHabit(habitID int) (*habit.Habit, error) {
+func (m *mockRecordHandlerService) GetJournal(userID, habitID int) ([]Record, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.journal, nil
+}
+
+func (m *mockRecordHandlerService) GetHabit(userID, habitID int) (*habit.Habit, error) {
 	if m.err != nil {
 		return nil, m.err
 	}
@@ -97,6 +127,73 @@ func TestRecordMarkDoneToday_ServiceError(t *testing.T) {
 	}
 }
 
+func TestRecordMarkDoneToday_OtherUsersHabit(t *testing.T) {
+	handler := NewHandler(&mockRecordHandlerService{err: ErrHabitNotFound})
+
+	req := httptest.NewRequest("POST", "/api/habits/1/done-today", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	handler.MarkDoneToday(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+}
+
+func TestRecordMarkDoneToday_NotScheduled(t *testing.T) {
+	handler := NewHandler(&mockRecordHandlerService{err: ErrNotScheduled})
+
+	req := httptest.NewRequest("POST", "/api/habits/1/done-today", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	handler.MarkDoneToday(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+}
+
+func TestRecordHandler_MissingHabit(t *testing.T) {
+	db := testhelpers.NewTestDB(t)
+	if _, err := db.Exec(`INSERT INTO habits (id, user_id, description, start_date, color) VALUES (1, 2, 'Read', '2025-01-01', '')`); err != nil {
+		t.Fatalf("failed to create habit: %v", err)
+	}
+	handler := NewHandler(NewService(NewStore(db), habit.NewService(habit.NewStore(db))))
+
+	// Habit 1 belongs to user 2, and habit 9 does not exist at all
+	tests := []struct {
+		name   string
+		method string
+		target string
+		serve  func(w http.ResponseWriter, r *http.Request)
+	}{
+		{"done today", "POST", "/api/habits/%s/done-today", handler.MarkDoneToday},
+		{"mark date", "PUT", "/api/habits/%s/records/2025-01-06", handler.MarkDate},
+		{"unmark date", "DELETE", "/api/habits/%s/records/2025-01-06", handler.UnmarkDate},
+		{"contribution", "GET", "/api/habits/%s/contribution", handler.GetContribution},
+	}
+
+	for _, tt := range tests {
+		for _, id := range []string{"1", "9"} {
+			t.Run(tt.name+" "+id, func(t *testing.T) {
+				req := httptest.NewRequest(tt.method, strings.Replace(tt.target, "%s", id, 1), nil)
+				req.SetPathValue("id", id)
+				req.SetPathValue("date", "2025-01-06")
+				req = req.WithContext(shared.WithUser(req.Context(), shared.User{ID: 1, Username: "alice"}))
+				w := httptest.NewRecorder()
+
+				tt.serve(w, req)
+
+				if w.Code != http.StatusNotFound {
+					t.Errorf("expected status 404, got %d", w.Code)
+				}
+			})
+		}
+	}
+}
+
 func TestRecordMarkDoneToday_GetHabitError(t *testing.T) {
 	service := &mockRecordHandlerService{err: errors.New("fetch failed")}
 	handler := NewHandler(service)
@@ -235,4 +332,322 @@ func TestRecordGetContribution_InvalidDateFormat(t *testing.T) {
 	}
 }
 
+func TestAddAmount_Success(t *testing.T) {
+	h := &habit.Habit{ID: 1, Description: "Water", DailyTarget: 8}
+	handler := NewHandler(&mockRecordHandlerService{habit: h})
+
+	req := httptest.NewRequest("POST", "/api/habits/1/add-amount", strings.NewReader("amount=2"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	handler.AddAmount(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+}
+
+func TestAddAmount_InvalidAmount(t *testing.T) {
+	handler := NewHandler(&mockRecordHandlerService{})
+
+	for _, amount := range []string{"", "abc", "0", "-3"} {
+		req := httptest.NewRequest("POST", "/api/habits/1/add-amount", strings.NewReader("amount="+amount))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetPathValue("id", "1")
+		w := httptest.NewRecorder()
+
+		handler.AddAmount(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("amount %q: expected status 400, got %d", amount, w.Code)
+		}
+	}
+}
+
+func TestRenderDay_Levels(t *testing.T) {
+	day := time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC)
+
+	html := renderDay(1, ContributionDay{Date: day, Scheduled: true, Level: 2, Value: 4})
+	if !strings.Contains(html, "level-2") || !strings.Contains(html, "2025-01-06: 4") {
+		t.Errorf("unexpected cell %s", html)
+	}
+
+	html = renderDay(1, ContributionDay{Date: day})
+	if !strings.Contains(html, "off-schedule") {
+		t.Errorf("expected off-schedule cell, got %s", html)
+	}
+}
+
+func TestRenderDay_Editable(t *testing.T) {
+	day := time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC)
+
+	html := renderDay(3, ContributionDay{Date: day, Scheduled: true, Editable: true})
+	if !strings.Contains(html, `hx-put="/api/habits/3/records/2025-01-06"`) {
+		t.Errorf("expected missed day to be markable, got %s", html)
+	}
+
+	html = renderDay(3, ContributionDay{Date: day, Scheduled: true, Completed: true, Level: 4, Editable: true})
+	if !strings.Contains(html, `hx-delete="/api/habits/3/records/2025-01-06"`) {
+		t.Errorf("expected completed day to be unmarkable, got %s", html)
+	}
+
+	html = renderDay(3, ContributionDay{Date: day, Scheduled: true})
+	if strings.Contains(html, "hx-") {
+		t.Errorf("expected read-only cell, got %s", html)
+	}
+}
+
+func TestMarkDate_Success(t *testing.T) {
+	h := &habit.Habit{ID: 1, Description: "Test"}
+	handler := NewHandler(&mockRecordHandlerService{habit: h})
+
+	req := httptest.NewRequest("PUT", "/api/habits/1/records/2025-01-06", nil)
+	req.SetPathValue("id", "1")
+	req.SetPathValue("date", "2025-01-06")
+	w := httptest.NewRecorder()
+
+	handler.MarkDate(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "habit-1") {
+		t.Error("expected re-rendered habit card in response")
+	}
+}
+
+func TestMarkDate_InvalidDate(t *testing.T) {
+	handler := NewHandler(&mockRecordHandlerService{})
+
+	req := httptest.NewRequest("PUT", "/api/habits/1/records/yesterday", nil)
+	req.SetPathValue("id", "1")
+	req.SetPathValue("date", "yesterday")
+	w := httptest.NewRecorder()
+
+	handler.MarkDate(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+}
+
+func TestUnmarkDate_OutOfRange(t *testing.T) {
+	handler := NewHandler(&mockRecordHandlerService{err: ErrDateOutOfRange})
+
+	req := httptest.NewRequest("DELETE", "/api/habits/1/records/2099-01-01", nil)
+	req.SetPathValue("id", "1")
+	req.SetPathValue("date", "2099-01-01")
+	w := httptest.NewRecorder()
 
+	handler.UnmarkDate(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+}
+
+func TestUnmarkDate_WrongMethod(t *testing.T) {
+	handler := NewHandler(&mockRecordHandlerService{})
+	req := httptest.NewRequest("PUT", "/api/habits/1/records/2025-01-06", nil)
+	w := httptest.NewRecorder()
+
+	handler.UnmarkDate(w, req)
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+func TestRecordMarkDoneToday_WithNote(t *testing.T) {
+	service := &mockRecordHandlerService{habit: &habit.Habit{ID: 1, Description: "Run"}}
+	handler := NewHandler(service)
+
+	req := httptest.NewRequest("POST", "/api/habits/1/done-today", strings.NewReader("note=ran+5k+in+the+rain"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	handler.MarkDoneToday(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+	if service.note != "ran 5k in the rain" {
+		t.Errorf("expected note to be passed to service, got %q", service.note)
+	}
+}
+
+func TestGetJournal_Success(t *testing.T) {
+	journal := []Record{
+		{RecordDate: time.Date(2025, 1, 7, 0, 0, 0, 0, time.UTC), Note: "<b>rainy</b>"},
+		{RecordDate: time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC), Note: "first run"},
+	}
+	handler := NewHandler(&mockRecordHandlerService{journal: journal})
+
+	req := httptest.NewRequest("GET", "/api/habits/1/journal", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	handler.GetJournal(w, req)
+
+	body := w.Body.String()
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+	if strings.Contains(body, "<b>") {
+		t.Error("expected notes to be HTML-escaped")
+	}
+	if strings.Index(body, "rainy") > strings.Index(body, "first run") {
+		t.Error("expected newest note first")
+	}
+}
+
+func TestGetJournal_Empty(t *testing.T) {
+	handler := NewHandler(&mockRecordHandlerService{})
+
+	req := httptest.NewRequest("GET", "/api/habits/1/journal", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	handler.GetJournal(w, req)
+
+	if !strings.Contains(w.Body.String(), "No notes yet") {
+		t.Error("expected empty journal message")
+	}
+}
+
+func TestRenderDay_NoteTooltip(t *testing.T) {
+	day := time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC)
+
+	html := renderDay(1, ContributionDay{Date: day, Scheduled: true, Completed: true, Level: 4, Note: `"quoted" note`})
+	if !strings.Contains(html, "&#34;quoted&#34; note") {
+		t.Errorf("expected escaped note in tooltip, got %s", html)
+	}
+}
+
+func TestSkipToday_Success(t *testing.T) {
+	service := &mockRecordHandlerService{habit: &habit.Habit{ID: 1, Description: "Run", SkippedToday: true}}
+	handler := NewHandler(service)
+
+	req := httptest.NewRequest("POST", "/api/habits/1/skip-today", strings.NewReader("note=sick"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	handler.SkipToday(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+	if service.note != "sick" {
+		t.Errorf("expected note to be passed to service, got %q", service.note)
+	}
+	if !strings.Contains(w.Body.String(), "Skipped today") {
+		t.Error("expected skipped card in response")
+	}
+}
+
+func TestSkipToday_ServiceError(t *testing.T) {
+	handler := NewHandler(&mockRecordHandlerService{err: errors.New("skip failed")})
+
+	req := httptest.NewRequest("POST", "/api/habits/1/skip-today", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	handler.SkipToday(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", w.Code)
+	}
+}
+
+func TestRenderDay_Skipped(t *testing.T) {
+	day := time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC)
+
+	html := renderDay(1, ContributionDay{Date: day, Scheduled: true, Skipped: true, Editable: true})
+	if !strings.Contains(html, "day skipped") {
+		t.Errorf("expected skipped cell style, got %s", html)
+	}
+	if !strings.Contains(html, "hx-delete") {
+		t.Errorf("expected skipped day to be clearable, got %s", html)
+	}
+}
+
+func TestRecordMarkDoneToday_JSON(t *testing.T) {
+	service := &mockRecordHandlerService{habit: &habit.Habit{ID: 1, Description: "Run", CompletedToday: true}}
+	handler := NewHandler(service)
+
+	req := httptest.NewRequest("POST", "/api/habits/1/done-today", strings.NewReader(`{"note": "ran 5k"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	handler.MarkDoneToday(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+	if service.note != "ran 5k" {
+		t.Errorf("expected note from JSON body, got %q", service.note)
+	}
+	if !strings.Contains(w.Body.String(), `"completed_today":true`) {
+		t.Errorf("expected habit JSON, got %s", w.Body.String())
+	}
+}
+
+func TestAddAmount_JSON(t *testing.T) {
+	handler := NewHandler(&mockRecordHandlerService{habit: &habit.Habit{ID: 1}})
+
+	req := httptest.NewRequest("POST", "/api/habits/1/add-amount", strings.NewReader(`{"amount": 2.5}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	handler.AddAmount(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+}
+
+func TestRecordGetContribution_JSON(t *testing.T) {
+	service := &mockRecordHandlerService{
+		contributions: []ContributionDay{{Date: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), Completed: true, Level: 4}},
+	}
+	handler := NewHandler(service)
+
+	req := httptest.NewRequest("GET", "/api/habits/1/contribution", nil)
+	req.Header.Set("Accept", "application/json")
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	handler.GetContribution(w, req)
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected JSON content type, got %s", ct)
+	}
+	if !strings.Contains(w.Body.String(), `"level":4`) {
+		t.Errorf("expected contribution JSON, got %s", w.Body.String())
+	}
+}
+
+func TestMarkDate_JSONOutOfRange(t *testing.T) {
+	handler := NewHandler(&mockRecordHandlerService{err: ErrDateOutOfRange})
+
+	req := httptest.NewRequest("PUT", "/api/habits/1/records/2020-01-01", nil)
+	req.Header.Set("Accept", "application/json")
+	req.SetPathValue("id", "1")
+	req.SetPathValue("date", "2020-01-01")
+	w := httptest.NewRecorder()
+
+	handler.MarkDate(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `"status":400`) {
+		t.Errorf("expected JSON error, got %s", w.Body.String())
+	}
+}
@@ -2,15 +2,34 @@ package record
 
 import "time"
 
+// Status describes what happened on a recorded day
+type Status string
+
+const (
+	StatusDone Status = "done"
+	// StatusSkipped marks an excused day (sick, travelling) that neither
+	// counts toward nor breaks a streak
+	StatusSkipped Status = "skipped"
+)
+
 type Record struct {
 	ID          int       `json:"id"`
 	HabitID     int       `json:"habit_id"`
 	RecordDate  time.Time `json:"record_date"`
 	CompletedAt time.Time `json:"completed_at"`
+	Value       float64   `json:"value"`
+	Note        string    `json:"note"`
+	Status      Status    `json:"status"`
 	CreatedAt   time.Time `json:"created_at"`
 }
 
 type ContributionDay struct {
 	Date      time.Time `json:"date"`
 	Completed bool      `json:"completed"`
+	Skipped   bool      `json:"skipped"`
+	Scheduled bool      `json:"scheduled"`
+	Value     float64   `json:"value"`
+	Level     int       `json:"level"`
+	Editable  bool      `json:"editable"`
+	Note      string    `json:"note,omitempty"`
 }
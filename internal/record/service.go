@@ -1,23 +1,43 @@
 package record
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"strings"
 	"time"
 
 	"github.com/epalmerini/abitudini/internal/habit"
 )
 
-// StoreAdapter defines the interface for data access
+// StoreAdapter defines the interface for data access. Every method only
+// touches records of habits owned by userID.
 type StoreAdapter interface {
-	Record(habitID int, date time.Time) error
-	GetByHabitAndDateRange(habitID int, from, to time.Time) ([]Record, error)
+	Record(userID, habitID int, date time.Time, note string) error
+	Skip(userID, habitID int, date time.Time, note string) error
+	AddValue(userID, habitID int, date time.Time, amount float64) error
+	SetValue(userID, habitID int, date time.Time, value float64, note string) error
+	Delete(userID, habitID int, date time.Time) error
+	CountCompleted(userID, habitID int, from, to time.Time) (int, error)
+	GetByHabitAndDateRange(userID, habitID int, from, to time.Time) ([]Record, error)
+	GetNotes(userID, habitID int) ([]Record, error)
 }
 
 // HabitAdapter defines the interface for habit access
 type HabitAdapter interface {
-	GetByID(habitID int) (*habit.Habit, error)
+	GetByID(userID, habitID int) (*habit.Habit, error)
 }
 
+// ErrDateOutOfRange is returned when a day before the habit's start date
+// or in the future is marked or unmarked
+var ErrDateOutOfRange = errors.New("date must be between the habit's start date and today")
+
+// ErrNotScheduled is returned when a day the habit is not due is marked
+var ErrNotScheduled = errors.New("habit is not scheduled on this day")
+
+// maxNoteLength caps the length of a note, in characters
+const maxNoteLength = 500
+
 type Service struct {
 	store        StoreAdapter
 	habitService HabitAdapter
@@ -27,79 +47,327 @@ func NewService(store StoreAdapter, habitService HabitAdapter) *Service {
 	return &Service{store: store, habitService: habitService}
 }
 
-func (s *Service) MarkDoneToday(habitID int) error {
+// MarkDoneToday records today as completed if the habit is due today.
+// Quantitative habits are filled up to their daily target, as in MarkDate.
+func (s *Service) MarkDoneToday(userID, habitID int, note string) error {
+	return s.MarkDate(userID, habitID, time.Now(), note)
+}
+
+// SkipToday records today as an excused day if the habit is due today
+func (s *Service) SkipToday(userID, habitID int, note string) error {
+	today := time.Now()
+	if _, err := s.scheduledHabit(userID, habitID, today); err != nil {
+		return err
+	}
+	return s.store.Skip(userID, habitID, today, normalizeNote(note))
+}
+
+// AddAmountToday adds amount to today's value of a quantitative habit due today
+func (s *Service) AddAmountToday(userID, habitID int, amount float64) error {
+	if amount <= 0 {
+		return fmt.Errorf("amount must be positive")
+	}
+	today := time.Now()
+	if _, err := s.scheduledHabit(userID, habitID, today); err != nil {
+		return err
+	}
+	return s.store.AddValue(userID, habitID, today, amount)
+}
+
+// MarkDate records a completion for any scheduled day between the habit's
+// start date and today. Quantitative habits are filled up to their daily target.
+func (s *Service) MarkDate(userID, habitID int, date time.Time, note string) error {
+	h, err := s.scheduledHabit(userID, habitID, date)
+	if err != nil {
+		return err
+	}
+	if h.IsQuantitative() {
+		return s.store.SetValue(userID, habitID, date, h.DailyTarget, normalizeNote(note))
+	}
+	return s.store.Record(userID, habitID, date, normalizeNote(note))
+}
+
+// GetJournal returns the habit's notes, newest first
+func (s *Service) GetJournal(userID, habitID int) ([]Record, error) {
 	if s == nil || s.store == nil {
-		return fmt.Errorf("service not properly initialized")
+		return nil, fmt.Errorf("service not properly initialized")
+	}
+	return s.store.GetNotes(userID, habitID)
+}
+
+// normalizeNote trims whitespace and truncates overly long notes
+func normalizeNote(note string) string {
+	note = strings.TrimSpace(note)
+	if runes := []rune(note); len(runes) > maxNoteLength {
+		note = string(runes[:maxNoteLength])
+	}
+	return note
+}
+
+// UnmarkDate removes the record for any day between the habit's start date and today
+func (s *Service) UnmarkDate(userID, habitID int, date time.Time) error {
+	if _, err := s.editableHabit(userID, habitID, date); err != nil {
+		return err
+	}
+	return s.store.Delete(userID, habitID, date)
+}
+
+// editableHabit looks up the habit and checks that date may be changed
+func (s *Service) editableHabit(userID, habitID int, date time.Time) (*habit.Habit, error) {
+	if s == nil || s.store == nil || s.habitService == nil {
+		return nil, fmt.Errorf("service not properly initialized")
+	}
+
+	h, err := s.habitService.GetByID(userID, habitID)
+	if err != nil {
+		return nil, err
+	}
+	if h == nil {
+		return nil, ErrHabitNotFound
+	}
+
+	if !isEditable(h, date, time.Now()) {
+		return nil, ErrDateOutOfRange
+	}
+	return h, nil
+}
+
+// scheduledHabit is editableHabit for changes that record the habit as done
+// or excused, which only make sense on days the habit is due. Removing a
+// record stays possible on any day, so a schedule change never strands one.
+func (s *Service) scheduledHabit(userID, habitID int, date time.Time) (*habit.Habit, error) {
+	h, err := s.editableHabit(userID, habitID, date)
+	if err != nil {
+		return nil, err
 	}
-	return s.store.Record(habitID, time.Now())
+	if !h.IsScheduledOn(date) {
+		return nil, ErrNotScheduled
+	}
+	return h, nil
+}
+
+// isEditable reports whether date lies between the habit's start date and today
+func isEditable(h *habit.Habit, date, today time.Time) bool {
+	day := date.Format("2006-01-02")
+	if day > today.Format("2006-01-02") {
+		return false
+	}
+	return h == nil || h.StartDate.IsZero() || day >= h.StartDate.Format("2006-01-02")
 }
 
-func (s *Service) GetRecords(habitID int, from, to time.Time) ([]Record, error) {
+func (s *Service) GetRecords(userID, habitID int, from, to time.Time) ([]Record, error) {
 	if s == nil || s.store == nil {
 		return nil, fmt.Errorf("service not properly initialized")
 	}
-	return s.store.GetByHabitAndDateRange(habitID, from, to)
+	return s.store.GetByHabitAndDateRange(userID, habitID, from, to)
 }
 
-func (s *Service) GetContributionData(habitID int, from, to time.Time) ([]ContributionDay, error) {
-	records, err := s.GetRecords(habitID, from, to)
+// rollingWindow is the number of trailing days used to grade plain habits
+const rollingWindow = 7
+
+// GetContributionData returns one entry per day between from and to. Each
+// completed day is graded from 1 to 4: quantitative habits by the value
+// relative to the daily target, frequency habits by the progress toward the
+// period target, and plain habits by the completion ratio of the scheduled
+// days in the trailing week.
+func (s *Service) GetContributionData(userID, habitID int, from, to time.Time) ([]ContributionDay, error) {
+	// Without a habit lookup every day counts as scheduled
+	var h *habit.Habit
+	if s.habitService != nil {
+		var err error
+		h, err = s.habitService.GetByID(userID, habitID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Grading looks back before the first displayed day
+	fetchFrom := from.AddDate(0, 0, 1-rollingWindow)
+	if h != nil && h.HasFrequency() {
+		if start := h.TargetPeriod.Start(from); start.Before(fetchFrom) {
+			fetchFrom = start
+		}
+	}
+
+	records, err := s.GetRecords(userID, habitID, fetchFrom, to)
 	if err != nil {
 		return nil, err
 	}
 
-	// Create a map of completed dates
+	// Create maps of recorded values, notes, and completed and skipped days by date
+	valueMap := make(map[string]float64)
+	noteMap := make(map[string]string)
 	completedMap := make(map[string]bool)
+	skippedMap := make(map[string]bool)
 	for _, record := range records {
-		completedMap[record.RecordDate.Format("2006-01-02")] = true
+		date := record.RecordDate.Format("2006-01-02")
+		valueMap[date] = record.Value
+		noteMap[date] = record.Note
+		if record.Status == StatusSkipped {
+			skippedMap[date] = true
+			continue
+		}
+		completedMap[date] = h == nil || !h.IsQuantitative() || record.Value >= h.DailyTarget
 	}
 
 	// Generate all days in range
+	today := time.Now()
 	var contributions []ContributionDay
 	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
-		contributions = append(contributions, ContributionDay{
+		date := d.Format("2006-01-02")
+		scheduled := h == nil || h.IsScheduledOn(d)
+		// Off-schedule days can only be cleared of a record made before
+		// the schedule changed
+		_, recorded := valueMap[date]
+		day := ContributionDay{
 			Date:      d,
-			Completed: completedMap[d.Format("2006-01-02")],
-		})
+			Completed: completedMap[date],
+			Skipped:   skippedMap[date],
+			Scheduled: scheduled,
+			Editable:  isEditable(h, d, today) && (scheduled || recorded),
+			Note:      noteMap[date],
+		}
+
+		switch {
+		case h != nil && h.IsQuantitative():
+			day.Value = valueMap[date]
+			day.Level = intensityLevel(day.Value, h.DailyTarget)
+		case !day.Completed:
+			day.Level = 0
+		case h != nil && h.HasFrequency():
+			done := 0
+			for p := h.TargetPeriod.Start(d); !p.After(d); p = p.AddDate(0, 0, 1) {
+				if completedMap[p.Format("2006-01-02")] {
+					done++
+				}
+			}
+			day.Level = intensityLevel(float64(done), float64(h.TargetCount))
+		default:
+			done, due := 0, 0
+			for p := d.AddDate(0, 0, 1-rollingWindow); !p.After(d); p = p.AddDate(0, 0, 1) {
+				// Skipped days are neutral
+				if skippedMap[p.Format("2006-01-02")] {
+					continue
+				}
+				completed := completedMap[p.Format("2006-01-02")]
+				if completed {
+					done++
+				}
+				if completed || h == nil || h.IsScheduledOn(p) {
+					due++
+				}
+			}
+			day.Level = intensityLevel(float64(done), float64(due))
+		}
+
+		contributions = append(contributions, day)
 	}
 
 	return contributions, nil
 }
 
-func (s *Service) GetHabit(habitID int) (*habit.Habit, error) {
+func (s *Service) GetHabit(userID, habitID int) (*habit.Habit, error) {
 	if s == nil || s.habitService == nil {
 		return nil, fmt.Errorf("service not properly initialized")
 	}
 	
-	h, err := s.habitService.GetByID(habitID)
+	h, err := s.habitService.GetByID(userID, habitID)
 	if err != nil {
 		return nil, err
 	}
 	if h == nil {
-		return nil, fmt.Errorf("habit not found")
+		return nil, ErrHabitNotFound
 	}
 	
 	// Set CompletedToday flag
-	completed, err := s.IsCompletedToday(habitID)
+	completed, err := s.IsCompletedToday(userID, habitID)
 	if err != nil {
 		return nil, err
 	}
 	h.CompletedToday = completed
+
+	// Set SkippedToday flag
+	skipped, err := s.IsSkippedToday(userID, habitID)
+	if err != nil {
+		return nil, err
+	}
+	h.SkippedToday = skipped
+
+	// Set TodayValue for quantitative habits
+	if h.IsQuantitative() {
+		value, err := s.GetTodayValue(userID, habitID)
+		if err != nil {
+			return nil, err
+		}
+		h.TodayValue = value
+	}
+
+	// Set PeriodProgress for frequency targets
+	if h.HasFrequency() {
+		from, to := h.TargetPeriod.Bounds(time.Now())
+		count, err := s.CountCompletions(userID, habitID, from, to)
+		if err != nil {
+			return nil, err
+		}
+		h.PeriodProgress = count
+	}
 	
 	return h, nil
 }
 
-func (s *Service) IsCompletedToday(habitID int) (bool, error) {
+func (s *Service) IsCompletedToday(userID, habitID int) (bool, error) {
 	if s == nil || s.store == nil {
 		return false, fmt.Errorf("service not properly initialized")
 	}
-	
+
 	today := time.Now()
-	startOfDay := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, today.Location())
-	endOfDay := startOfDay.AddDate(0, 0, 1).Add(-time.Nanosecond)
-	
-	records, err := s.GetRecords(habitID, startOfDay, endOfDay)
+	count, err := s.store.CountCompleted(userID, habitID, today, today)
 	if err != nil {
 		return false, err
 	}
-	return len(records) > 0, nil
+	return count > 0, nil
+}
+
+// IsSkippedToday reports whether today was recorded as an excused day
+func (s *Service) IsSkippedToday(userID, habitID int) (bool, error) {
+	today := time.Now()
+	records, err := s.GetRecords(userID, habitID, today, today)
+	if err != nil {
+		return false, err
+	}
+	return len(records) > 0 && records[0].Status == StatusSkipped, nil
+}
+
+// GetTodayValue returns the amount recorded today, or 0 if nothing was recorded
+func (s *Service) GetTodayValue(userID, habitID int) (float64, error) {
+	today := time.Now()
+	records, err := s.GetRecords(userID, habitID, today, today)
+	if err != nil {
+		return 0, err
+	}
+	if len(records) == 0 {
+		return 0, nil
+	}
+	return records[0].Value, nil
+}
+
+// CountCompletions returns the number of completed days between from and to
+func (s *Service) CountCompletions(userID, habitID int, from, to time.Time) (int, error) {
+	if s == nil || s.store == nil {
+		return 0, fmt.Errorf("service not properly initialized")
+	}
+	return s.store.CountCompleted(userID, habitID, from, to)
+}
+
+// intensityLevel maps a value to a contribution level from 0 to 4
+// relative to the daily target
+func intensityLevel(value, target float64) int {
+	if value <= 0 || target <= 0 {
+		return 0
+	}
+	level := int(math.Ceil(value / target * 4))
+	if level > 4 {
+		return 4
+	}
+	return level
 }
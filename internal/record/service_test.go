@@ -2,10 +2,12 @@ package record
 
 import (
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/epalmerini/abitudini/internal/habit"
+	"github.com/epalmerini/abitudini/internal/testhelpers"
 )
 
 type mockRecordStore struct {
@@ -13,11 +15,41 @@ type mockRecordStore struct {
 	err     error
 }
 
-func (m *mockRecordStore) Record(habitID int, date time.Time) error {
+func (m *mockRecordStore) Record(userID, habitID int, date time.Time, note string) error {
 	return m.err
 }
 
-func (m *mockRecordStore) GetByHabitAndDateRange(habitID int, from, to time.Time) ([]Record, error) {
+func (m *mockRecordStore) AddValue(userID, habitID int, date time.Time, amount float64) error {
+	return m.err
+}
+
+func (m *mockRecordStore) Skip(userID, habitID int, date time.Time, note string) error {
+	return m.err
+}
+
+func (m *mockRecordStore) SetValue(userID, habitID int, date time.Time, value float64, note string) error {
+	return m.err
+}
+
+func (m *mockRecordStore) GetNotes(userID, habitID int) ([]Record, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.records, nil
+}
+
+func (m *mockRecordStore) Delete(userID, habitID int, date time.Time) error {
+	return m.err
+}
+
+func (m *mockRecordStore) CountCompleted(userID, habitID int, from, to time.Time) (int, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+	return len(m.records), nil
+}
+
+func (m *mockRecordStore) GetByHabitAndDateRange(userID, habitID int, from, to time.Time) ([]Record, error) {
 	if m.err != nil {
 		return nil, m.err
 	}
@@ -29,7 +61,7 @@ type mockHabitAdapter struct {
 	err   error
 }
 
-func (m *mockHabitAdapter) GetByID(habitID int) (*habit.Habit, error) {
+func (m *mockHabitAdapter) GetByID(userID, habitID int) (*habit.Habit, error) {
 	if m.err != nil {
 		return nil, m.err
 	}
@@ -38,10 +70,10 @@ func (m *mockHabitAdapter) GetByID(habitID int) (*habit.Habit, error) {
 
 func TestMarkDoneToday_Success(t *testing.T) {
 	store := &mockRecordStore{}
-	habitAdapter := &mockHabitAdapter{}
+	habitAdapter := &mockHabitAdapter{habit: &habit.Habit{ID: 1}}
 	s := NewService(store, habitAdapter)
 
-	err := s.MarkDoneToday(1)
+	err := s.MarkDoneToday(1, 1, "")
 	if err != nil {
 		t.Errorf("expected no error, got %v", err)
 	}
@@ -52,12 +84,167 @@ func TestMarkDoneToday_Error(t *testing.T) {
 	habitAdapter := &mockHabitAdapter{}
 	s := NewService(store, habitAdapter)
 
-	err := s.MarkDoneToday(1)
+	err := s.MarkDoneToday(1, 1, "")
 	if err == nil {
 		t.Error("expected error when recording fails")
 	}
 }
 
+func TestMarkDoneToday_Quantitative(t *testing.T) {
+	db := testhelpers.NewTestDB(t)
+	if _, err := db.Exec(`INSERT INTO habits (id, user_id, description, start_date, color, daily_target) VALUES (1, 1, 'Water', '2025-01-01', '', 8)`); err != nil {
+		t.Fatalf("failed to create habit: %v", err)
+	}
+	adapter := &mockHabitAdapter{habit: &habit.Habit{ID: 1, DailyTarget: 8}}
+	s := NewService(NewStore(db), adapter)
+
+	if err := s.MarkDoneToday(1, 1, "all of it"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// Marking done meets the target rather than storing a single unit
+	value, err := s.GetTodayValue(1, 1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if value != 8 {
+		t.Errorf("expected today's value to be the daily target 8, got %v", value)
+	}
+	if completed, _ := s.IsCompletedToday(1, 1); !completed {
+		t.Error("expected the habit to count as completed today")
+	}
+}
+
+func TestAddAmountToday_Success(t *testing.T) {
+	s := NewService(&mockRecordStore{}, &mockHabitAdapter{habit: &habit.Habit{ID: 1, DailyTarget: 8}})
+
+	if err := s.AddAmountToday(1, 1, 2.5); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestAddAmountToday_NonPositive(t *testing.T) {
+	s := NewService(&mockRecordStore{}, nil)
+
+	if err := s.AddAmountToday(1, 1, 0); err == nil {
+		t.Error("expected error for zero amount")
+	}
+}
+
+func TestMarkDate_WithinRange(t *testing.T) {
+	start := time.Now().AddDate(0, 0, -10)
+	adapter := &mockHabitAdapter{habit: &habit.Habit{ID: 1, StartDate: start}}
+	s := NewService(&mockRecordStore{}, adapter)
+
+	if err := s.MarkDate(1, 1, time.Now().AddDate(0, 0, -3), ""); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if err := s.UnmarkDate(1, 1, start); err != nil {
+		t.Errorf("expected start date to be editable, got %v", err)
+	}
+}
+
+func TestMarkDate_OutOfRange(t *testing.T) {
+	start := time.Now().AddDate(0, 0, -10)
+	adapter := &mockHabitAdapter{habit: &habit.Habit{ID: 1, StartDate: start}}
+	s := NewService(&mockRecordStore{}, adapter)
+
+	if err := s.MarkDate(1, 1, start.AddDate(0, 0, -1), ""); !errors.Is(err, ErrDateOutOfRange) {
+		t.Errorf("expected ErrDateOutOfRange before start date, got %v", err)
+	}
+	if err := s.UnmarkDate(1, 1, time.Now().AddDate(0, 0, 1)); !errors.Is(err, ErrDateOutOfRange) {
+		t.Errorf("expected ErrDateOutOfRange for future date, got %v", err)
+	}
+}
+
+func TestMarkDate_NotScheduled(t *testing.T) {
+	today := time.Now()
+	tomorrow := today.AddDate(0, 0, 1).Weekday()
+	adapter := &mockHabitAdapter{habit: &habit.Habit{ID: 1, Days: []time.Weekday{tomorrow}}}
+	s := NewService(&mockRecordStore{}, adapter)
+
+	if err := s.MarkDoneToday(1, 1, ""); !errors.Is(err, ErrNotScheduled) {
+		t.Errorf("expected ErrNotScheduled marking today done, got %v", err)
+	}
+	if err := s.SkipToday(1, 1, ""); !errors.Is(err, ErrNotScheduled) {
+		t.Errorf("expected ErrNotScheduled skipping today, got %v", err)
+	}
+	if err := s.MarkDate(1, 1, today, ""); !errors.Is(err, ErrNotScheduled) {
+		t.Errorf("expected ErrNotScheduled marking an off-schedule day, got %v", err)
+	}
+	if err := s.MarkDate(1, 1, today.AddDate(0, 0, -6), ""); err != nil {
+		t.Errorf("expected a scheduled day to be marked, got %v", err)
+	}
+	// A record left from before a schedule change can still be removed
+	if err := s.UnmarkDate(1, 1, today); err != nil {
+		t.Errorf("expected an off-schedule day to be unmarked, got %v", err)
+	}
+
+	adapter.habit.DailyTarget = 8
+	if err := s.AddAmountToday(1, 1, 2); !errors.Is(err, ErrNotScheduled) {
+		t.Errorf("expected ErrNotScheduled adding an amount today, got %v", err)
+	}
+}
+
+func TestNormalizeNote(t *testing.T) {
+	if note := normalizeNote("  skipped dessert  "); note != "skipped dessert" {
+		t.Errorf("expected trimmed note, got %q", note)
+	}
+	if note := normalizeNote(strings.Repeat("é", maxNoteLength+10)); len([]rune(note)) != maxNoteLength {
+		t.Errorf("expected note truncated to %d characters, got %d", maxNoteLength, len([]rune(note)))
+	}
+}
+
+func TestSkipToday(t *testing.T) {
+	adapter := &mockHabitAdapter{habit: &habit.Habit{ID: 1}}
+	s := NewService(&mockRecordStore{}, adapter)
+	if err := s.SkipToday(1, 1, "sick"); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	s = NewService(&mockRecordStore{err: errors.New("skip failed")}, adapter)
+	if err := s.SkipToday(1, 1, ""); err == nil {
+		t.Error("expected error when skip fails")
+	}
+}
+
+func TestIsSkippedToday(t *testing.T) {
+	records := []Record{{HabitID: 1, RecordDate: time.Now(), Status: StatusSkipped}}
+	s := NewService(&mockRecordStore{records: records}, nil)
+
+	skipped, err := s.IsSkippedToday(1, 1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !skipped {
+		t.Error("expected today to be skipped")
+	}
+}
+
+func TestGetContributionData_SkippedDays(t *testing.T) {
+	monday := time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC)
+	records := []Record{
+		{HabitID: 1, RecordDate: monday, Status: StatusDone},
+		{HabitID: 1, RecordDate: monday.AddDate(0, 0, 1), Status: StatusSkipped},
+		{HabitID: 1, RecordDate: monday.AddDate(0, 0, 2), Status: StatusDone},
+	}
+	adapter := &mockHabitAdapter{habit: &habit.Habit{ID: 1}}
+	s := NewService(&mockRecordStore{records: records}, adapter)
+
+	contributions, err := s.GetContributionData(1, 1, monday, monday.AddDate(0, 0, 2))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if !contributions[1].Skipped || contributions[1].Completed {
+		t.Errorf("expected skipped day to be neither completed nor unskipped: %+v", contributions[1])
+	}
+	// Two of two non-excused days are done, ignoring the previous week
+	if contributions[2].Level != 2 {
+		t.Errorf("expected level 2, got %d", contributions[2].Level)
+	}
+}
+
 func TestGetRecords_Success(t *testing.T) {
 	now := time.Now()
 	records := []Record{
@@ -67,7 +254,7 @@ func TestGetRecords_Success(t *testing.T) {
 	store := &mockRecordStore{records: records}
 	s := NewService(store, nil)
 
-	result, err := s.GetRecords(1, now.AddDate(0, 0, -7), now)
+	result, err := s.GetRecords(1, 1, now.AddDate(0, 0, -7), now)
 	if err != nil {
 		t.Errorf("expected no error, got %v", err)
 	}
@@ -80,7 +267,7 @@ func TestGetRecords_Error(t *testing.T) {
 	store := &mockRecordStore{err: errors.New("fetch failed")}
 	s := NewService(store, nil)
 
-	_, err := s.GetRecords(1, time.Now().AddDate(0, 0, -7), time.Now())
+	_, err := s.GetRecords(1, 1, time.Now().AddDate(0, 0, -7), time.Now())
 	if err == nil {
 		t.Error("expected error when fetch fails")
 	}
@@ -99,7 +286,7 @@ func TestGetContributionData_Success(t *testing.T) {
 	s := NewService(store, nil)
 
 	from := today.AddDate(0, 0, -3)
-	contributions, err := s.GetContributionData(1, from, today)
+	contributions, err := s.GetContributionData(1, 1, from, today)
 	if err != nil {
 		t.Errorf("expected no error, got %v", err)
 	}
@@ -128,7 +315,7 @@ func TestGetContributionData_NoRecords(t *testing.T) {
 	s := NewService(store, nil)
 
 	from := today.AddDate(0, 0, -3)
-	contributions, err := s.GetContributionData(1, from, today)
+	contributions, err := s.GetContributionData(1, 1, from, today)
 	if err != nil {
 		t.Errorf("expected no error, got %v", err)
 	}
@@ -146,11 +333,140 @@ func TestGetContributionData_NoRecords(t *testing.T) {
 	}
 }
 
+func TestGetContributionData_Schedule(t *testing.T) {
+	monday := time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC)
+	adapter := &mockHabitAdapter{habit: &habit.Habit{ID: 1, Days: []time.Weekday{time.Monday}}}
+	s := NewService(&mockRecordStore{}, adapter)
+
+	contributions, err := s.GetContributionData(1, 1, monday, monday.AddDate(0, 0, 6))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	for _, c := range contributions {
+		if expected := c.Date.Weekday() == time.Monday; c.Scheduled != expected {
+			t.Errorf("%s: expected scheduled=%v, got %v", c.Date.Format("2006-01-02"), expected, c.Scheduled)
+		}
+		if expected := c.Date.Weekday() == time.Monday; c.Editable != expected {
+			t.Errorf("%s: expected editable=%v, got %v", c.Date.Format("2006-01-02"), expected, c.Editable)
+		}
+	}
+
+	// An off-schedule day with a record stays editable so it can be cleared
+	tuesday := monday.AddDate(0, 0, 1)
+	s = NewService(&mockRecordStore{records: []Record{{HabitID: 1, RecordDate: tuesday, Value: 1}}}, adapter)
+	contributions, err = s.GetContributionData(1, 1, tuesday, tuesday)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(contributions) != 1 || !contributions[0].Editable {
+		t.Errorf("expected a recorded off-schedule day to be editable, got %+v", contributions)
+	}
+}
+
+func TestGetContributionData_Quantitative(t *testing.T) {
+	day := time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC)
+	records := []Record{
+		{HabitID: 1, RecordDate: day, Value: 2},
+		{HabitID: 1, RecordDate: day.AddDate(0, 0, 1), Value: 8},
+	}
+	adapter := &mockHabitAdapter{habit: &habit.Habit{ID: 1, DailyTarget: 8}}
+	s := NewService(&mockRecordStore{records: records}, adapter)
+
+	contributions, err := s.GetContributionData(1, 1, day, day.AddDate(0, 0, 2))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if contributions[0].Completed || contributions[0].Level != 1 {
+		t.Errorf("expected partial day at level 1, got completed=%v level=%d", contributions[0].Completed, contributions[0].Level)
+	}
+	if !contributions[1].Completed || contributions[1].Level != 4 {
+		t.Errorf("expected completed day at level 4, got completed=%v level=%d", contributions[1].Completed, contributions[1].Level)
+	}
+	if contributions[2].Level != 0 {
+		t.Errorf("expected empty day at level 0, got %d", contributions[2].Level)
+	}
+}
+
+func TestGetContributionData_RollingLevels(t *testing.T) {
+	monday := time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC)
+	var records []Record
+	for i := 0; i < 7; i++ {
+		records = append(records, Record{HabitID: 1, RecordDate: monday.AddDate(0, 0, i)})
+	}
+	// An isolated completion two weeks later
+	isolated := monday.AddDate(0, 0, 20)
+	records = append(records, Record{HabitID: 1, RecordDate: isolated})
+
+	adapter := &mockHabitAdapter{habit: &habit.Habit{ID: 1}}
+	s := NewService(&mockRecordStore{records: records}, adapter)
+
+	contributions, err := s.GetContributionData(1, 1, monday, isolated)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if level := contributions[0].Level; level != 1 {
+		t.Errorf("expected first day of a run at level 1, got %d", level)
+	}
+	if level := contributions[6].Level; level != 4 {
+		t.Errorf("expected a full week at level 4, got %d", level)
+	}
+	if level := contributions[len(contributions)-1].Level; level != 1 {
+		t.Errorf("expected isolated completion at level 1, got %d", level)
+	}
+}
+
+func TestGetContributionData_FrequencyLevels(t *testing.T) {
+	monday := time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC)
+	records := []Record{
+		{HabitID: 1, RecordDate: monday},
+		{HabitID: 1, RecordDate: monday.AddDate(0, 0, 2)},
+	}
+	h := &habit.Habit{ID: 1, TargetCount: 2, TargetPeriod: habit.PeriodWeek}
+	s := NewService(&mockRecordStore{records: records}, &mockHabitAdapter{habit: h})
+
+	contributions, err := s.GetContributionData(1, 1, monday, monday.AddDate(0, 0, 6))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if level := contributions[0].Level; level != 2 {
+		t.Errorf("expected half the target at level 2, got %d", level)
+	}
+	if level := contributions[2].Level; level != 4 {
+		t.Errorf("expected target reached at level 4, got %d", level)
+	}
+	if level := contributions[1].Level; level != 0 {
+		t.Errorf("expected missed day at level 0, got %d", level)
+	}
+}
+
+func TestIntensityLevel(t *testing.T) {
+	cases := []struct {
+		value, target float64
+		expected      int
+	}{
+		{0, 8, 0},
+		{1, 8, 1},
+		{4, 8, 2},
+		{6, 8, 3},
+		{8, 8, 4},
+		{12, 8, 4},
+	}
+	for _, c := range cases {
+		if level := intensityLevel(c.value, c.target); level != c.expected {
+			t.Errorf("intensityLevel(%v, %v) = %d, expected %d", c.value, c.target, level, c.expected)
+		}
+	}
+}
+
 func TestGetContributionData_Error(t *testing.T) {
 	store := &mockRecordStore{err: errors.New("fetch failed")}
 	s := NewService(store, nil)
 
-	_, err := s.GetContributionData(1, time.Now().AddDate(0, 0, -7), time.Now())
+	_, err := s.GetContributionData(1, 1, time.Now().AddDate(0, 0, -7), time.Now())
 	if err == nil {
 		t.Error("expected error when fetch fails")
 	}
@@ -162,7 +478,7 @@ func TestGetHabit_Success(t *testing.T) {
 	store := &mockRecordStore{records: []Record{}}
 	s := NewService(store, adapter)
 
-	h, err := s.GetHabit(1)
+	h, err := s.GetHabit(1, 1)
 	if err != nil {
 		t.Errorf("expected no error, got %v", err)
 	}
@@ -175,7 +491,7 @@ func TestGetHabit_Error(t *testing.T) {
 	adapter := &mockHabitAdapter{err: errors.New("habit not found")}
 	s := NewService(nil, adapter)
 
-	_, err := s.GetHabit(1)
+	_, err := s.GetHabit(1, 1)
 	if err == nil {
 		t.Error("expected error when habit not found")
 	}
@@ -191,7 +507,7 @@ func TestIsCompletedToday_Completed(t *testing.T) {
 	store := &mockRecordStore{records: records}
 	s := NewService(store, nil)
 
-	completed, err := s.IsCompletedToday(1)
+	completed, err := s.IsCompletedToday(1, 1)
 	if err != nil {
 		t.Errorf("expected no error, got %v", err)
 	}
@@ -204,7 +520,7 @@ func TestIsCompletedToday_NotCompleted(t *testing.T) {
 	store := &mockRecordStore{records: []Record{}}
 	s := NewService(store, nil)
 
-	completed, err := s.IsCompletedToday(1)
+	completed, err := s.IsCompletedToday(1, 1)
 	if err != nil {
 		t.Errorf("expected no error, got %v", err)
 	}
@@ -217,7 +533,7 @@ func TestIsCompletedToday_Error(t *testing.T) {
 	store := &mockRecordStore{err: errors.New("fetch failed")}
 	s := NewService(store, nil)
 
-	_, err := s.IsCompletedToday(1)
+	_, err := s.IsCompletedToday(1, 1)
 	if err == nil {
 		t.Error("expected error when fetch fails")
 	}
@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"fmt"
 	"time"
+
+	"github.com/epalmerini/abitudini/internal/habit"
 )
 
 type Store struct {
@@ -14,45 +16,186 @@ func NewStore(db *sql.DB) *Store {
 	return &Store{db: db}
 }
 
-func (s *Store) Record(habitID int, date time.Time) error {
+// ErrHabitNotFound is returned when recording a day for a habit the user
+// does not own. It is the habit slice's error, so a failed habit lookup
+// matches it too.
+var ErrHabitNotFound = habit.ErrHabitNotFound
+
+// ownedHabit restricts a statement to records of a habit owned by the user.
+// It takes the habit ID and the user ID.
+const ownedHabit = `EXISTS (SELECT 1 FROM habits WHERE id = ? AND user_id = ?)`
+
+func (s *Store) Record(userID, habitID int, date time.Time, note string) error {
 	dateStr := date.Format("2006-01-02")
-	_, err := s.db.Exec(
-		`INSERT OR REPLACE INTO records (habit_id, record_date, completed_at)
-		 VALUES (?, ?, CURRENT_TIMESTAMP)`,
+	result, err := s.db.Exec(
+		`INSERT OR REPLACE INTO records (habit_id, record_date, completed_at, note, status)
+		 SELECT ?, ?, CURRENT_TIMESTAMP, ?, ? WHERE `+ownedHabit,
 		habitID,
 		dateStr,
+		note,
+		string(StatusDone),
+		habitID, userID,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to record completion: %w", err)
 	}
+	return requireRow(result)
+}
+
+// Skip records an excused day, replacing any completion for that day
+func (s *Store) Skip(userID, habitID int, date time.Time, note string) error {
+	result, err := s.db.Exec(
+		`INSERT OR REPLACE INTO records (habit_id, record_date, completed_at, value, note, status)
+		 SELECT ?, ?, CURRENT_TIMESTAMP, 0, ?, ? WHERE `+ownedHabit,
+		habitID,
+		date.Format("2006-01-02"),
+		note,
+		string(StatusSkipped),
+		habitID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record skip: %w", err)
+	}
+	return requireRow(result)
+}
+
+// AddValue adds amount to the recorded value for a day, creating the record
+// if the day has none yet
+func (s *Store) AddValue(userID, habitID int, date time.Time, amount float64) error {
+	result, err := s.db.Exec(
+		`INSERT INTO records (habit_id, record_date, completed_at, value)
+		 SELECT ?, ?, CURRENT_TIMESTAMP, ? WHERE `+ownedHabit+`
+		 ON CONFLICT(habit_id, record_date)
+		 DO UPDATE SET value = value + excluded.value, status = 'done', completed_at = excluded.completed_at`,
+		habitID,
+		date.Format("2006-01-02"),
+		amount,
+		habitID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add value: %w", err)
+	}
+	return requireRow(result)
+}
+
+// SetValue records a day with the given value and note, replacing any previous ones
+func (s *Store) SetValue(userID, habitID int, date time.Time, value float64, note string) error {
+	result, err := s.db.Exec(
+		`INSERT INTO records (habit_id, record_date, completed_at, value, note)
+		 SELECT ?, ?, CURRENT_TIMESTAMP, ?, ? WHERE `+ownedHabit+`
+		 ON CONFLICT(habit_id, record_date)
+		 DO UPDATE SET value = excluded.value, note = excluded.note, status = 'done',
+		               completed_at = excluded.completed_at`,
+		habitID,
+		date.Format("2006-01-02"),
+		value,
+		note,
+		habitID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set value: %w", err)
+	}
+	return requireRow(result)
+}
+
+// requireRow turns a write that matched no owned habit into ErrHabitNotFound
+func requireRow(result sql.Result) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check written record: %w", err)
+	}
+	if rows == 0 {
+		return ErrHabitNotFound
+	}
 	return nil
 }
 
-func (s *Store) GetByHabitAndDateRange(habitID int, from, to time.Time) ([]Record, error) {
+// Delete removes the record for a day, if any
+func (s *Store) Delete(userID, habitID int, date time.Time) error {
+	_, err := s.db.Exec(
+		`DELETE FROM records WHERE habit_id = ? AND record_date = ? AND `+ownedHabit,
+		habitID,
+		date.Format("2006-01-02"),
+		habitID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete record: %w", err)
+	}
+	return nil
+}
+
+// CountCompleted counts the days in range whose record meets the habit's
+// daily target. Habits without a target complete on any record.
+func (s *Store) CountCompleted(userID, habitID int, from, to time.Time) (int, error) {
+	var count int
+	err := s.db.QueryRow(
+		`SELECT COUNT(*)
+		 FROM records r JOIN habits h ON h.id = r.habit_id
+		 WHERE r.habit_id = ? AND h.user_id = ? AND r.record_date BETWEEN ? AND ?
+		   AND r.status = 'done'
+		   AND (h.daily_target <= 0 OR r.value >= h.daily_target)`,
+		habitID,
+		userID,
+		from.Format("2006-01-02"),
+		to.Format("2006-01-02"),
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count records: %w", err)
+	}
+	return count, nil
+}
+
+// GetNotes returns the habit's records that carry a note, newest first
+func (s *Store) GetNotes(userID, habitID int) ([]Record, error) {
 	rows, err := s.db.Query(
-		`SELECT id, habit_id, record_date, completed_at, created_at 
+		`SELECT id, habit_id, record_date, completed_at, value, note, status, created_at 
 		 FROM records 
-		 WHERE habit_id = ? AND record_date BETWEEN ? AND ?
+		 WHERE habit_id = ? AND note != '' AND `+ownedHabit+`
+		 ORDER BY record_date DESC`,
+		habitID,
+		habitID, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notes: %w", err)
+	}
+	defer rows.Close()
+
+	return scanRecords(rows)
+}
+
+func (s *Store) GetByHabitAndDateRange(userID, habitID int, from, to time.Time) ([]Record, error) {
+	rows, err := s.db.Query(
+		`SELECT id, habit_id, record_date, completed_at, value, note, status, created_at 
+		 FROM records 
+		 WHERE habit_id = ? AND record_date BETWEEN ? AND ? AND `+ownedHabit+`
 		 ORDER BY record_date DESC`,
 		habitID,
 		from.Format("2006-01-02"),
 		to.Format("2006-01-02"),
+		habitID, userID,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get records: %w", err)
 	}
 	defer rows.Close()
 
+	return scanRecords(rows)
+}
+
+// scanRecords reads rows selected as id, habit_id, record_date,
+// completed_at, value, note, status, created_at
+func scanRecords(rows *sql.Rows) ([]Record, error) {
 	var records []Record
 	for rows.Next() {
 		r := Record{}
-		var recordDate, completedAt, createdAt string
+		var recordDate, completedAt, status, createdAt string
 
-		if err := rows.Scan(&r.ID, &r.HabitID, &recordDate, &completedAt, &createdAt); err != nil {
+		if err := rows.Scan(&r.ID, &r.HabitID, &recordDate, &completedAt, &r.Value, &r.Note, &status, &createdAt); err != nil {
 			return nil, fmt.Errorf("failed to scan record: %w", err)
 		}
 
 		r.RecordDate, _ = time.Parse("2006-01-02", recordDate)
+		r.Status = Status(status)
 		r.CompletedAt, _ = time.Parse("2006-01-02 15:04:05", completedAt)
 		r.CreatedAt, _ = time.Parse("2006-01-02 15:04:05", createdAt)
 
@@ -1,21 +1,185 @@
 package record
 
 import (
+	"database/sql"
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/epalmerini/abitudini/internal/testhelpers"
 )
 
+// createHabit adds habit 1, owned by user 1, which records must reference
+func createHabit(t *testing.T, db *sql.DB) {
+	t.Helper()
+	if _, err := db.Exec(`INSERT INTO habits (id, user_id, description, start_date, color) VALUES (1, 1, 'Read', '2025-01-01', '')`); err != nil {
+		t.Fatalf("failed to create habit: %v", err)
+	}
+}
+
 func TestRecordStore_Record(t *testing.T) {
 	db := testhelpers.NewTestDB(t)
 	store := NewStore(db)
+	createHabit(t, db)
 
 	habitID := 1
 	date := time.Now()
 
-	err := store.Record(habitID, date)
+	err := store.Record(1, habitID, date, "")
+	if err != nil {
+		t.Fatalf("failed to record: %v", err)
+	}
+}
+
+func TestRecordStore_AddValueAndCountCompleted(t *testing.T) {
+	db := testhelpers.NewTestDB(t)
+	store := NewStore(db)
+
+	result, err := db.Exec(`INSERT INTO habits (user_id, description, start_date, color, daily_target) VALUES (1, 'Water', '2025-01-01', '', 8)`)
+	if err != nil {
+		t.Fatalf("failed to create habit: %v", err)
+	}
+	id, _ := result.LastInsertId()
+	habitID := int(id)
+	date := time.Now()
+
+	for _, amount := range []float64{3, 4} {
+		if err := store.AddValue(1, habitID, date, amount); err != nil {
+			t.Fatalf("failed to add value: %v", err)
+		}
+	}
+
+	records, err := store.GetByHabitAndDateRange(1, habitID, date, date)
+	if err != nil {
+		t.Fatalf("failed to get records: %v", err)
+	}
+	if len(records) != 1 || records[0].Value != 7 {
+		t.Fatalf("expected a single record with value 7, got %+v", records)
+	}
+
+	count, err := store.CountCompleted(1, habitID, date, date)
+	if err != nil {
+		t.Fatalf("failed to count: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("expected day below target to be incomplete, got %d", count)
+	}
+
+	if err := store.AddValue(1, habitID, date, 1); err != nil {
+		t.Fatalf("failed to add value: %v", err)
+	}
+	count, _ = store.CountCompleted(1, habitID, date, date)
+	if count != 1 {
+		t.Errorf("expected day at target to be complete, got %d", count)
+	}
+}
+
+func TestRecordStore_Delete(t *testing.T) {
+	db := testhelpers.NewTestDB(t)
+	store := NewStore(db)
+	createHabit(t, db)
+
+	date := time.Now()
+	if err := store.Record(1, 1, date, ""); err != nil {
+		t.Fatalf("failed to record: %v", err)
+	}
+	if err := store.Delete(1, 1, date); err != nil {
+		t.Fatalf("failed to delete: %v", err)
+	}
+
+	records, err := store.GetByHabitAndDateRange(1, 1, date, date)
+	if err != nil {
+		t.Fatalf("failed to get records: %v", err)
+	}
+	if len(records) != 0 {
+		t.Errorf("expected no records after delete, got %d", len(records))
+	}
+}
+
+func TestRecordStore_GetNotes(t *testing.T) {
+	db := testhelpers.NewTestDB(t)
+	store := NewStore(db)
+	createHabit(t, db)
+
+	today := time.Now()
+	if err := store.Record(1, 1, today.AddDate(0, 0, -2), "older"); err != nil {
+		t.Fatalf("failed to record: %v", err)
+	}
+	if err := store.Record(1, 1, today.AddDate(0, 0, -1), ""); err != nil {
+		t.Fatalf("failed to record: %v", err)
+	}
+	if err := store.Record(1, 1, today, "newer"); err != nil {
+		t.Fatalf("failed to record: %v", err)
+	}
+
+	notes, err := store.GetNotes(1, 1)
+	if err != nil {
+		t.Fatalf("failed to get notes: %v", err)
+	}
+	if len(notes) != 2 {
+		t.Fatalf("expected 2 notes, got %d", len(notes))
+	}
+	if notes[0].Note != "newer" || notes[1].Note != "older" {
+		t.Errorf("expected notes newest first, got %q then %q", notes[0].Note, notes[1].Note)
+	}
+}
+
+func TestRecordStore_SkipIsNotCompleted(t *testing.T) {
+	db := testhelpers.NewTestDB(t)
+	store := NewStore(db)
+
+	if _, err := db.Exec(`INSERT INTO habits (id, user_id, description, start_date, color) VALUES (1, 1, 'Run', '2025-01-01', '')`); err != nil {
+		t.Fatalf("failed to create habit: %v", err)
+	}
+
+	date := time.Now()
+	if err := store.Skip(1, 1, date, "sick"); err != nil {
+		t.Fatalf("failed to skip: %v", err)
+	}
+
+	count, err := store.CountCompleted(1, 1, date, date)
 	if err != nil {
+		t.Fatalf("failed to count: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("expected skipped day not to count as completed, got %d", count)
+	}
+
+	records, _ := store.GetByHabitAndDateRange(1, 1, date, date)
+	if len(records) != 1 || records[0].Status != StatusSkipped {
+		t.Errorf("expected a skipped record, got %+v", records)
+	}
+}
+
+func TestRecordStore_ScopedToOwner(t *testing.T) {
+	db := testhelpers.NewTestDB(t)
+	store := NewStore(db)
+	createHabit(t, db)
+
+	date := time.Now()
+	if err := store.Record(1, 1, date, "mine"); err != nil {
 		t.Fatalf("failed to record: %v", err)
 	}
+
+	// Another user can neither write nor read the habit's records
+	if err := store.Record(2, 1, date, ""); !errors.Is(err, ErrHabitNotFound) {
+		t.Errorf("expected ErrHabitNotFound, got %v", err)
+	}
+	if err := store.AddValue(2, 1, date, 1); !errors.Is(err, ErrHabitNotFound) {
+		t.Errorf("expected ErrHabitNotFound, got %v", err)
+	}
+	if records, _ := store.GetByHabitAndDateRange(2, 1, date, date); len(records) != 0 {
+		t.Errorf("expected no records for another user, got %+v", records)
+	}
+	if count, _ := store.CountCompleted(2, 1, date, date); count != 0 {
+		t.Errorf("expected no completions for another user, got %d", count)
+	}
+	if err := store.Delete(2, 1, date); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	records, _ := store.GetByHabitAndDateRange(1, 1, date, date)
+	if len(records) != 1 || records[0].Note != "mine" {
+		t.Errorf("expected the owner's record to be untouched, got %+v", records)
+	}
 }
@@ -0,0 +1,19 @@
+package shared
+
+import "context"
+
+// csrfKey is the context key holding the request's CSRF token
+type csrfKey struct{}
+
+// WithCSRFToken returns a copy of ctx carrying the CSRF token pages must
+// send back on state-changing requests
+func WithCSRFToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, csrfKey{}, token)
+}
+
+// CSRFToken returns the CSRF token the CSRF middleware issued for the
+// request, or "" outside of it
+func CSRFToken(ctx context.Context) string {
+	token, _ := ctx.Value(csrfKey{}).(string)
+	return token
+}
@@ -1,10 +1,25 @@
 package shared
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
+// maxJSONBody caps the size of JSON request bodies
+const maxJSONBody = 1 << 20
+
+// ErrorResponse is the body of every JSON error
+type ErrorResponse struct {
+	Error  string `json:"error"`
+	Status int    `json:"status"`
+}
+
 type BaseHandler struct{}
 
 // WriteHTML writes HTML response with proper content-type header
@@ -18,6 +33,93 @@ func (h *BaseHandler) WriteError(w http.ResponseWriter, message string, status i
 	http.Error(w, message, status)
 }
 
+// WriteJSON writes v as a JSON response with the given status
+func (h *BaseHandler) WriteJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// RespondError writes an error as JSON for clients that asked for it and as
+// plain text otherwise
+func (h *BaseHandler) RespondError(w http.ResponseWriter, r *http.Request, message string, status int) {
+	if h.WantsJSON(r) {
+		h.WriteJSON(w, status, ErrorResponse{Error: message, Status: status})
+		return
+	}
+	h.WriteError(w, message, status)
+}
+
+// WantsJSON reports whether the request's Accept header asks for JSON
+func (h *BaseHandler) WantsJSON(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || mediaType != "application/json" {
+			continue
+		}
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil && q == 0 {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// ParseInput parses the request body into r.Form. JSON object bodies are
+// accepted alongside form encoding: scalar fields become single form values
+// and arrays become repeated ones, so handlers read both through FormValue.
+func (h *BaseHandler) ParseInput(r *http.Request) error {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		return r.ParseForm()
+	}
+
+	var body map[string]any
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxJSONBody)).Decode(&body); err != nil {
+		return fmt.Errorf("failed to decode JSON body: %w", err)
+	}
+
+	form := r.URL.Query()
+	for key, value := range body {
+		values, err := formValues(value)
+		if err != nil {
+			return fmt.Errorf("invalid field %q: %w", key, err)
+		}
+		form[key] = append(values, form[key]...)
+	}
+	r.Form = form
+	r.PostForm = form
+	return nil
+}
+
+// formValues converts a decoded JSON value into form values
+func formValues(value any) ([]string, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case float64:
+		return []string{strconv.FormatFloat(v, 'f', -1, 64)}, nil
+	case bool:
+		return []string{strconv.FormatBool(v)}, nil
+	case []any:
+		var values []string
+		for _, item := range v {
+			if _, nested := item.([]any); nested {
+				return nil, errors.New("nested arrays are not supported")
+			}
+			itemValues, err := formValues(item)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, itemValues...)
+		}
+		return values, nil
+	}
+	return nil, errors.New("objects are not supported")
+}
+
 // ExtractIntPathParam extracts and converts a path parameter to integer
 func (h *BaseHandler) ExtractIntPathParam(r *http.Request, paramName string) (int, error) {
 	return strconv.Atoi(r.PathValue(paramName))
@@ -26,7 +128,7 @@ func (h *BaseHandler) ExtractIntPathParam(r *http.Request, paramName string) (in
 // ValidateMethod checks if request method matches expected method
 func (h *BaseHandler) ValidateMethod(w http.ResponseWriter, r *http.Request, expectedMethod string) bool {
 	if r.Method != expectedMethod {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		h.RespondError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
 		return false
 	}
 	return true
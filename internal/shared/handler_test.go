@@ -163,3 +163,116 @@ func TestValidateMethod_AllMethods(t *testing.T) {
 		}
 	}
 }
+
+func TestWantsJSON(t *testing.T) {
+	h := &BaseHandler{}
+	cases := map[string]bool{
+		"":                                  false,
+		"*/*":                               false,
+		"text/html":                         false,
+		"application/json":                  true,
+		"text/html, application/json;q=0.9": true,
+		"application/json;q=0":              false,
+	}
+
+	for accept, want := range cases {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Accept", accept)
+		if got := h.WantsJSON(req); got != want {
+			t.Errorf("WantsJSON(%q) = %v, want %v", accept, got, want)
+		}
+	}
+}
+
+func TestRespondError_JSON(t *testing.T) {
+	h := &BaseHandler{}
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	h.RespondError(w, req, "not found", http.StatusNotFound)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected JSON content type, got %s", ct)
+	}
+	if strings.TrimSpace(w.Body.String()) != `{"error":"not found","status":404}` {
+		t.Errorf("unexpected error body %s", w.Body.String())
+	}
+}
+
+func TestRespondError_PlainText(t *testing.T) {
+	h := &BaseHandler{}
+	req := httptest.NewRequest("GET", "/test", nil)
+	w := httptest.NewRecorder()
+
+	h.RespondError(w, req, "not found", http.StatusNotFound)
+
+	if strings.Contains(w.Header().Get("Content-Type"), "json") {
+		t.Error("expected plain text error without JSON Accept header")
+	}
+}
+
+func TestParseInput_JSON(t *testing.T) {
+	h := &BaseHandler{}
+	body := `{"description": "Read", "target": 2.5, "days": [1, 3], "done": true, "note": null}`
+	req := httptest.NewRequest("POST", "/test?color=red", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	if err := h.ParseInput(req); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if req.FormValue("description") != "Read" || req.FormValue("target") != "2.5" || req.FormValue("done") != "true" {
+		t.Errorf("unexpected form %v", req.Form)
+	}
+	if days := req.Form["days"]; len(days) != 2 || days[0] != "1" || days[1] != "3" {
+		t.Errorf("expected days [1 3], got %v", days)
+	}
+	if req.FormValue("color") != "red" {
+		t.Error("expected query parameters to be kept")
+	}
+}
+
+func TestParseInput_Form(t *testing.T) {
+	h := &BaseHandler{}
+	req := httptest.NewRequest("POST", "/test", strings.NewReader("note=hello"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if err := h.ParseInput(req); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if req.FormValue("note") != "hello" {
+		t.Errorf("expected note hello, got %q", req.FormValue("note"))
+	}
+}
+
+func TestParseInput_InvalidJSON(t *testing.T) {
+	h := &BaseHandler{}
+	for _, body := range []string{`{"note":`, `["a"]`, `{"nested": {"a": 1}}`} {
+		req := httptest.NewRequest("POST", "/test", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		if err := h.ParseInput(req); err == nil {
+			t.Errorf("expected error for body %s", body)
+		}
+	}
+}
+
+func TestUserID(t *testing.T) {
+	h := &BaseHandler{}
+	req := httptest.NewRequest("GET", "/", nil)
+
+	if id := h.UserID(req); id != 0 {
+		t.Errorf("expected 0 without a user, got %d", id)
+	}
+
+	req = req.WithContext(WithUser(req.Context(), User{ID: 3, Username: "alice"}))
+	if id := h.UserID(req); id != 3 {
+		t.Errorf("expected user 3, got %d", id)
+	}
+	if user := UserFromContext(req.Context()); user.Username != "alice" {
+		t.Errorf("expected alice, got %+v", user)
+	}
+}
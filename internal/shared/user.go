@@ -0,0 +1,33 @@
+package shared
+
+import (
+	"context"
+	"net/http"
+)
+
+// User identifies the account a request was authenticated as
+type User struct {
+	ID       int
+	Username string
+}
+
+// userKey is the context key holding the authenticated user
+type userKey struct{}
+
+// WithUser returns a copy of ctx carrying the authenticated user
+func WithUser(ctx context.Context, user User) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFromContext returns the authenticated user, or the zero User when the
+// request is not authenticated
+func UserFromContext(ctx context.Context) User {
+	user, _ := ctx.Value(userKey{}).(User)
+	return user
+}
+
+// UserID returns the ID of the user the auth middleware authenticated.
+// Stores scope every habit query to it.
+func (h *BaseHandler) UserID(r *http.Request) int {
+	return UserFromContext(r.Context()).ID
+}
@@ -1,15 +1,20 @@
 package streak
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+
+	"github.com/epalmerini/abitudini/internal/habit"
 
 	"github.com/epalmerini/abitudini/internal/shared"
 )
 
 // HandlerService interface for dependency injection
 type HandlerService interface {
-	GetByHabitID(habitID int) (*Streak, error)
+	GetByHabitID(userID, habitID int) (*Streak, error)
+	GetHistory(userID, habitID int) (*History, error)
 }
 
 type Handler struct {
@@ -28,25 +33,111 @@ func (h *Handler) GetByHabitID(w http.ResponseWriter, r *http.Request) {
 
 	habitID, err := h.ExtractIntPathParam(r, "id")
 	if err != nil {
-		h.WriteError(w, "Invalid habit ID", http.StatusBadRequest)
+		h.RespondError(w, r, "Invalid habit ID", http.StatusBadRequest)
 		return
 	}
 
-	streak, err := h.service.GetByHabitID(habitID)
+	streak, err := h.service.GetByHabitID(h.UserID(r), habitID)
 	if err != nil {
-		h.WriteError(w, err.Error(), http.StatusInternalServerError)
+		h.RespondError(w, r, err.Error(), errorStatus(err))
 		return
 	}
 
-	streakLabel := "day streak"
-	if streak.CurrentCount != 1 {
-		streakLabel = "days streak"
+	if h.WantsJSON(r) {
+		h.WriteJSON(w, http.StatusOK, streak)
+		return
+	}
+
+	streakLabel := unitLabel(streak.Period, streak.CurrentCount) + " streak"
+
+	best := ""
+	if streak.LongestCount > 0 {
+		best = fmt.Sprintf(`
+		<span class="streak-best">best: %d</span>`, streak.LongestCount)
+	}
+
+	// Only habits with a grace day budget show their freezes
+	freezes := ""
+	if streak.FreezesUsed+streak.FreezesRemaining > 0 {
+		freezes = fmt.Sprintf(`
+		<span class="streak-freezes" title="Grace days this month">❄ %d used, %d left</span>`,
+			streak.FreezesUsed, streak.FreezesRemaining)
 	}
 
 	html := fmt.Sprintf(`<div class="streak-display">
 		<span class="streak-count">%d</span>
-		<span class="streak-label">%s</span>
-	</div>`, streak.CurrentCount, streakLabel)
+		<span class="streak-label">%s</span>%s%s
+	</div>`, streak.CurrentCount, streakLabel, best, freezes)
 
 	h.WriteHTML(w, html)
 }
+
+// GetHistory renders the longest streak and every past run, most recent first
+func (h *Handler) GetHistory(w http.ResponseWriter, r *http.Request) {
+	if !h.ValidateMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	habitID, err := h.ExtractIntPathParam(r, "id")
+	if err != nil {
+		h.RespondError(w, r, "Invalid habit ID", http.StatusBadRequest)
+		return
+	}
+
+	history, err := h.service.GetHistory(h.UserID(r), habitID)
+	if err != nil {
+		h.RespondError(w, r, err.Error(), errorStatus(err))
+		return
+	}
+
+	if h.WantsJSON(r) {
+		h.WriteJSON(w, http.StatusOK, history)
+		return
+	}
+
+	if history.Longest == nil {
+		h.WriteHTML(w, `<p class="streak-history-empty">No streaks yet</p>`)
+		return
+	}
+
+	var html strings.Builder
+	fmt.Fprintf(&html, `<p class="streak-best">Best: %d %s · %s</p>`,
+		history.Longest.Length, unitLabel(history.Period, history.Longest.Length), runDates(*history.Longest))
+	html.WriteString(`<ul class="streak-runs">`)
+	for _, run := range history.Runs {
+		fmt.Fprintf(&html, `<li><span class="streak-run-length">%d %s</span> <span class="streak-run-dates">%s</span></li>`,
+			run.Length, unitLabel(history.Period, run.Length), runDates(run))
+	}
+	html.WriteString(`</ul>`)
+
+	h.WriteHTML(w, html.String())
+}
+
+// errorStatus maps a service error to its HTTP status
+func errorStatus(err error) int {
+	if errors.Is(err, habit.ErrHabitNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// unitLabel names what a streak counts: days, or weeks or months for
+// frequency habits
+func unitLabel(period habit.Period, count int) string {
+	unit := "day"
+	if period != "" {
+		unit = string(period)
+	}
+	if count != 1 {
+		unit += "s"
+	}
+	return unit
+}
+
+// runDates formats the span of a run
+func runDates(run Run) string {
+	if run.Start.Equal(run.End) {
+		return run.Start.Format("Jan 02, 2006")
+	}
+	return run.Start.Format("Jan 02, 2006") + " – " + run.End.Format("Jan 02, 2006")
+}
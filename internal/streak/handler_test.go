@@ -2,24 +2,36 @@ package streak
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/epalmerini/abitudini/internal/habit"
 )
 
 type mockStreakHandlerService struct {
-	streak *Streak
-	err    error
+	streak  *Streak
+	history *History
+	err     error
 }
 
-func (m *mockStreakHandlerService) GetByHabitID(habitID int) (*Streak, error) {
+func (m *mockStreakHandlerService) GetByHabitID(userID, habitID int) (*Streak, error) {
 	if m.err != nil {
 		return nil, m.err
 	}
 	return m.streak, nil
 }
 
+func (m *mockStreakHandlerService) GetHistory(userID, habitID int) (*History, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.history, nil
+}
+
 func TestStreakGetByHabitID_Success(t *testing.T) {
 	streak := &Streak{HabitID: 1, CurrentCount: 5}
 	service := &mockStreakHandlerService{streak: streak}
@@ -129,6 +141,29 @@ func TestStreakGetByHabitID_ServiceError(t *testing.T) {
 	}
 }
 
+func TestStreakHandler_HabitNotFound(t *testing.T) {
+	handler := NewHandler(&mockStreakHandlerService{err: fmt.Errorf("lookup: %w", habit.ErrHabitNotFound)})
+
+	for path, serve := range map[string]http.HandlerFunc{
+		"/api/habits/1/streak":  handler.GetByHabitID,
+		"/api/habits/1/streaks": handler.GetHistory,
+	} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Accept", "application/json")
+		req.SetPathValue("id", "1")
+		w := httptest.NewRecorder()
+
+		serve(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: expected status 404, got %d", path, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), `"error"`) {
+			t.Errorf("%s: expected a JSON error, got %s", path, w.Body.String())
+		}
+	}
+}
+
 func TestStreakGetByHabitID_LargeStreak(t *testing.T) {
 	streak := &Streak{HabitID: 1, CurrentCount: 365}
 	service := &mockStreakHandlerService{streak: streak}
@@ -148,3 +183,141 @@ func TestStreakGetByHabitID_LargeStreak(t *testing.T) {
 		t.Error("expected streak count 365 in response")
 	}
 }
+
+func TestStreakGetByHabitID_PeriodLabel(t *testing.T) {
+	streak := &Streak{HabitID: 1, CurrentCount: 3, Period: habit.PeriodWeek}
+	handler := NewHandler(&mockStreakHandlerService{streak: streak})
+
+	req := httptest.NewRequest("GET", "/api/habits/1/streak", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	handler.GetByHabitID(w, req)
+
+	if !strings.Contains(w.Body.String(), "weeks streak") {
+		t.Error("expected 'weeks streak' label in response")
+	}
+}
+
+func TestStreakGetByHabitID_Freezes(t *testing.T) {
+	streak := &Streak{HabitID: 1, CurrentCount: 5, FreezesUsed: 1, FreezesRemaining: 2}
+	handler := NewHandler(&mockStreakHandlerService{streak: streak})
+
+	req := httptest.NewRequest("GET", "/api/habits/1/streak", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	handler.GetByHabitID(w, req)
+
+	if !strings.Contains(w.Body.String(), "1 used, 2 left") {
+		t.Errorf("expected freezes in response, got %s", w.Body.String())
+	}
+}
+
+func TestStreakGetByHabitID_NoFreezeBudget(t *testing.T) {
+	streak := &Streak{HabitID: 1, CurrentCount: 5}
+	handler := NewHandler(&mockStreakHandlerService{streak: streak})
+
+	req := httptest.NewRequest("GET", "/api/habits/1/streak", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	handler.GetByHabitID(w, req)
+
+	if strings.Contains(w.Body.String(), "streak-freezes") {
+		t.Error("expected no freezes without a grace day budget")
+	}
+}
+
+func TestStreakGetByHabitID_Best(t *testing.T) {
+	streak := &Streak{HabitID: 1, CurrentCount: 2, LongestCount: 12}
+	handler := NewHandler(&mockStreakHandlerService{streak: streak})
+
+	req := httptest.NewRequest("GET", "/api/habits/1/streak", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	handler.GetByHabitID(w, req)
+
+	if !strings.Contains(w.Body.String(), "best: 12") {
+		t.Errorf("expected best streak in response, got %s", w.Body.String())
+	}
+}
+
+func TestStreakGetHistory(t *testing.T) {
+	longest := Run{
+		Start:  time.Date(2025, 1, 8, 0, 0, 0, 0, time.UTC),
+		End:    time.Date(2025, 1, 14, 0, 0, 0, 0, time.UTC),
+		Length: 7,
+	}
+	history := &History{
+		HabitID: 1,
+		Longest: &longest,
+		Runs: []Run{
+			{Start: time.Date(2025, 1, 19, 0, 0, 0, 0, time.UTC), End: time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC), Length: 2},
+			longest,
+		},
+	}
+	handler := NewHandler(&mockStreakHandlerService{history: history})
+
+	req := httptest.NewRequest("GET", "/api/habits/1/streaks", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	handler.GetHistory(w, req)
+
+	body := w.Body.String()
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+	if !strings.Contains(body, "Best: 7 days · Jan 08, 2025 – Jan 14, 2025") {
+		t.Errorf("expected longest run in response, got %s", body)
+	}
+	if strings.Count(body, "<li>") != 2 {
+		t.Errorf("expected 2 runs listed, got %s", body)
+	}
+}
+
+func TestStreakGetHistory_Empty(t *testing.T) {
+	handler := NewHandler(&mockStreakHandlerService{history: &History{HabitID: 1, Runs: []Run{}}})
+
+	req := httptest.NewRequest("GET", "/api/habits/1/streaks", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	handler.GetHistory(w, req)
+
+	if !strings.Contains(w.Body.String(), "No streaks yet") {
+		t.Errorf("expected empty state, got %s", w.Body.String())
+	}
+}
+
+func TestStreakGetHistory_ServiceError(t *testing.T) {
+	handler := NewHandler(&mockStreakHandlerService{err: errors.New("boom")})
+
+	req := httptest.NewRequest("GET", "/api/habits/1/streaks", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	handler.GetHistory(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", w.Code)
+	}
+}
+
+func TestStreakGetByHabitID_JSON(t *testing.T) {
+	streak := &Streak{HabitID: 1, CurrentCount: 4, LongestCount: 9}
+	handler := NewHandler(&mockStreakHandlerService{streak: streak})
+
+	req := httptest.NewRequest("GET", "/api/habits/1/streak", nil)
+	req.Header.Set("Accept", "application/json")
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	handler.GetByHabitID(w, req)
+
+	if !strings.Contains(w.Body.String(), `"current_count":4`) || !strings.Contains(w.Body.String(), `"longest_count":9`) {
+		t.Errorf("expected streak JSON, got %s", w.Body.String())
+	}
+}
@@ -1,6 +1,33 @@
 package streak
 
+import (
+	"time"
+
+	"github.com/epalmerini/abitudini/internal/habit"
+)
+
 type Streak struct {
-	HabitID      int `json:"habit_id"`
-	CurrentCount int `json:"current_count"`
+	HabitID          int          `json:"habit_id"`
+	CurrentCount     int          `json:"current_count"`
+	LongestCount     int          `json:"longest_count"`
+	FreezesUsed      int          `json:"freezes_used"`      // grace days absorbed this month
+	FreezesRemaining int          `json:"freezes_remaining"` // grace days left this month
+	Period           habit.Period `json:"period,omitempty"`
+}
+
+// Run is one unbroken streak. Start and End are the first and last completed
+// day, or the bounds of the first and last period for frequency habits.
+type Run struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Length int       `json:"length"`
+}
+
+// History is every streak a habit has had, most recent first
+type History struct {
+	HabitID      int          `json:"habit_id"`
+	CurrentCount int          `json:"current_count"`
+	Longest      *Run         `json:"longest,omitempty"`
+	Runs         []Run        `json:"runs"`
+	Period       habit.Period `json:"period,omitempty"`
 }
@@ -1,15 +1,18 @@
 package streak
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/epalmerini/abitudini/internal/habit"
 )
 
-// StoreAdapter defines the interface for data access
+// StoreAdapter defines the interface for data access, scoped to the
+// habits owned by userID
 type StoreAdapter interface {
-	GetHabitByID(habitID int) (*habit.Habit, error)
-	GetRecordsByHabit(habitID int) ([]time.Time, error)
+	GetHabitByID(userID, habitID int) (*habit.Habit, error)
+	GetRecordsByHabit(userID, habitID int) ([]time.Time, error)
+	GetSkippedByHabit(userID, habitID int) ([]time.Time, error)
 }
 
 type Service struct {
@@ -20,32 +23,268 @@ func NewService(store StoreAdapter) *Service {
 	return &Service{store: store}
 }
 
-func (s *Service) GetByHabitID(habitID int) (*Streak, error) {
-	recordDates, err := s.store.GetRecordsByHabit(habitID)
+// GetByHabitID returns the current and longest streak of one of userID's
+// habits. A habit that is missing or owned by someone else yields
+// habit.ErrHabitNotFound.
+func (s *Service) GetByHabitID(userID, habitID int) (*Streak, error) {
+	h, result, err := s.walk(userID, habitID)
 	if err != nil {
-		return &Streak{HabitID: habitID, CurrentCount: 0}, nil
+		return nil, err
 	}
 
-	count := s.calculateDailyStreak(time.Now(), recordDates)
-	return &Streak{HabitID: habitID, CurrentCount: count}, nil
+	streak := &Streak{
+		HabitID:      habitID,
+		CurrentCount: result.current,
+		FreezesUsed:  result.freezesUsed,
+	}
+	if longest := longestRun(result.runs); longest != nil {
+		streak.LongestCount = longest.Length
+	}
+	if h == nil {
+		return streak, nil
+	}
+	if h.HasFrequency() {
+		streak.Period = h.TargetPeriod
+	} else if h.GraceDays > result.freezesUsed {
+		streak.FreezesRemaining = h.GraceDays - result.freezesUsed
+	}
+	return streak, nil
+}
+
+// GetHistory returns every streak run of a habit along with the longest one
+func (s *Service) GetHistory(userID, habitID int) (*History, error) {
+	h, result, err := s.walk(userID, habitID)
+	if err != nil {
+		return nil, err
+	}
+
+	history := &History{
+		HabitID:      habitID,
+		CurrentCount: result.current,
+		Longest:      longestRun(result.runs),
+		Runs:         result.runs,
+	}
+	if h != nil && h.HasFrequency() {
+		history.Period = h.TargetPeriod
+	}
+	return history, nil
 }
 
-func (s *Service) calculateDailyStreak(today time.Time, recordDates []time.Time) int {
+// walk loads a habit's records and computes its streak runs
+func (s *Service) walk(userID, habitID int) (*habit.Habit, streakRuns, error) {
+	h, err := s.store.GetHabitByID(userID, habitID)
+	if err != nil {
+		return nil, streakRuns{}, err
+	}
+
+	recordDates, err := s.store.GetRecordsByHabit(userID, habitID)
+	if err != nil {
+		return nil, streakRuns{}, fmt.Errorf("failed to get records: %w", err)
+	}
+
+	skippedDates, err := s.store.GetSkippedByHabit(userID, habitID)
+	if err != nil {
+		return nil, streakRuns{}, fmt.Errorf("failed to get skipped days: %w", err)
+	}
+
+	if h != nil && h.HasFrequency() {
+		return h, s.periodRuns(time.Now(), h, recordDates, skippedDates), nil
+	}
+	return h, s.dailyRuns(time.Now(), h, recordDates, skippedDates), nil
+}
+
+// streakRuns is the outcome of walking a habit's history backward from today
+type streakRuns struct {
+	runs        []Run // most recent first
+	current     int   // length of the streak still alive today
+	freezesUsed int   // grace days charged to today's month
+}
+
+// dailyRuns splits a habit's history into runs of consecutive scheduled days
+// completed, walking backward from today. Days the habit is not scheduled for
+// and excused (skipped) days are passed over, and the walk stops at the
+// habit's start date. Today only counts once completed; until then it does
+// not break the streak. Missed days inside a run are absorbed by the habit's
+// monthly grace day budget until it runs out, with recent runs drawing on the
+// budget first. A nil habit is treated as due every day with no start date
+// and no grace days.
+func (s *Service) dailyRuns(today time.Time, h *habit.Habit, recordDates, skippedDates []time.Time) streakRuns {
+	result := streakRuns{runs: []Run{}}
 	if len(recordDates) == 0 {
-		return 0
+		return result
+	}
+
+	completed := make(map[string]bool, len(recordDates))
+	earliest := recordDates[0].Format("2006-01-02")
+	for _, record := range recordDates {
+		date := record.Format("2006-01-02")
+		completed[date] = true
+		if date < earliest {
+			earliest = date
+		}
+	}
+
+	// Nothing can be completed before the first record or the start date
+	stop := earliest
+	if h != nil && !h.StartDate.IsZero() {
+		if start := h.StartDate.Format("2006-01-02"); start > stop {
+			stop = start
+		}
+	}
+
+	skipped := dateSet(skippedDates)
+
+	budget := 0
+	if h != nil {
+		budget = h.GraceDays
 	}
+	// Grace days are charged per month, and only once a completed day
+	// further back shows the missed days were inside a run
+	used := make(map[string]int)
+	pending := make(map[string]int)
 
-	count := 0
-	currentDate := today
+	// The walk starts inside the current streak, which may still be empty
+	var run Run
+	open, live := true, true
+	closeRun := func() {
+		if run.Length > 0 {
+			result.runs = append(result.runs, run)
+		}
+		if live {
+			result.current = run.Length
+			live = false
+		}
+		run = Run{}
+		open = false
+		clear(pending)
+	}
+
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	for d := today; d.Format("2006-01-02") >= stop; d = d.AddDate(0, 0, -1) {
+		key := d.Format("2006-01-02")
+		if (h != nil && !h.IsScheduledOn(d)) || skipped[key] {
+			continue
+		}
+		if completed[key] {
+			if run.Length == 0 {
+				run.End = d
+			}
+			run.Start = d
+			run.Length++
+			open = true
+			for month, n := range pending {
+				used[month] += n
+			}
+			clear(pending)
+			continue
+		}
+		if d.Equal(today) || !open {
+			continue
+		}
+		month := d.Format("2006-01")
+		if used[month]+pending[month] < budget {
+			pending[month]++
+			continue
+		}
+		closeRun()
+	}
+	closeRun()
 
+	result.freezesUsed = used[today.Format("2006-01")]
+	return result
+}
+
+// periodRuns splits a habit's history into runs of consecutive periods (weeks
+// or months) that met the target, walking backward from the current period.
+// The current period only counts once its target is met; until then it does
+// not break the streak. A missed period containing excused (skipped) days is
+// neutral. Runs span from the first day of their first period to the last day
+// of their last period.
+func (s *Service) periodRuns(today time.Time, h *habit.Habit, recordDates, skippedDates []time.Time) streakRuns {
+	result := streakRuns{runs: []Run{}}
+	if len(recordDates) == 0 {
+		return result
+	}
+
+	// Record dates are UTC midnights; compare periods on the same clock
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	period := h.TargetPeriod
+	counts := make(map[string]int)
+	earliest := recordDates[0]
 	for _, record := range recordDates {
-		if record.Format("2006-01-02") == currentDate.Format("2006-01-02") {
-			count++
-			currentDate = currentDate.AddDate(0, 0, -1)
-		} else {
-			break
+		counts[period.Start(record).Format("2006-01-02")]++
+		if record.Before(earliest) {
+			earliest = record
 		}
 	}
 
-	return count
+	excused := make(map[string]bool)
+	for _, d := range skippedDates {
+		excused[period.Start(d).Format("2006-01-02")] = true
+	}
+
+	stop := period.Start(earliest)
+	if !h.StartDate.IsZero() && h.StartDate.After(earliest) {
+		stop = period.Start(h.StartDate)
+	}
+
+	var run Run
+	open, live := true, true
+	closeRun := func() {
+		if run.Length > 0 {
+			result.runs = append(result.runs, run)
+		}
+		if live {
+			result.current = run.Length
+			live = false
+		}
+		run = Run{}
+		open = false
+	}
+
+	current := period.Start(today)
+	for p := current; !p.Before(stop); p = previousPeriod(period, p) {
+		key := p.Format("2006-01-02")
+		if counts[key] >= h.TargetCount {
+			if run.Length == 0 {
+				_, run.End = period.Bounds(p)
+			}
+			run.Start = p
+			run.Length++
+			open = true
+			continue
+		}
+		if p.Equal(current) || excused[key] || !open {
+			continue
+		}
+		closeRun()
+	}
+	closeRun()
+
+	return result
+}
+
+// longestRun returns the longest run, preferring the most recent on ties
+func longestRun(runs []Run) *Run {
+	var longest *Run
+	for i := range runs {
+		if longest == nil || runs[i].Length > longest.Length {
+			longest = &runs[i]
+		}
+	}
+	return longest
+}
+
+// previousPeriod returns the start of the period before the one starting at start
+func previousPeriod(p habit.Period, start time.Time) time.Time {
+	return p.Start(start.AddDate(0, 0, -1))
+}
+
+// dateSet indexes dates by their YYYY-MM-DD form
+func dateSet(dates []time.Time) map[string]bool {
+	set := make(map[string]bool, len(dates))
+	for _, d := range dates {
+		set[d.Format("2006-01-02")] = true
+	}
+	return set
 }
@@ -11,24 +11,32 @@ import (
 type mockStreakStore struct {
 	habit   *habit.Habit
 	records []time.Time
+	skipped []time.Time
 	habitErr error
 	recordsErr error
 }
 
-func (m *mockStreakStore) GetHabitByID(habitID int) (*habit.Habit, error) {
+func (m *mockStreakStore) GetHabitByID(userID, habitID int) (*habit.Habit, error) {
 	if m.habitErr != nil {
 		return nil, m.habitErr
 	}
 	return m.habit, nil
 }
 
-func (m *mockStreakStore) GetRecordsByHabit(habitID int) ([]time.Time, error) {
+func (m *mockStreakStore) GetRecordsByHabit(userID, habitID int) ([]time.Time, error) {
 	if m.recordsErr != nil {
 		return nil, m.recordsErr
 	}
 	return m.records, nil
 }
 
+func (m *mockStreakStore) GetSkippedByHabit(userID, habitID int) ([]time.Time, error) {
+	if m.recordsErr != nil {
+		return nil, m.recordsErr
+	}
+	return m.skipped, nil
+}
+
 func TestGetByHabitID_Success(t *testing.T) {
 	s := NewService(&mockStreakStore{
 		records: []time.Time{
@@ -37,7 +45,7 @@ func TestGetByHabitID_Success(t *testing.T) {
 		},
 	})
 
-	streak, err := s.GetByHabitID(1)
+	streak, err := s.GetByHabitID(1, 1)
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -51,16 +59,14 @@ func TestGetByHabitID_Success(t *testing.T) {
 
 func TestGetByHabitID_HabitNotFound(t *testing.T) {
 	s := NewService(&mockStreakStore{
-		recordsErr: errors.New("habit not found"),
+		habitErr: habit.ErrHabitNotFound,
 	})
 
-	streak, err := s.GetByHabitID(1)
-	// Should return zero streak on error, not an error
-	if err != nil {
-		t.Fatalf("expected no error, got %v", err)
+	if _, err := s.GetByHabitID(1, 1); !errors.Is(err, habit.ErrHabitNotFound) {
+		t.Errorf("expected ErrHabitNotFound, got %v", err)
 	}
-	if streak.CurrentCount != 0 {
-		t.Errorf("expected CurrentCount 0, got %d", streak.CurrentCount)
+	if _, err := s.GetHistory(1, 1); !errors.Is(err, habit.ErrHabitNotFound) {
+		t.Errorf("expected ErrHabitNotFound from history, got %v", err)
 	}
 }
 
@@ -69,7 +75,7 @@ func TestGetByHabitID_NoRecords(t *testing.T) {
 		records: []time.Time{},
 	})
 
-	streak, err := s.GetByHabitID(1)
+	streak, err := s.GetByHabitID(1, 1)
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -83,16 +89,12 @@ func TestGetByHabitID_RecordsFetchError(t *testing.T) {
 		recordsErr: errors.New("fetch records failed"),
 	})
 
-	streak, err := s.GetByHabitID(1)
-	if err != nil {
-		t.Fatalf("expected no error, got %v", err)
-	}
-	if streak.CurrentCount != 0 {
-		t.Errorf("expected CurrentCount 0 on fetch error, got %d", streak.CurrentCount)
+	if _, err := s.GetByHabitID(1, 1); err == nil {
+		t.Error("expected error when fetching records fails")
 	}
 }
 
-func TestCalculateDailyStreak_Consecutive(t *testing.T) {
+func TestDailyRuns_Consecutive(t *testing.T) {
 	s := NewService(nil)
 	now := time.Now()
 	records := []time.Time{
@@ -102,13 +104,13 @@ func TestCalculateDailyStreak_Consecutive(t *testing.T) {
 		now.AddDate(0, 0, -3),
 	}
 
-	count := s.calculateDailyStreak(now, records)
+	count := s.dailyRuns(now, nil, records, nil).current
 	if count != 4 {
 		t.Errorf("expected streak of 4, got %d", count)
 	}
 }
 
-func TestCalculateDailyStreak_Broken(t *testing.T) {
+func TestDailyRuns_Broken(t *testing.T) {
 	s := NewService(nil)
 	now := time.Now()
 	records := []time.Time{
@@ -117,20 +119,433 @@ func TestCalculateDailyStreak_Broken(t *testing.T) {
 		now.AddDate(0, 0, -3), // gap here
 	}
 
-	count := s.calculateDailyStreak(now, records)
+	count := s.dailyRuns(now, nil, records, nil).current
 	if count != 2 {
 		t.Errorf("expected streak of 2 (broken), got %d", count)
 	}
 }
 
-func TestCalculateDailyStreak_Empty(t *testing.T) {
+func TestDailyRuns_Empty(t *testing.T) {
 	s := NewService(nil)
-	count := s.calculateDailyStreak(time.Now(), []time.Time{})
+	count := s.dailyRuns(time.Now(), nil, []time.Time{}, nil).current
 	if count != 0 {
 		t.Errorf("expected streak of 0 for empty records, got %d", count)
 	}
 }
 
+func TestGetByHabitID_HabitLookupError(t *testing.T) {
+	s := NewService(&mockStreakStore{
+		habitErr: errors.New("database is locked"),
+		records:  []time.Time{time.Now()},
+	})
+
+	if _, err := s.GetByHabitID(1, 1); err == nil {
+		t.Error("expected error when habit lookup fails")
+	}
+}
+
+func TestDailyRuns_SkipsUnscheduledDays(t *testing.T) {
+	s := NewService(nil)
+	friday := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	h := &habit.Habit{Days: []time.Weekday{time.Monday, time.Wednesday, time.Friday}}
+	records := []time.Time{
+		friday,
+		friday.AddDate(0, 0, -2), // Wednesday
+		friday.AddDate(0, 0, -4), // Monday
+		friday.AddDate(0, 0, -7), // previous Friday
+	}
+
+	count := s.dailyRuns(friday, h, records, nil).current
+	if count != 4 {
+		t.Errorf("expected streak of 4, got %d", count)
+	}
+}
+
+func TestDailyRuns_TodayUnscheduled(t *testing.T) {
+	s := NewService(nil)
+	saturday := time.Date(2025, 1, 11, 12, 0, 0, 0, time.UTC)
+	h := &habit.Habit{Days: []time.Weekday{time.Friday}}
+	records := []time.Time{saturday.AddDate(0, 0, -1)}
+
+	count := s.dailyRuns(saturday, h, records, nil).current
+	if count != 1 {
+		t.Errorf("expected streak of 1, got %d", count)
+	}
+}
+
+func TestDailyRuns_StopsAtStartDate(t *testing.T) {
+	s := NewService(nil)
+	today := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	h := &habit.Habit{StartDate: today.AddDate(0, 0, -1)}
+	records := []time.Time{
+		today,
+		today.AddDate(0, 0, -1),
+		today.AddDate(0, 0, -2), // before start date
+	}
+
+	count := s.dailyRuns(today, h, records, nil).current
+	if count != 2 {
+		t.Errorf("expected streak of 2, got %d", count)
+	}
+}
+
+func TestPeriodRuns_Weekly(t *testing.T) {
+	s := NewService(nil)
+	// Wednesday; the current week has one completion so far
+	today := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	h := &habit.Habit{TargetCount: 2, TargetPeriod: habit.PeriodWeek}
+	records := []time.Time{
+		today,
+		today.AddDate(0, 0, -7), today.AddDate(0, 0, -8), // previous week met
+		today.AddDate(0, 0, -14), today.AddDate(0, 0, -13), // week before met
+		today.AddDate(0, 0, -28), today.AddDate(0, 0, -27), // gap week before this
+	}
+
+	count := s.periodRuns(today, h, records, nil).current
+	if count != 2 {
+		t.Errorf("expected streak of 2 weeks, got %d", count)
+	}
+}
+
+func TestPeriodRuns_CurrentPeriodMet(t *testing.T) {
+	s := NewService(nil)
+	today := time.Date(2025, 3, 20, 12, 0, 0, 0, time.UTC)
+	h := &habit.Habit{TargetCount: 1, TargetPeriod: habit.PeriodMonth}
+	records := []time.Time{
+		today,
+		today.AddDate(0, -1, 0),
+	}
+
+	count := s.periodRuns(today, h, records, nil).current
+	if count != 2 {
+		t.Errorf("expected streak of 2 months, got %d", count)
+	}
+}
+
+func TestPeriodRuns_LocalClock(t *testing.T) {
+	s := NewService(nil)
+	// Monday morning east of UTC, where the week starts before UTC midnight
+	today := time.Date(2025, 1, 13, 9, 0, 0, 0, time.FixedZone("CET", 60*60))
+	h := &habit.Habit{TargetCount: 1, TargetPeriod: habit.PeriodWeek}
+	records := []time.Time{time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)}
+
+	count := s.periodRuns(today, h, records, nil).current
+	if count != 1 {
+		t.Errorf("expected streak of 1 week, got %d", count)
+	}
+}
+
+func TestGetByHabitID_FrequencyHabit(t *testing.T) {
+	s := NewService(&mockStreakStore{
+		habit:   &habit.Habit{ID: 1, TargetCount: 1, TargetPeriod: habit.PeriodWeek},
+		records: []time.Time{time.Now()},
+	})
+
+	streak, err := s.GetByHabitID(1, 1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if streak.Period != habit.PeriodWeek {
+		t.Errorf("expected weekly streak, got %q", streak.Period)
+	}
+	if streak.CurrentCount != 1 {
+		t.Errorf("expected CurrentCount 1, got %d", streak.CurrentCount)
+	}
+}
+
+func TestDailyRuns_SkippedDaysAreNeutral(t *testing.T) {
+	s := NewService(nil)
+	now := time.Now()
+	records := []time.Time{
+		now,
+		now.AddDate(0, 0, -3),
+		now.AddDate(0, 0, -4),
+	}
+	skipped := []time.Time{
+		now.AddDate(0, 0, -1),
+		now.AddDate(0, 0, -2),
+	}
+
+	count := s.dailyRuns(now, nil, records, skipped).current
+	if count != 3 {
+		t.Errorf("expected streak of 3 across skipped days, got %d", count)
+	}
+}
+
+func TestPeriodRuns_ExcusedPeriodIsNeutral(t *testing.T) {
+	s := NewService(nil)
+	today := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	h := &habit.Habit{TargetCount: 1, TargetPeriod: habit.PeriodWeek}
+	records := []time.Time{
+		today,
+		today.AddDate(0, 0, -14),
+	}
+	// The week in between was excused
+	skipped := []time.Time{today.AddDate(0, 0, -7)}
+
+	count := s.periodRuns(today, h, records, skipped).current
+	if count != 2 {
+		t.Errorf("expected streak of 2 weeks across excused week, got %d", count)
+	}
+}
+
+func TestDailyRuns_TodayPending(t *testing.T) {
+	s := NewService(nil)
+	now := time.Now()
+	records := []time.Time{
+		now.AddDate(0, 0, -1),
+		now.AddDate(0, 0, -2),
+	}
+
+	count := s.dailyRuns(now, nil, records, nil).current
+	if count != 2 {
+		t.Errorf("expected streak of 2 while today is still open, got %d", count)
+	}
+}
+
+func TestDailyRuns_GraceDaysAbsorbMisses(t *testing.T) {
+	s := NewService(nil)
+	today := time.Date(2025, 1, 20, 12, 0, 0, 0, time.UTC)
+	h := &habit.Habit{GraceDays: 2}
+	records := []time.Time{
+		today,
+		today.AddDate(0, 0, -2), // the 19th is missed
+		today.AddDate(0, 0, -3),
+		today.AddDate(0, 0, -5), // the 16th is missed
+	}
+
+	result := s.dailyRuns(today, h, records, nil)
+	count, used := result.current, result.freezesUsed
+	if count != 4 {
+		t.Errorf("expected streak of 4, got %d", count)
+	}
+	if used != 2 {
+		t.Errorf("expected 2 grace days used, got %d", used)
+	}
+}
+
+func TestDailyRuns_GraceBudgetExhausted(t *testing.T) {
+	s := NewService(nil)
+	today := time.Date(2025, 1, 20, 12, 0, 0, 0, time.UTC)
+	h := &habit.Habit{GraceDays: 1}
+	records := []time.Time{
+		today,
+		today.AddDate(0, 0, -2),
+		today.AddDate(0, 0, -4),
+	}
 
+	result := s.dailyRuns(today, h, records, nil)
+	count, used := result.current, result.freezesUsed
+	if count != 2 {
+		t.Errorf("expected streak of 2, got %d", count)
+	}
+	if used != 1 {
+		t.Errorf("expected 1 grace day used, got %d", used)
+	}
+}
 
+func TestDailyRuns_GraceBudgetIsMonthly(t *testing.T) {
+	s := NewService(nil)
+	today := time.Date(2025, 2, 2, 12, 0, 0, 0, time.UTC)
+	h := &habit.Habit{GraceDays: 1}
+	records := []time.Time{
+		today,
+		today.AddDate(0, 0, -2), // Jan 31, Feb 1 missed
+		today.AddDate(0, 0, -4), // Jan 29, Jan 30 missed
+	}
 
+	result := s.dailyRuns(today, h, records, nil)
+	count, used := result.current, result.freezesUsed
+	if count != 3 {
+		t.Errorf("expected streak of 3 with one grace day per month, got %d", count)
+	}
+	if used != 1 {
+		t.Errorf("expected 1 grace day used in February, got %d", used)
+	}
+}
+
+func TestDailyRuns_UnusedGraceNotCharged(t *testing.T) {
+	s := NewService(nil)
+	today := time.Date(2025, 1, 20, 12, 0, 0, 0, time.UTC)
+	h := &habit.Habit{GraceDays: 3}
+	records := []time.Time{
+		today,
+		today.AddDate(0, 0, -10),
+	}
+
+	result := s.dailyRuns(today, h, records, nil)
+	count, used := result.current, result.freezesUsed
+	if count != 1 {
+		t.Errorf("expected streak of 1, got %d", count)
+	}
+	if used != 0 {
+		t.Errorf("expected no grace days charged for a broken gap, got %d", used)
+	}
+}
+
+func TestGetByHabitID_ReportsFreezes(t *testing.T) {
+	now := time.Now()
+	s := NewService(&mockStreakStore{
+		habit: &habit.Habit{GraceDays: 3},
+		records: []time.Time{
+			now,
+			now.AddDate(0, 0, -1),
+		},
+	})
+
+	streak, err := s.GetByHabitID(1, 1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if streak.FreezesUsed != 0 || streak.FreezesRemaining != 3 {
+		t.Errorf("expected 0 used and 3 remaining, got %d and %d", streak.FreezesUsed, streak.FreezesRemaining)
+	}
+}
+
+func TestDailyRuns_History(t *testing.T) {
+	s := NewService(nil)
+	today := time.Date(2025, 1, 20, 12, 0, 0, 0, time.UTC)
+	var records []time.Time
+	// Jan 1-5, Jan 8-14 and Jan 19-20
+	for _, day := range []int{1, 2, 3, 4, 5, 8, 9, 10, 11, 12, 13, 14, 19, 20} {
+		records = append(records, time.Date(2025, 1, day, 0, 0, 0, 0, time.UTC))
+	}
+
+	result := s.dailyRuns(today, nil, records, nil)
+	if result.current != 2 {
+		t.Errorf("expected current streak of 2, got %d", result.current)
+	}
+	if len(result.runs) != 3 {
+		t.Fatalf("expected 3 runs, got %d", len(result.runs))
+	}
+
+	longest := longestRun(result.runs)
+	if longest.Length != 7 {
+		t.Errorf("expected longest run of 7, got %d", longest.Length)
+	}
+	if longest.Start.Day() != 8 || longest.End.Day() != 14 {
+		t.Errorf("expected longest run Jan 8-14, got %v to %v", longest.Start, longest.End)
+	}
+}
+
+func TestDailyRuns_BrokenCurrentStreak(t *testing.T) {
+	s := NewService(nil)
+	today := time.Date(2025, 1, 20, 12, 0, 0, 0, time.UTC)
+	records := []time.Time{
+		today.AddDate(0, 0, -5),
+		today.AddDate(0, 0, -6),
+	}
+
+	result := s.dailyRuns(today, nil, records, nil)
+	if result.current != 0 {
+		t.Errorf("expected no current streak, got %d", result.current)
+	}
+	if len(result.runs) != 1 || result.runs[0].Length != 2 {
+		t.Errorf("expected one past run of 2, got %v", result.runs)
+	}
+}
+
+func TestDailyRuns_MoreThanHundredDays(t *testing.T) {
+	s := NewService(nil)
+	today := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	var records []time.Time
+	for i := 0; i < 150; i++ {
+		records = append(records, today.AddDate(0, 0, -i))
+	}
+
+	result := s.dailyRuns(today, nil, records, nil)
+	if result.current != 150 {
+		t.Errorf("expected streak of 150, got %d", result.current)
+	}
+}
+
+func TestPeriodRuns_History(t *testing.T) {
+	s := NewService(nil)
+	today := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC) // Wednesday
+	h := &habit.Habit{TargetCount: 1, TargetPeriod: habit.PeriodWeek}
+	records := []time.Time{
+		today.AddDate(0, 0, -7),
+		today.AddDate(0, 0, -21),
+		today.AddDate(0, 0, -28),
+	}
+
+	result := s.periodRuns(today, h, records, nil)
+	if result.current != 1 {
+		t.Errorf("expected current streak of 1 week, got %d", result.current)
+	}
+	if len(result.runs) != 2 {
+		t.Fatalf("expected 2 runs, got %d", len(result.runs))
+	}
+
+	past := result.runs[1]
+	if past.Length != 2 {
+		t.Errorf("expected past run of 2 weeks, got %d", past.Length)
+	}
+	if past.Start.Format("2006-01-02") != "2024-12-16" || past.End.Format("2006-01-02") != "2024-12-29" {
+		t.Errorf("expected past run Dec 16-29, got %v to %v", past.Start, past.End)
+	}
+}
+
+func TestPeriodRuns_HistoryLocalClock(t *testing.T) {
+	s := NewService(nil)
+	// Wednesday morning east of UTC; record dates are UTC midnights
+	today := time.Date(2025, 1, 15, 9, 0, 0, 0, time.FixedZone("CET", 60*60))
+	h := &habit.Habit{TargetCount: 1, TargetPeriod: habit.PeriodWeek}
+	day := func(value string) time.Time {
+		d, _ := time.Parse("2006-01-02", value)
+		return d
+	}
+	records := []time.Time{day("2025-01-13"), day("2024-12-23"), day("2024-12-16")}
+
+	result := s.periodRuns(today, h, records, nil)
+	if result.current != 1 {
+		t.Errorf("expected current streak of 1 week, got %d", result.current)
+	}
+	if len(result.runs) != 2 {
+		t.Fatalf("expected 2 runs, got %+v", result.runs)
+	}
+
+	// The earliest week must not be lost, or the longest streak shrinks
+	longest := longestRun(result.runs)
+	if longest.Length != 2 || longest.Start.Format("2006-01-02") != "2024-12-16" || longest.End.Format("2006-01-02") != "2024-12-29" {
+		t.Errorf("expected the longest run Dec 16-29, got %+v", longest)
+	}
+}
+
+func TestGetHistory(t *testing.T) {
+	now := time.Now()
+	s := NewService(&mockStreakStore{
+		records: []time.Time{
+			now,
+			now.AddDate(0, 0, -1),
+			now.AddDate(0, 0, -5),
+		},
+	})
+
+	history, err := s.GetHistory(1, 1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if history.CurrentCount != 2 {
+		t.Errorf("expected CurrentCount 2, got %d", history.CurrentCount)
+	}
+	if len(history.Runs) != 2 {
+		t.Errorf("expected 2 runs, got %d", len(history.Runs))
+	}
+	if history.Longest == nil || history.Longest.Length != 2 {
+		t.Errorf("expected longest run of 2, got %v", history.Longest)
+	}
+
+	streak, _ := s.GetByHabitID(1, 1)
+	if streak.LongestCount != 2 {
+		t.Errorf("expected LongestCount 2, got %d", streak.LongestCount)
+	}
+}
+
+func TestGetHistory_StoreError(t *testing.T) {
+	s := NewService(&mockStreakStore{recordsErr: errors.New("fetch records failed")})
+
+	if _, err := s.GetHistory(1, 1); err == nil {
+		t.Error("expected error when fetching records fails")
+	}
+}
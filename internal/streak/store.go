@@ -2,7 +2,6 @@ package streak
 
 import (
 	"database/sql"
-	"fmt"
 	"time"
 
 	"github.com/epalmerini/abitudini/internal/habit"
@@ -16,13 +15,18 @@ func NewStore(db *sql.DB) *Store {
 	return &Store{db: db}
 }
 
-func (s *Store) GetRecordsByHabit(habitID int) ([]time.Time, error) {
+// GetRecordsByHabit returns the completed dates of one of userID's habits,
+// newest first
+func (s *Store) GetRecordsByHabit(userID, habitID int) ([]time.Time, error) {
 	rows, err := s.db.Query(
-		`SELECT record_date FROM records 
-		 WHERE habit_id = ? 
-		 ORDER BY record_date DESC 
-		 LIMIT 100`,
+		`SELECT r.record_date FROM records r
+		 JOIN habits h ON h.id = r.habit_id
+		 WHERE r.habit_id = ? AND h.user_id = ?
+		   AND r.status = 'done'
+		   AND (h.daily_target <= 0 OR r.value >= h.daily_target)
+		 ORDER BY r.record_date DESC`,
 		habitID,
+		userID,
 	)
 	if err != nil {
 		return nil, err
@@ -43,25 +47,37 @@ func (s *Store) GetRecordsByHabit(habitID int) ([]time.Time, error) {
 	return recordDates, rows.Err()
 }
 
-func (s *Store) GetHabitByID(habitID int) (*habit.Habit, error) {
-	h := &habit.Habit{}
-	var startDate string
-	var createdAt string
-
-	err := s.db.QueryRow(
-		`SELECT id, description, start_date, color, created_at 
-		 FROM habits WHERE id = ?`,
+// GetSkippedByHabit returns the dates recorded as excused, newest first
+func (s *Store) GetSkippedByHabit(userID, habitID int) ([]time.Time, error) {
+	rows, err := s.db.Query(
+		`SELECT r.record_date FROM records r
+		 JOIN habits h ON h.id = r.habit_id
+		 WHERE r.habit_id = ? AND h.user_id = ? AND r.status = 'skipped'
+		 ORDER BY r.record_date DESC`,
 		habitID,
-	).Scan(&h.ID, &h.Description, &startDate, &h.Color, &createdAt)
-
+		userID,
+	)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("habit not found")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var skippedDates []time.Time
+	for rows.Next() {
+		var dateStr string
+		if err := rows.Scan(&dateStr); err != nil {
+			continue
+		}
+		if date, err := time.Parse("2006-01-02", dateStr); err == nil {
+			skippedDates = append(skippedDates, date)
 		}
-		return nil, fmt.Errorf("failed to get habit: %w", err)
 	}
 
-	h.StartDate, _ = time.Parse("2006-01-02", startDate)
-	h.CreatedAt, _ = time.Parse("2006-01-02 15:04:05", createdAt)
-	return h, nil
+	return skippedDates, rows.Err()
+}
+
+// GetHabitByID reads the habit through the habit slice's store so the
+// column mapping lives in one place
+func (s *Store) GetHabitByID(userID, habitID int) (*habit.Habit, error) {
+	return habit.NewStore(s.db).GetByID(userID, habitID)
 }
@@ -0,0 +1,56 @@
+package streak
+
+import (
+	"testing"
+	"time"
+
+	"github.com/epalmerini/abitudini/internal/testhelpers"
+)
+
+func TestStreakStore_GetRecordsByHabit_NoLimit(t *testing.T) {
+	db := testhelpers.NewTestDB(t)
+	store := NewStore(db)
+
+	result, err := db.Exec(`INSERT INTO habits (user_id, description, start_date, color) VALUES (1, 'Read', '2024-01-01', '')`)
+	if err != nil {
+		t.Fatalf("failed to create habit: %v", err)
+	}
+	id, _ := result.LastInsertId()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 150; i++ {
+		date := start.AddDate(0, 0, i).Format("2006-01-02")
+		if _, err := db.Exec(`INSERT INTO records (habit_id, record_date, completed_at) VALUES (?, ?, ?)`, id, date, time.Now()); err != nil {
+			t.Fatalf("failed to insert record: %v", err)
+		}
+	}
+
+	dates, err := store.GetRecordsByHabit(1, int(id))
+	if err != nil {
+		t.Fatalf("failed to get records: %v", err)
+	}
+	if len(dates) != 150 {
+		t.Errorf("expected 150 records, got %d", len(dates))
+	}
+}
+
+func TestStreakStore_ScopedToOwner(t *testing.T) {
+	db := testhelpers.NewTestDB(t)
+	store := NewStore(db)
+
+	result, err := db.Exec(`INSERT INTO habits (user_id, description, start_date, color) VALUES (1, 'Read', '2024-01-01', '')`)
+	if err != nil {
+		t.Fatalf("failed to create habit: %v", err)
+	}
+	id, _ := result.LastInsertId()
+	if _, err := db.Exec(`INSERT INTO records (habit_id, record_date, completed_at) VALUES (?, '2024-01-01', ?)`, id, time.Now()); err != nil {
+		t.Fatalf("failed to insert record: %v", err)
+	}
+
+	if _, err := store.GetHabitByID(2, int(id)); err == nil {
+		t.Error("expected another user's habit not to be found")
+	}
+	if dates, _ := store.GetRecordsByHabit(2, int(id)); len(dates) != 0 {
+		t.Errorf("expected no records for another user, got %v", dates)
+	}
+}
@@ -1,17 +1,26 @@
 package main
 
 import (
+	"context"
 	"embed"
 	"flag"
-	"fmt"
 	"io/fs"
 	"log"
 	"net/http"
 	"os"
+	"strings"
 
+	"github.com/epalmerini/abitudini/internal/auth"
+	"github.com/epalmerini/abitudini/internal/backup"
+	"github.com/epalmerini/abitudini/internal/calendar"
+	"github.com/epalmerini/abitudini/internal/config"
 	"github.com/epalmerini/abitudini/internal/db"
+	"github.com/epalmerini/abitudini/internal/docs"
+	"github.com/epalmerini/abitudini/internal/export"
 	"github.com/epalmerini/abitudini/internal/habit"
+	"github.com/epalmerini/abitudini/internal/importer"
 	"github.com/epalmerini/abitudini/internal/record"
+	"github.com/epalmerini/abitudini/internal/shared"
 	"github.com/epalmerini/abitudini/internal/streak"
 )
 
@@ -19,9 +28,21 @@ import (
 var staticFiles embed.FS
 
 func main() {
+	// Subcommands such as "abitudini export" run and exit
+	if handled, err := runCommand(os.Args[1:], os.Stdout); handled {
+		if err != nil {
+			log.Fatalf("%s: %v", os.Args[1], err)
+		}
+		return
+	}
+
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:], os.Getenv)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
 	// Initialize database
-	dbPath := "abitudini.db"
-	database, err := db.Init(dbPath)
+	database, err := db.Init(cfg.DBPath)
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
@@ -29,7 +50,37 @@ func main() {
 
 	log.Println("Database initialized successfully")
 
+	// Scheduled backups, when a backup directory is configured
+	if cfg.BackupDir != "" {
+		scheduler := backup.NewScheduler(database, cfg.BackupDir, cfg.BackupInterval, cfg.BackupKeep)
+		go scheduler.Run(context.Background())
+		log.Printf("Backing up to %s every %s, keeping %d", cfg.BackupDir, cfg.BackupInterval, cfg.BackupKeep)
+	}
+
 	// Initialize slices
+	// Auth slice, which guards every other route
+	var oidc auth.OIDCClient
+	if cfg.OIDCIssuer != "" {
+		oidc = auth.NewOIDCProvider(auth.OIDCConfig{
+			Issuer:        cfg.OIDCIssuer,
+			ClientID:      cfg.OIDCClientID,
+			ClientSecret:  cfg.OIDCClientSecret,
+			RedirectURL:   cfg.OIDCRedirectURL,
+			UsernameClaim: cfg.OIDCUsernameClaim,
+		})
+		log.Printf("Single sign-on through %s", cfg.OIDCIssuer)
+	}
+	var proxy *auth.ProxyAuth
+	if cfg.AuthProxyHeader != "" {
+		trusted, err := config.ParseCIDRs(cfg.AuthProxyTrusted)
+		if err != nil {
+			log.Fatalf("Invalid configuration: %v", err)
+		}
+		proxy = &auth.ProxyAuth{Header: cfg.AuthProxyHeader, Trusted: trusted}
+		log.Printf("Trusting the %s header from %s", cfg.AuthProxyHeader, cfg.AuthProxyTrusted)
+	}
+	authHandler := auth.NewHandler(auth.NewService(auth.NewStore(database), cfg.AllowRegistration), oidc, proxy)
+
 	// Record slice (initialize first for habit service dependency)
 	recordStore := record.NewStore(database)
 	recordService := record.NewService(recordStore, nil)
@@ -48,55 +99,131 @@ func main() {
 	streakService := streak.NewService(streakStore)
 	streakHandler := streak.NewHandler(streakService)
 
-	// Routes
-	mux := http.NewServeMux()
+	// Export slice
+	exportHandler := export.NewHandler(export.NewService(export.NewStore(database)))
+
+	// Import slice
+	importHandler := importer.NewHandler(importer.NewService(importer.NewStore(database)))
+
+	// Calendar slice
+	calendarHandler := calendar.NewHandler(calendar.NewService(calendar.NewStore(database)))
 
-	// Habit API Routes
-	mux.HandleFunc("POST /api/habits", habitHandler.Create)
-	mux.HandleFunc("GET /api/habits", habitHandler.GetAll)
-	mux.HandleFunc("GET /api/habits/{id}", habitHandler.GetByID)
-	mux.HandleFunc("PUT /api/habits/{id}", habitHandler.Update)
-	mux.HandleFunc("DELETE /api/habits/{id}", habitHandler.Delete)
+	docsHandler := docs.NewHandler()
 
-	// Record API Routes
-	mux.HandleFunc("POST /api/habits/{id}/done-today", recordHandler.MarkDoneToday)
-	mux.HandleFunc("GET /api/habits/{id}/contribution", recordHandler.GetContribution)
+	// Routes
+	mux := http.NewServeMux()
+	for _, rt := range apiRoutes(habitHandler, recordHandler, streakHandler, exportHandler, importHandler, calendarHandler, authHandler, docsHandler) {
+		switch {
+		case publicRoutes[rt.pattern]:
+			mux.HandleFunc(rt.pattern, rt.handler)
+		case feedRoutes[rt.pattern]:
+			mux.Handle(rt.pattern, authHandler.RequireReadToken(rt.handler))
+		case acceptsTokens(rt.pattern):
+			mux.Handle(rt.pattern, authHandler.RequireUserOrToken(rt.handler))
+		default:
+			mux.Handle(rt.pattern, authHandler.RequireUser(rt.handler))
+		}
+	}
 
-	// Streak API Routes
-	mux.HandleFunc("GET /api/habits/{id}/streak", streakHandler.GetByHabitID)
+	// Accounts
+	mux.HandleFunc("GET /login", authHandler.LoginPage)
+	mux.HandleFunc("POST /login", authHandler.Login)
+	mux.HandleFunc("GET /register", authHandler.RegisterPage)
+	mux.HandleFunc("POST /register", authHandler.Register)
+	mux.HandleFunc("POST /logout", authHandler.Logout)
+	mux.HandleFunc("GET /login/oidc", authHandler.OIDCLogin)
+	mux.HandleFunc("GET /login/oidc/callback", authHandler.OIDCCallback)
+	mux.Handle("GET /tokens", authHandler.RequireUser(http.HandlerFunc(authHandler.TokensPage)))
 
 	// Static files
 	staticSubFS, _ := fs.Sub(staticFiles, "static")
 	mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServer(http.FS(staticSubFS))))
 
 	// Home page
-	mux.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
-		habits, err := habitService.GetAll()
+	mux.Handle("GET /", authHandler.RequireUser(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := shared.UserFromContext(r.Context())
+		habits, err := habitService.GetAll(user.ID)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		w.Header().Set("Content-Type", "text/html")
-		w.Write([]byte(habit.RenderAllHabits(habits)))
-	})
+		w.Write([]byte(habit.RenderAllHabits(habits, user.Username, shared.CSRFToken(r.Context()))))
+	})))
 
 	// Server
-	portFlag := flag.String("p", "", "Port to listen on (default: 8080, or ABITUDINI_PORT env var)")
-	flag.Parse()
-
-	port := *portFlag
-	if port == "" {
-		port = os.Getenv("ABITUDINI_PORT")
-	}
-	if port == "" {
-		port = "8080"
-	}
-	if port[0] != ':' {
-		port = ":" + port
+	log.Printf("Server listening on %s", cfg.Addr)
+	if err := http.ListenAndServe(cfg.Addr, authHandler.CSRF(mux)); err != nil && err != http.ErrServerClosed {
+		log.Fatalf("Server error: %v", err)
 	}
+}
 
-	fmt.Printf("Server running on http://localhost%s\n", port)
-	if err := http.ListenAndServe(port, mux); err != nil && err != http.ErrServerClosed {
-		log.Fatalf("Server error: %v", err)
+// route pairs a mux pattern with its handler
+type route struct {
+	pattern string
+	handler http.HandlerFunc
+}
+
+// publicRoutes are served without logging in
+var publicRoutes = map[string]bool{
+	"GET /api/openapi.json": true,
+	"GET /api/docs":         true,
+}
+
+// feedRoutes are subscribed to by clients that cannot log in or send
+// headers. They take a read-only API token in the URL instead.
+var feedRoutes = map[string]bool{
+	"GET /api/calendar.ics": true,
+}
+
+// acceptsTokens reports whether a route takes API tokens as well as a
+// session: the habit routes, which scripts use
+func acceptsTokens(pattern string) bool {
+	_, path, _ := strings.Cut(pattern, " ")
+	return path == "/api/habits" || strings.HasPrefix(path, "/api/habits/")
+}
+
+// apiRoutes lists every API route. Each one must be described in the
+// OpenAPI document served at /api/openapi.json.
+func apiRoutes(habitHandler *habit.Handler, recordHandler *record.Handler, streakHandler *streak.Handler, exportHandler *export.Handler, importHandler *importer.Handler, calendarHandler *calendar.Handler, authHandler *auth.Handler, docsHandler *docs.Handler) []route {
+	return []route{
+		// Habit API Routes
+		{"POST /api/habits", habitHandler.Create},
+		{"GET /api/habits", habitHandler.GetAll},
+		{"GET /api/habits/{id}", habitHandler.GetByID},
+		{"PUT /api/habits/{id}", habitHandler.Update},
+		{"DELETE /api/habits/{id}", habitHandler.Delete},
+
+		// Record API Routes
+		{"POST /api/habits/{id}/done-today", recordHandler.MarkDoneToday},
+		{"POST /api/habits/{id}/skip-today", recordHandler.SkipToday},
+		{"POST /api/habits/{id}/add-amount", recordHandler.AddAmount},
+		{"PUT /api/habits/{id}/records/{date}", recordHandler.MarkDate},
+		{"DELETE /api/habits/{id}/records/{date}", recordHandler.UnmarkDate},
+		{"GET /api/habits/{id}/contribution", recordHandler.GetContribution},
+		{"GET /api/habits/{id}/journal", recordHandler.GetJournal},
+
+		// Streak API Routes
+		{"GET /api/habits/{id}/streak", streakHandler.GetByHabitID},
+		{"GET /api/habits/{id}/streaks", streakHandler.GetHistory},
+
+		// Export API Routes
+		{"GET /api/export", exportHandler.Export},
+
+		// Import API Routes
+		{"POST /api/import", importHandler.Import},
+		{"POST /api/import/loop", importHandler.ImportLoop},
+
+		// Calendar API Routes
+		{"GET /api/calendar.ics", calendarHandler.Feed},
+
+		// API token Routes
+		{"GET /api/tokens", authHandler.ListTokens},
+		{"POST /api/tokens", authHandler.CreateToken},
+		{"DELETE /api/tokens/{id}", authHandler.RevokeToken},
+
+		// API documentation
+		{"GET /api/openapi.json", docsHandler.Spec},
+		{"GET /api/docs", docsHandler.Page},
 	}
 }
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/epalmerini/abitudini/internal/auth"
+	"github.com/epalmerini/abitudini/internal/calendar"
+	"github.com/epalmerini/abitudini/internal/docs"
+	"github.com/epalmerini/abitudini/internal/export"
+	"github.com/epalmerini/abitudini/internal/habit"
+	"github.com/epalmerini/abitudini/internal/importer"
+	"github.com/epalmerini/abitudini/internal/record"
+	"github.com/epalmerini/abitudini/internal/streak"
+)
+
+func testRoutes() []route {
+	return apiRoutes(
+		habit.NewHandler(nil),
+		record.NewHandler(nil),
+		streak.NewHandler(nil),
+		export.NewHandler(nil),
+		importer.NewHandler(nil),
+		calendar.NewHandler(nil),
+		auth.NewHandler(nil, nil, nil),
+		docs.NewHandler(),
+	)
+}
+
+func TestAPIRoutes_DescribedInOpenAPI(t *testing.T) {
+	operations, err := docs.Operations()
+	if err != nil {
+		t.Fatalf("failed to read OpenAPI operations: %v", err)
+	}
+
+	for _, rt := range testRoutes() {
+		if !slices.Contains(operations, rt.pattern) {
+			t.Errorf("route %q is missing from the OpenAPI document", rt.pattern)
+		}
+	}
+}
+
+func TestOpenAPI_OnlyDescribesRegisteredRoutes(t *testing.T) {
+	operations, err := docs.Operations()
+	if err != nil {
+		t.Fatalf("failed to read OpenAPI operations: %v", err)
+	}
+
+	var patterns []string
+	for _, rt := range testRoutes() {
+		patterns = append(patterns, rt.pattern)
+	}
+	for _, op := range operations {
+		if !slices.Contains(patterns, op) {
+			t.Errorf("OpenAPI operation %q has no registered route", op)
+		}
+	}
+}
+
+func TestAPIRoutes_Register(t *testing.T) {
+	// Conflicting or malformed patterns make ServeMux panic
+	mux := http.NewServeMux()
+	for _, rt := range testRoutes() {
+		mux.HandleFunc(rt.pattern, rt.handler)
+	}
+}
+
+func TestPublicRoutes_Registered(t *testing.T) {
+	var patterns []string
+	for _, rt := range testRoutes() {
+		patterns = append(patterns, rt.pattern)
+	}
+	for pattern := range publicRoutes {
+		if !slices.Contains(patterns, pattern) {
+			t.Errorf("public route %q is not registered", pattern)
+		}
+	}
+}
+
+func TestAcceptsTokens(t *testing.T) {
+	for pattern, want := range map[string]bool{
+		"GET /api/habits":                  true,
+		"POST /api/habits/{id}/done-today": true,
+		"GET /api/export":                  false,
+		"POST /api/tokens":                 false,
+		"GET /api/habitsandmore":           false,
+	} {
+		if got := acceptsTokens(pattern); got != want {
+			t.Errorf("acceptsTokens(%q) = %v, want %v", pattern, got, want)
+		}
+	}
+}